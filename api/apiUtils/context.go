@@ -2,9 +2,12 @@ package apiUtils
 
 import (
 	"backend/api/middleware"
+	"backend/dto"
 	"backend/token"
 	"context"
 	"fmt"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,3 +24,30 @@ func GetContextFromGinContext(ginCtx *gin.Context) context.Context {
 		middleware.AuthenticationPayloadKey,
 		value.(*token.Payload))
 }
+
+func GetRefreshContextFromGinContext(ginCtx *gin.Context) context.Context {
+	return context.WithValue(
+		ginCtx.Request.Context(),
+		middleware.RefreshTokenPayloadKey,
+		ginCtx.MustGet(fmt.Sprint(middleware.RefreshTokenPayloadKey)).(*token.RefreshPayload))
+}
+
+// maxUserAgentLength is the size of sessions.user_agent in characters
+const maxUserAgentLength = 1024
+
+func GetClientInfo(ginCtx *gin.Context) dto.ClientInfo {
+	return dto.ClientInfo{
+		IPAddress: ginCtx.ClientIP(),
+		UserAgent: truncateUserAgent(ginCtx.Request.UserAgent()),
+	}
+}
+
+// truncateUserAgent cuts the header to what the sessions table stores, the
+// header is not limited so a long one would fail the login or refresh
+func truncateUserAgent(userAgent string) string {
+	userAgent = strings.ToValidUTF8(userAgent, "")
+	if utf8.RuneCountInString(userAgent) <= maxUserAgentLength {
+		return userAgent
+	}
+	return string([]rune(userAgent)[:maxUserAgentLength])
+}
@@ -0,0 +1,37 @@
+package apiUtils
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetClientInfoUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{name: "short", userAgent: "Mozilla/5.0", want: "Mozilla/5.0"},
+		{name: "column size", userAgent: strings.Repeat("a", maxUserAgentLength), want: strings.Repeat("a", maxUserAgentLength)},
+		{name: "longer than the column", userAgent: strings.Repeat("a", 10000), want: strings.Repeat("a", maxUserAgentLength)},
+		{name: "multi byte characters", userAgent: strings.Repeat("ü", 2000), want: strings.Repeat("ü", maxUserAgentLength)},
+		{name: "invalid utf-8", userAgent: "Mozilla\xff/5.0", want: "Mozilla/5.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ginCtx.Request = httptest.NewRequest("POST", "/api/v1/login", nil)
+			ginCtx.Request.Header.Set("User-Agent", tt.userAgent)
+
+			got := GetClientInfo(ginCtx).UserAgent
+			if got != tt.want {
+				t.Errorf("user agent = %.40q (%d characters), want %.40q (%d characters)", got, utf8.RuneCountInString(got), tt.want, utf8.RuneCountInString(tt.want))
+			}
+		})
+	}
+}
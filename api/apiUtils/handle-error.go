@@ -1,18 +1,12 @@
 package apiUtils
 
 import (
-	"backend/dto"
-	"errors"
-	"net/http"
+	"backend/api/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
+// SendErrorResponse answers with the error as application/problem+json
 func SendErrorResponse(c *gin.Context, err error) {
-	var httpErr *dto.Error
-	if errors.As(err, &httpErr) {
-		c.JSON(httpErr.Code, err)
-		return
-	}
-	c.JSON(http.StatusInternalServerError, dto.NewError(err.Error()))
+	middleware.AbortWithProblem(c, err)
 }
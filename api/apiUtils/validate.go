@@ -2,6 +2,7 @@ package apiUtils
 
 import (
 	"backend/dto"
+	"backend/i18n"
 	"backend/utils"
 	"context"
 	"errors"
@@ -25,28 +26,40 @@ func AddCustomValidator(val any) error {
 	return nil
 }
 
+// CustomValidationError returns the english message of the field error, the
+// responses use the language of the client
 func CustomValidationError(err validator.FieldError) string {
-	fieldName := strings.Join(strings.Split(err.Namespace(), ".")[1:], ".")
+	return i18n.Validation(i18n.DefaultLanguage, validationKey(err), map[string]string{
+		"field": validationField(err),
+		"param": err.Param(),
+	})
+}
+
+// validationKey is the catalog key of the failed tag, the length tags have
+// their own messages for lists and numbers
+func validationKey(err validator.FieldError) string {
 	switch err.Tag() {
-	case "required":
-		return fmt.Sprintf("%s is required", fieldName)
-	case "email":
-		return fmt.Sprintf("%s not in email format", fieldName)
-	case "min":
-		return fmt.Sprintf("%s must be longer than or equal %s characters", fieldName, err.Param())
-	case "max":
-		return fmt.Sprintf("%s cannot be longer than %s characters", fieldName, err.Param())
-	case "gt":
-		return fmt.Sprintf("%s should be atleast %s", fieldName, err.Param())
-	case "gte":
-		return fmt.Sprintf("%s should have atleast %s element", fieldName, err.Param())
-	case "lte":
-		return fmt.Sprintf("%s should have maximum %s elements", fieldName, err.Param())
-	case "iso3166_1_alpha3":
-		return fmt.Sprintf("%s is not valid country '%s'", fieldName, err.Param())
+	case "min", "max", "len", "gt", "gte", "lt", "lte":
 	default:
-		return err.Error()
+		return err.Tag()
 	}
+
+	switch err.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return err.Tag() + "_items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if err.Tag() == "min" || err.Tag() == "max" {
+			return err.Tag() + "_value"
+		}
+	}
+	return err.Tag()
+}
+
+// validationField is the json path of the field without the request struct
+func validationField(err validator.FieldError) string {
+	return strings.Join(strings.Split(err.Namespace(), ".")[1:], ".")
 }
 
 func validatorTagFunc(fl reflect.StructField) string {
@@ -62,10 +75,21 @@ func ValidatorError(err error) error {
 
 	var ve validator.ValidationErrors
 	if errors.As(err, &ve) {
+		errResponse.ErrorCode = dto.ErrCodeValidationFailed
 		for _, fieldErr := range ve {
-			errResponse.AddReason(CustomValidationError(fieldErr))
+			message := CustomValidationError(fieldErr)
+			errResponse.AddReason(message)
+			errResponse.Fields = append(errResponse.Fields, dto.FieldError{
+				Field:   validationField(fieldErr),
+				Code:    fieldErr.Tag(),
+				Message: message,
+				Key:     validationKey(fieldErr),
+				Param:   fieldErr.Param(),
+			})
 		}
 	} else {
+		// the body could not be decoded
+		errResponse.ErrorCode = dto.ErrCodeInvalidPayload
 		errResponse.AddReason(err.Error())
 	}
 	errResponse.Code = http.StatusBadRequest
@@ -86,7 +110,7 @@ func ValidateStruct(val any) error {
 func AssignAndValidateCreateUserPayload(c context.Context, p any, payload any) error {
 	err := utils.ConvertToJSONAndBack(p, &payload)
 	if err != nil {
-		return dto.NewErrorWithStatus(http.StatusBadRequest, "invalid payload")
+		return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidPayload, "invalid payload")
 	}
 
 	err = ValidateStruct(payload)
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"backend/dto"
+	"backend/revocation"
 	"backend/token"
 	"errors"
 	"fmt"
@@ -25,9 +26,10 @@ const (
 
 var (
 	ErrHeaderNotProvided = errors.New("authentication header is not provided")
+	errRevocationCheck   = errors.New("could not check token revocation")
 )
 
-func getPayloadFromContext(c *gin.Context, tokenMaker token.Maker) (*token.Payload, *gin.Context, error) {
+func getPayloadFromContext(c *gin.Context, tokenMaker token.Maker, revocations revocation.Store) (*token.Payload, *gin.Context, error) {
 	authenticationHeader := c.GetHeader(authenticationHeaderKey)
 	if len(authenticationHeader) == 0 {
 		return nil, c, ErrHeaderNotProvided
@@ -51,26 +53,60 @@ func getPayloadFromContext(c *gin.Context, tokenMaker token.Maker) (*token.Paylo
 		return nil, c, err
 	}
 
+	revoked, err := revocations.IsRevoked(c.Request.Context(), payload)
+	if err != nil {
+		return nil, c, fmt.Errorf("%w: %w", errRevocationCheck, err)
+	}
+	if revoked {
+		return nil, c, token.ErrRevokedToken
+	}
+
 	c.Set(fmt.Sprint(AuthenticationPayloadKey), payload)
-	// ctx := utils.AppendCtx(c, slog.Int64("user_id", payload.UserID)).(*gin.Context) // TODO: check on how to make this work?
+	setRequestContext(c, slog.Int64("user_id", payload.UserID))
+	if payload.Locale != "" {
+		setLanguage(c, payload.Locale)
+	}
 
-	slog.InfoContext(c, "authenticated user token",
+	slog.InfoContext(c.Request.Context(), "authenticated user token",
 		slog.String("token_id", payload.ID.String()),
 		slog.String("token_type", payload.Type),
 		slog.String("issuer", payload.Issuer),
-		slog.Int64("user_id", payload.UserID),
 	)
 
 	return payload, c, nil
 }
 
+// unauthorizedError gives token errors the code that tells clients whether to
+// refresh the token or to log in again
+func unauthorizedError(err error) error {
+	code := dto.ErrCodeTokenInvalid
+	switch {
+	case errors.Is(err, ErrHeaderNotProvided):
+		code = dto.ErrCodeTokenMissing
+	case errors.Is(err, token.ErrExpiredToken):
+		code = dto.ErrCodeTokenExpired
+	case errors.Is(err, token.ErrRevokedToken):
+		code = dto.ErrCodeTokenRevoked
+	}
+	return dto.NewErrorWithCode(http.StatusUnauthorized, code, err.Error())
+}
+
+// authenticationError answers failures of the revocation store with a 500,
+// logging in again would not help the client
+func authenticationError(err error) error {
+	if errors.Is(err, errRevocationCheck) {
+		return err
+	}
+	return unauthorizedError(err)
+}
+
 // AuthMiddleware creates a gin middleware for authentication
-func AuthMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+func AuthMiddleware(tokenMaker token.Maker, revocations revocation.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		_, ctx, err := getPayloadFromContext(c, tokenMaker)
+		_, ctx, err := getPayloadFromContext(c, tokenMaker, revocations)
 		if err != nil {
 			slog.InfoContext(ctx, "token validation failed", slog.Any("error", err))
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, dto.NewError(err.Error()))
+			AbortWithProblem(ctx, authenticationError(err))
 		}
 
 		ctx.Next()
@@ -78,12 +114,12 @@ func AuthMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 }
 
 // Do authentication if token exists, if not skip token validation
-func AuthMiddlewareOptional(tokenMaker token.Maker) gin.HandlerFunc {
+func AuthMiddlewareOptional(tokenMaker token.Maker, revocations revocation.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		_, ctx, err := getPayloadFromContext(c, tokenMaker)
+		_, ctx, err := getPayloadFromContext(c, tokenMaker, revocations)
 		if err != nil && err != ErrHeaderNotProvided {
 			slog.InfoContext(ctx, "token validation failed (optional header)", slog.Any("error", err))
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, dto.NewError(err.Error()))
+			AbortWithProblem(ctx, authenticationError(err))
 		}
 
 		ctx.Next()
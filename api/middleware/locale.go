@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"backend/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+const localeParam = "lang"
+
+// LocaleMiddleware picks the language of the error messages, the lang query
+// parameter or cookie the user chose wins over the Accept-Language header.
+// AuthMiddleware picks it again with the locale of the access token
+func LocaleMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setLanguage(c, "")
+		c.Header("Vary", "Accept-Language")
+		c.Next()
+	}
+}
+
+// setLanguage stores the language in the request context, the lang query
+// parameter and cookie come first, then the locale the user stored and then
+// the Accept-Language header
+func setLanguage(c *gin.Context, userLocale string) {
+	preference := c.Query(localeParam)
+	if preference == "" {
+		preference, _ = c.Cookie(localeParam)
+	}
+
+	lang := i18n.Match(preference, userLocale, c.GetHeader("Accept-Language"))
+	c.Request = c.Request.WithContext(i18n.WithLanguage(c.Request.Context(), lang))
+}
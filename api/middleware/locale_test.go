@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"backend/i18n"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLanguagePreferenceOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		cookie         string
+		userLocale     string
+		acceptLanguage string
+		want           string
+	}{
+		{name: "query parameter", query: "es", cookie: "de", userLocale: "de", acceptLanguage: "de", want: "es"},
+		{name: "cookie", cookie: "es", userLocale: "de", acceptLanguage: "de", want: "es"},
+		{name: "user locale", userLocale: "de", acceptLanguage: "es", want: "de"},
+		{name: "accept language", acceptLanguage: "es-MX,es;q=0.9", want: "es"},
+		{name: "unsupported user locale", userLocale: "fr", acceptLanguage: "de", want: "de"},
+		{name: "nothing", want: "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			r := gin.New()
+			r.Use(LocaleMiddleware())
+			r.GET("/", func(c *gin.Context) {
+				// what AuthMiddleware does with the locale of the access token
+				if tt.userLocale != "" {
+					setLanguage(c, tt.userLocale)
+				}
+				got = i18n.FromContext(c.Request.Context()).String()
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.query != "" {
+				req.URL.RawQuery = localeParam + "=" + tt.query
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: localeParam, Value: tt.cookie})
+			}
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("language = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
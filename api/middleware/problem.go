@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"backend/dto"
+	"backend/i18n"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+// AbortWithProblem writes the error as application/problem+json, errors that
+// are not a dto.Error are logged and answered with a generic internal error so
+// their message does not reach the client
+func AbortWithProblem(c *gin.Context, err error) {
+	var dtoErr *dto.Error
+	if !errors.As(err, &dtoErr) {
+		slog.ErrorContext(c.Request.Context(), "unexpected error", slog.Any("error", err))
+		dtoErr = &dto.Error{
+			Reason:    []string{"internal server error"},
+			Code:      http.StatusInternalServerError,
+			ErrorCode: dto.ErrCodeInternal,
+		}
+	}
+
+	lang := i18n.FromContext(c.Request.Context())
+	problem := dto.NewProblem(dtoErr, lang)
+	problem.RequestID = c.Writer.Header().Get(RequestIDHeader)
+
+	c.Header("Content-Type", dto.ProblemContentType)
+	c.Header("Content-Language", lang.String())
+	c.Render(problem.Status, render.JSON{Data: problem})
+	c.Abort()
+}
@@ -1,12 +1,10 @@
 package middleware
 
 import (
-	"backend/dto"
 	"backend/token"
 	"errors"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -25,8 +23,7 @@ func validateAndSetRefreshTokenContext(tokenMaker token.Maker, ctx *gin.Context)
 	refreshHeader := ctx.GetHeader(refreshHeaderKey)
 
 	if len(refreshHeader) == 0 {
-		err := errors.New("authentication header is not provided")
-		return nil, ctx, err
+		return nil, ctx, ErrHeaderNotProvided
 	}
 
 	fields := strings.Fields(refreshHeader)
@@ -48,12 +45,12 @@ func validateAndSetRefreshTokenContext(tokenMaker token.Maker, ctx *gin.Context)
 	}
 
 	ctx.Set(fmt.Sprint(AuthenticationPayloadKey), payload)
+	setRequestContext(ctx, slog.Int64("user_id", payload.UserID))
 
-	slog.InfoContext(ctx, "authenticated user token",
+	slog.InfoContext(ctx.Request.Context(), "authenticated user token",
 		slog.String("token_id", payload.ID.String()),
 		slog.String("token_type", payload.Type),
 		slog.String("issuer", payload.Issuer),
-		slog.Int64("user_id", payload.UserID),
 	)
 
 	return payload, ctx, nil
@@ -64,7 +61,7 @@ func RefreshTokenValidateMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 		_, ctx, err := validateAndSetRefreshTokenContext(tokenMaker, c)
 		if err != nil {
 			slog.InfoContext(ctx, "refresh-token validation failed", slog.Any("error", err))
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, dto.NewError(err.Error()))
+			AbortWithProblem(ctx, unauthorizedError(err))
 		}
 
 		ctx.Next()
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"backend/utils"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	maxRequestIDLen = 128
+)
+
+// RequestIDMiddleware keeps the X-Request-ID of the caller or generates one,
+// echoes it in the response and adds it together with the route and client ip
+// to the request context, so every slog.*Context call of the request logs them
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		setRequestContext(c,
+			slog.String("request_id", requestID),
+			slog.String("route", c.FullPath()),
+			slog.String("client_ip", c.ClientIP()),
+		)
+		c.Next()
+	}
+}
+
+// validRequestID only accepts short ids of printable ascii, so callers cannot
+// write arbitrary data into the logs
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLen {
+		return false
+	}
+
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < '!' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// setRequestContext adds log attributes to the context of the request, the
+// handlers and services get them through c.Request.Context()
+func setRequestContext(c *gin.Context, attrs ...slog.Attr) {
+	c.Request = c.Request.WithContext(utils.AppendCtx(c.Request.Context(), attrs...))
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"backend/dto"
+	"backend/token"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPayload returns the access token payload AuthMiddleware validated, it is
+// missing when the route is not authenticated or no token was sent
+func GetPayload(c *gin.Context) (*token.Payload, bool) {
+	value, exists := c.Get(fmt.Sprint(AuthenticationPayloadKey))
+	if !exists {
+		return nil, false
+	}
+	payload, ok := value.(*token.Payload)
+	return payload, ok
+}
+
+// RequireScope only lets requests through whose access token grants the
+// scope, it must run after AuthMiddleware
+func RequireScope(scope string) gin.HandlerFunc {
+	return requirePayload(func(payload *token.Payload) error {
+		if payload.HasScope(scope) {
+			return nil
+		}
+		return dto.NewErrorWithParams(http.StatusForbidden, dto.ErrCodeInsufficientScope, "token does not grant the scope", map[string]string{"scope": scope})
+	})
+}
+
+// RequireRole only lets requests through whose access token has the role, it
+// must run after AuthMiddleware
+func RequireRole(role string) gin.HandlerFunc {
+	return requirePayload(func(payload *token.Payload) error {
+		if payload.HasRole(role) {
+			return nil
+		}
+		return dto.NewErrorWithParams(http.StatusForbidden, dto.ErrCodeMissingRole, "token does not have the role", map[string]string{"role": role})
+	})
+}
+
+func requirePayload(check func(payload *token.Payload) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		payload, ok := GetPayload(c)
+		if !ok {
+			AbortWithProblem(c, unauthorizedError(ErrHeaderNotProvided))
+			return
+		}
+
+		if err := check(payload); err != nil {
+			slog.InfoContext(c.Request.Context(), "token lacks permission", slog.Any("error", err))
+			AbortWithProblem(c, err)
+			return
+		}
+
+		c.Next()
+	}
+}
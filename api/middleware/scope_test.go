@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"backend/dto"
+	"backend/token"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireScopeAndRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		middleware gin.HandlerFunc
+		payload    *token.Payload
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "scope without payload", middleware: RequireScope("users:read"), wantStatus: http.StatusUnauthorized, wantCode: dto.ErrCodeTokenMissing},
+		{name: "scope missing", middleware: RequireScope("users:read"), payload: &token.Payload{Scopes: []string{"users:write"}}, wantStatus: http.StatusForbidden, wantCode: dto.ErrCodeInsufficientScope},
+		{name: "scope granted", middleware: RequireScope("users:read"), payload: &token.Payload{Scopes: []string{"users:write", "users:read"}}, wantStatus: http.StatusOK},
+		{name: "role without payload", middleware: RequireRole("admin"), wantStatus: http.StatusUnauthorized, wantCode: dto.ErrCodeTokenMissing},
+		{name: "role missing", middleware: RequireRole("admin"), payload: &token.Payload{Roles: []string{"user"}, Scopes: []string{"admin"}}, wantStatus: http.StatusForbidden, wantCode: dto.ErrCodeMissingRole},
+		{name: "role granted", middleware: RequireRole("admin"), payload: &token.Payload{Roles: []string{"admin"}}, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", func(c *gin.Context) {
+				// what AuthMiddleware does with a valid access token
+				if tt.payload != nil {
+					c.Set(fmt.Sprint(AuthenticationPayloadKey), tt.payload)
+				}
+			}, tt.middleware, func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+
+			var problem dto.Problem
+			if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("could not decode problem %q: %v", recorder.Body.String(), err)
+			}
+			if problem.Code != tt.wantCode {
+				t.Errorf("code = %s, want %s", problem.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -3,7 +3,10 @@ package api
 import (
 	"backend/api/middleware"
 	v1 "backend/api/v1"
+	"backend/health"
+	"backend/revocation"
 	"backend/service"
+	platformService "backend/service/platform"
 	"backend/token"
 	"backend/utils"
 	"net/http"
@@ -17,26 +20,87 @@ func RegisterPath(
 	serviceName string,
 	version string,
 	tokenMaker token.Maker,
+	revocations revocation.Store,
 	userService service.UserService,
+	passkeyService platformService.PasskeyService,
+	emailLinkService platformService.EmailLinkService,
+	googleService platformService.GoogleService,
+	oidcServices []platformService.OIDCService,
+	healthChecks *health.Health,
 ) {
 
 	v1Route := r.Group("/v1")
 
 	// handlers
 	userHandler := v1.NewUserHandler(userService)
+	passkeyHandler := v1.NewPasskeyHandler(passkeyService)
+	emailLinkHandler := v1.NewEmailLinkHandler(emailLinkService)
+	googleHandler := v1.NewGoogleHandler(googleService)
+	oidcHandler := v1.NewOIDCHandler(oidcServices)
 
 	// user
 	userRouter := v1Route.Group("/users")
 	userRouter.POST("/", userHandler.CreateUser())
-	userRouter.GET("/:userID", middleware.AuthMiddleware(tokenMaker), userHandler.GetUser())
+	userRouter.GET("/:userID", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.GetUser())
+	userRouter.PUT("/:userID/locale", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.UpdateLocale())
 	userRouter.POST("/token", userHandler.Login())
+	userRouter.POST("/token/2fa", userHandler.LoginTwoFactor())
+	userRouter.POST("/token/revoke", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.RevokeAccessToken())
 	userRouter.POST("/refresh-token", middleware.RefreshTokenValidateMiddleware(tokenMaker), userHandler.RefreshToken())
-	userRouter.POST("/:userID/auth", middleware.AuthMiddleware(tokenMaker), userHandler.ConnectAuthPlatform())
-	userRouter.DELETE("/:userID/auth/:provider", middleware.AuthMiddleware(tokenMaker), userHandler.UnlinkAuthPlatform())
+	userRouter.POST("/logout", middleware.RefreshTokenValidateMiddleware(tokenMaker), userHandler.Logout())
+	userRouter.POST("/:userID/logout-all", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.LogoutAllSessions())
+	userRouter.POST("/:userID/tokens/revoke", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.RevokeAccessTokens())
+	userRouter.POST("/:userID/2fa/totp", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.EnrollTotp())
+	userRouter.POST("/:userID/2fa/totp/confirm", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.ConfirmTotp())
+	userRouter.POST("/:userID/2fa/totp/disable", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.DisableTotp())
+	userRouter.POST("/:userID/2fa/recovery-codes", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.RegenerateRecoveryCodes())
+	userRouter.GET("/:userID/sessions", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.ListSessions())
+	userRouter.DELETE("/:userID/sessions/:sessionID", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.RevokeSession())
+	userRouter.POST("/:userID/auth", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.ConnectAuthPlatform())
+	userRouter.DELETE("/:userID/auth/:provider", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.UnlinkAuthPlatform())
+	userRouter.POST("/google/authorize", googleHandler.StartAuthorization())
+	userRouter.POST("/oidc/:provider/authorize", oidcHandler.StartAuthorization())
+	userRouter.POST("/passkey/login-options", passkeyHandler.BeginLogin())
+	userRouter.POST("/:userID/passkey/registration-options", middleware.AuthMiddleware(tokenMaker, revocations), passkeyHandler.BeginRegistration())
+	userRouter.POST("/email-link", emailLinkHandler.RequestLogin())
+	userRouter.POST("/verify-email", userHandler.VerifyEmail())
+	userRouter.POST("/:userID/verify-email/resend", middleware.AuthMiddleware(tokenMaker, revocations), userHandler.ResendVerificationEmail())
+	userRouter.POST("/password-reset", userHandler.RequestPasswordReset())
+	userRouter.POST("/password-reset/confirm", userHandler.ConfirmPasswordReset())
+
+	r.GET("/.well-known/jwks.json", jwksHandler(tokenMaker))
 
 	// health check
 	r.GET("/health", func(c *gin.Context) {
 		response := map[string]string{"status": "UP", "service": serviceName, "version": version}
 		c.JSON(http.StatusOK, response)
 	})
+
+	r.GET("/livez", func(c *gin.Context) {
+		c.JSON(http.StatusOK, healthChecks.Live())
+	})
+
+	r.GET("/readyz", readyzHandler(healthChecks))
+}
+
+// readyzHandler answers 503 while a check fails or the service shuts down, so
+// load balancers stop sending requests
+func readyzHandler(healthChecks *health.Health) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := healthChecks.Ready(c)
+		if report.Status != health.StatusUp {
+			c.JSON(http.StatusServiceUnavailable, report)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// jwksHandler serves the public keys of the access tokens, caches must pick up
+// a new key before it signs tokens, so keep max-age below the rotation stages
+func jwksHandler(tokenMaker token.Maker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, tokenMaker.JWKS())
+	}
 }
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"backend/health"
+	"backend/token"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestJWKSHandler(t *testing.T) {
+	oldPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, newPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessKeys, err := token.NewKeyring(token.AlgorithmEdDSA, []token.Key{{ID: "old", Public: oldPublic}, {ID: "new", Private: newPrivate}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+	refreshKeys, err := token.NewKeyring(token.AlgorithmHS256, []token.Key{{ID: "refresh", Private: secret}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	maker, err := token.NewJWTMaker("backend.user", accessKeys, refreshKeys, 900, 3600, token.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.GET("/.well-known/jwks.json", jwksHandler(maker))
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	if cacheControl := recorder.Header().Get("Cache-Control"); cacheControl != "public, max-age=300" {
+		t.Errorf("Cache-Control = %q, want public, max-age=300", cacheControl)
+	}
+
+	var jwks token.JWKS
+	if err = json.Unmarshal(recorder.Body.Bytes(), &jwks); err != nil {
+		t.Fatalf("could not decode jwks %q: %v", recorder.Body.String(), err)
+	}
+
+	// both access keys are published, the refresh secret never is
+	if len(jwks.Keys) != 2 || jwks.Keys[0].KeyID != "old" || jwks.Keys[1].KeyID != "new" {
+		t.Fatalf("jwks = %+v, want the old and the new access key", jwks.Keys)
+	}
+	for _, jwk := range jwks.Keys {
+		if jwk.KeyType != "OKP" || jwk.Algorithm != token.AlgorithmEdDSA {
+			t.Errorf("key = %+v, want an EdDSA OKP key", jwk)
+		}
+	}
+}
+
+func TestReadyzHandler(t *testing.T) {
+	healthChecks := health.New("backend", "1.0.0")
+	database := health.NewChecker("database", func(ctx context.Context) error { return nil })
+	healthChecks.Register(database)
+
+	r := gin.New()
+	r.GET("/readyz", readyzHandler(healthChecks))
+	readyz := func() (int, health.Report) {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		var report health.Report
+		if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+			t.Fatalf("could not decode report %q: %v", recorder.Body.String(), err)
+		}
+		return recorder.Code, report
+	}
+
+	if status, report := readyz(); status != http.StatusOK || report.Status != health.StatusUp {
+		t.Fatalf("readyz = %d %+v, want 200 and up", status, report)
+	}
+
+	healthChecks.Shutdown()
+
+	if status, report := readyz(); status != http.StatusServiceUnavailable || report.Status != health.StatusDown {
+		t.Fatalf("readyz after shutdown = %d %+v, want 503 and down", status, report)
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"backend/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = 30 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultShutdownTimeout   = 20 * time.Second
+)
+
+// NewServer builds the http server from the config, unset values use the
+// defaults above
+func NewServer(port string, config utils.ServerConfig, handler http.Handler) (*http.Server, error) {
+	if (config.TLS_CERT_FILE == "") != (config.TLS_KEY_FILE == "") {
+		return nil, errors.New("tls needs both the cert and the key file")
+	}
+
+	maxHeaderBytes := config.MAX_HEADER_BYTES
+	if maxHeaderBytes <= 0 {
+		maxHeaderBytes = http.DefaultMaxHeaderBytes
+	}
+
+	return &http.Server{
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadHeaderTimeout: durationOrDefault(config.READ_HEADER_TIMEOUT, defaultReadHeaderTimeout),
+		ReadTimeout:       durationOrDefault(config.READ_TIMEOUT, defaultReadTimeout),
+		WriteTimeout:      durationOrDefault(config.WRITE_TIMEOUT, defaultWriteTimeout),
+		IdleTimeout:       durationOrDefault(config.IDLE_TIMEOUT, defaultIdleTimeout),
+		MaxHeaderBytes:    maxHeaderBytes,
+	}, nil
+}
+
+// ListenAndServe serves until ctx is cancelled, then it calls onShutdown,
+// keeps serving for the shutdown delay so load balancers notice, stops
+// accepting connections and waits up to the shutdown timeout for running
+// requests
+func ListenAndServe(ctx context.Context, server *http.Server, config utils.ServerConfig, onShutdown func()) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.InfoContext(ctx, "starting server", slog.String("address", server.Addr), slog.Bool("tls", config.TLS_CERT_FILE != ""))
+		if config.TLS_CERT_FILE != "" {
+			serveErr <- server.ListenAndServeTLS(config.TLS_CERT_FILE, config.TLS_KEY_FILE)
+		} else {
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownTimeout := durationOrDefault(config.SHUTDOWN_TIMEOUT, defaultShutdownTimeout)
+	slog.InfoContext(ctx, "shutting down server", slog.Duration("timeout", shutdownTimeout))
+
+	if onShutdown != nil {
+		onShutdown()
+	}
+	if config.SHUTDOWN_DELAY > 0 {
+		time.Sleep(config.SHUTDOWN_DELAY * time.Second)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+
+	err := server.Shutdown(shutdownCtx)
+	if err != nil {
+		// the requests still running are cut off
+		server.Close()
+		return fmt.Errorf("could not finish running requests: %w", err)
+	}
+
+	if err = <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	slog.InfoContext(ctx, "server stopped")
+	return nil
+}
+
+func durationOrDefault(seconds time.Duration, defaultDuration time.Duration) time.Duration {
+	if seconds <= 0 {
+		return defaultDuration
+	}
+	return seconds * time.Second
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"backend/utils"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// slowServer serves a handler that blocks until release is closed
+type slowServer struct {
+	server  *http.Server
+	addr    string
+	started chan struct{}
+	release chan struct{}
+}
+
+func newSlowServer(t *testing.T) *slowServer {
+	t.Helper()
+
+	// ListenAndServe listens itself, so take a free port and hand it over
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+
+	s := &slowServer{
+		addr:    "127.0.0.1:" + port,
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(s.started)
+		<-s.release
+		io.WriteString(w, "done")
+	})
+
+	s.server, err = NewServer(port, utils.ServerConfig{}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.server.Addr = s.addr
+	return s
+}
+
+// serve runs ListenAndServe and returns its result on the channel
+func (s *slowServer) serve(ctx context.Context, config utils.ServerConfig, onShutdown func()) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- ListenAndServe(ctx, s.server, config, onShutdown)
+	}()
+	return result
+}
+
+type response struct {
+	status int
+	body   string
+	err    error
+}
+
+// get sends a request once the server accepts connections
+func (s *slowServer) get(t *testing.T) <-chan response {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", s.addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	result := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + s.addr)
+		if err != nil {
+			result <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		result <- response{status: resp.StatusCode, body: string(body), err: err}
+	}()
+	return result
+}
+
+// waitUntilClosed waits until the server stops accepting connections
+func (s *slowServer) waitUntilClosed(t *testing.T) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		conn, err := net.Dial("tcp", s.addr)
+		if err != nil {
+			return
+		}
+		conn.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("server still accepts connections")
+}
+
+func TestListenAndServeFinishesRunningRequests(t *testing.T) {
+	s := newSlowServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shutdown := make(chan struct{})
+	served := s.serve(ctx, utils.ServerConfig{SHUTDOWN_TIMEOUT: 5}, func() { close(shutdown) })
+	responses := s.get(t)
+	<-s.started
+
+	cancel()
+	<-shutdown
+	s.waitUntilClosed(t)
+
+	select {
+	case err := <-served:
+		t.Fatalf("returned with %v before the running request finished", err)
+	default:
+	}
+
+	close(s.release)
+
+	resp := <-responses
+	if resp.err != nil {
+		t.Fatalf("running request failed: %v", resp.err)
+	}
+	if resp.status != http.StatusOK || resp.body != "done" {
+		t.Errorf("response = %d %q, want 200 \"done\"", resp.status, resp.body)
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("ListenAndServe = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenAndServe did not return")
+	}
+}
+
+func TestListenAndServeCutsOffRequestsAfterShutdownTimeout(t *testing.T) {
+	s := newSlowServer(t)
+	defer close(s.release)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	served := s.serve(ctx, utils.ServerConfig{SHUTDOWN_TIMEOUT: 1}, nil)
+	responses := s.get(t)
+	<-s.started
+
+	cancel()
+
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Fatal("ListenAndServe = nil, want an error for the cut off request")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenAndServe did not return after the shutdown timeout")
+	}
+
+	if resp := <-responses; resp.err == nil {
+		t.Errorf("cut off request got %d %q, want an error", resp.status, resp.body)
+	}
+}
@@ -0,0 +1,44 @@
+package v1
+
+import (
+	"backend/api/apiUtils"
+	"backend/dto"
+	platformService "backend/service/platform"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailLinkHandler interface {
+	RequestLogin() gin.HandlerFunc
+}
+
+type emailLinkHandler struct {
+	service platformService.EmailLinkService
+}
+
+func NewEmailLinkHandler(service platformService.EmailLinkService) EmailLinkHandler {
+	return &emailLinkHandler{
+		service: service,
+	}
+}
+
+func (h *emailLinkHandler) RequestLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var emailLinkRequest dto.EmailLinkRequest
+
+		err := c.ShouldBind(&emailLinkRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(err))
+			return
+		}
+
+		err = h.service.RequestLogin(c.Request.Context(), &emailLinkRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.Status(http.StatusAccepted)
+	}
+}
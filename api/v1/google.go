@@ -0,0 +1,35 @@
+package v1
+
+import (
+	"backend/api/apiUtils"
+	platformService "backend/service/platform"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GoogleHandler interface {
+	StartAuthorization() gin.HandlerFunc
+}
+
+type googleHandler struct {
+	service platformService.GoogleService
+}
+
+func NewGoogleHandler(service platformService.GoogleService) GoogleHandler {
+	return &googleHandler{
+		service: service,
+	}
+}
+
+func (h *googleHandler) StartAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response, err := h.service.StartAuthorization(c.Request.Context())
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
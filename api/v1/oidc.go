@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"backend/api/apiUtils"
+	"backend/dto"
+	platformService "backend/service/platform"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCHandler interface {
+	StartAuthorization() gin.HandlerFunc
+}
+
+// oidcHandler serves all configured oidc providers, the provider is picked by
+// its key in the path
+type oidcHandler struct {
+	services map[string]platformService.OIDCService
+}
+
+func NewOIDCHandler(services []platformService.OIDCService) OIDCHandler {
+	handler := &oidcHandler{
+		services: make(map[string]platformService.OIDCService, len(services)),
+	}
+	for _, service := range services {
+		handler.services[service.AuthKey()] = service
+	}
+	return handler
+}
+
+func (h *oidcHandler) StartAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service, ok := h.services[c.Param("provider")]
+		if !ok {
+			apiUtils.SendErrorResponse(c, dto.NewErrorWithCode(http.StatusNotFound, dto.ErrCodeNotFound, "oidc provider not found"))
+			return
+		}
+
+		response, err := service.StartAuthorization(c.Request.Context())
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
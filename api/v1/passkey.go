@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"backend/api/apiUtils"
+	platformService "backend/service/platform"
+	"backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasskeyHandler interface {
+	BeginRegistration() gin.HandlerFunc
+	BeginLogin() gin.HandlerFunc
+}
+
+type passkeyHandler struct {
+	service platformService.PasskeyService
+}
+
+func NewPasskeyHandler(service platformService.PasskeyService) PasskeyHandler {
+	return &passkeyHandler{
+		service: service,
+	}
+}
+
+func (h *passkeyHandler) BeginRegistration() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.ParseToInt64OrNotFound(c, "userID")
+		if err != nil {
+			return
+		}
+
+		options, err := h.service.BeginRegistration(apiUtils.GetContextFromGinContext(c), userID)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, options)
+	}
+}
+
+func (h *passkeyHandler) BeginLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		options, err := h.service.BeginLogin(c.Request.Context())
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, options)
+	}
+}
@@ -2,14 +2,10 @@ package v1
 
 import (
 	"backend/api/apiUtils"
-	"backend/api/middleware"
 	"backend/dto"
 	"backend/service"
-	"backend/token"
 	"backend/utils"
-	"context"
 	"errors"
-	"fmt"
 	"log/slog"
 	"net/http"
 
@@ -19,10 +15,26 @@ import (
 type UserHandler interface {
 	CreateUser() gin.HandlerFunc
 	GetUser() gin.HandlerFunc
+	UpdateLocale() gin.HandlerFunc
 	Login() gin.HandlerFunc
 	ConnectAuthPlatform() gin.HandlerFunc
 	UnlinkAuthPlatform() gin.HandlerFunc
 	RefreshToken() gin.HandlerFunc
+	VerifyEmail() gin.HandlerFunc
+	ResendVerificationEmail() gin.HandlerFunc
+	LoginTwoFactor() gin.HandlerFunc
+	EnrollTotp() gin.HandlerFunc
+	ConfirmTotp() gin.HandlerFunc
+	RegenerateRecoveryCodes() gin.HandlerFunc
+	DisableTotp() gin.HandlerFunc
+	ListSessions() gin.HandlerFunc
+	RevokeSession() gin.HandlerFunc
+	Logout() gin.HandlerFunc
+	LogoutAllSessions() gin.HandlerFunc
+	RevokeAccessToken() gin.HandlerFunc
+	RevokeAccessTokens() gin.HandlerFunc
+	RequestPasswordReset() gin.HandlerFunc
+	ConfirmPasswordReset() gin.HandlerFunc
 }
 
 type userHandler struct {
@@ -41,7 +53,7 @@ func (h *userHandler) CreateUser() gin.HandlerFunc {
 
 		err := c.ShouldBind(&createUserRequest)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, apiUtils.ValidatorError(err))
+			apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(err))
 			return
 		}
 
@@ -66,7 +78,7 @@ func (h *userHandler) ConnectAuthPlatform() gin.HandlerFunc {
 
 		err = c.ShouldBind(&connectAuthPlatformRequest)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, apiUtils.ValidatorError(err))
+			apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(err))
 			return
 		}
 
@@ -89,7 +101,7 @@ func (h *userHandler) UnlinkAuthPlatform() gin.HandlerFunc {
 
 		provider := c.Param("provider")
 		if provider == "" {
-			c.JSON(http.StatusBadRequest, apiUtils.ValidatorError(errors.New("provider is required")))
+			apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(errors.New("provider is required")))
 			return
 		}
 
@@ -121,35 +133,262 @@ func (h *userHandler) GetUser() gin.HandlerFunc {
 	}
 }
 
+func (h *userHandler) UpdateLocale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var updateLocaleRequest dto.UpdateLocaleRequest
+
+		userID, err := utils.ParseToInt64OrNotFound(c, "userID")
+		if err != nil {
+			return
+		}
+
+		err = c.ShouldBind(&updateLocaleRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(err))
+			return
+		}
+
+		err = h.service.UpdateLocale(apiUtils.GetContextFromGinContext(c), userID, &updateLocaleRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
 func (h *userHandler) Login() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var loginRequest dto.LoginRequest
 
 		err := c.ShouldBind(&loginRequest)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, apiUtils.ValidatorError(err))
+			apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(err))
 			return
 		}
 
-		loginResponse, err := h.service.Login(c, &loginRequest)
+		loginRequest.Client = apiUtils.GetClientInfo(c)
+
+		loginResponse, challenge, err := h.service.Login(c.Request.Context(), &loginRequest)
 		if err != nil {
 			apiUtils.SendErrorResponse(c, err)
 			return
 		}
 
+		if challenge != nil {
+			c.JSON(http.StatusAccepted, challenge)
+			return
+		}
+
 		c.JSON(http.StatusOK, loginResponse)
 	}
 }
 
 func (h *userHandler) RefreshToken() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		loginResponse, err := h.service.GenerateAccessToken(apiUtils.GetRefreshContextFromGinContext(c), apiUtils.GetClientInfo(c))
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
 
-		newCtx := context.WithValue(
-			c.Request.Context(),
-			middleware.RefreshTokenPayloadKey,
-			c.MustGet(fmt.Sprint(middleware.RefreshTokenPayloadKey)).(*token.RefreshPayload))
+		c.JSON(http.StatusOK, loginResponse)
+	}
+}
 
-		loginResponse, err := h.service.GenerateAccessToken(newCtx)
+func (h *userHandler) VerifyEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var verifyEmailRequest dto.VerifyEmailRequest
+
+		err := c.ShouldBind(&verifyEmailRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(err))
+			return
+		}
+
+		err = h.service.VerifyEmail(c.Request.Context(), &verifyEmailRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *userHandler) ResendVerificationEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.ParseToInt64OrNotFound(c, "userID")
+		if err != nil {
+			return
+		}
+
+		err = h.service.ResendVerificationEmail(apiUtils.GetContextFromGinContext(c), userID)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.Status(http.StatusAccepted)
+	}
+}
+
+func (h *userHandler) RequestPasswordReset() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var passwordResetRequest dto.PasswordResetRequest
+
+		err := c.ShouldBind(&passwordResetRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(err))
+			return
+		}
+
+		err = h.service.RequestPasswordReset(c.Request.Context(), &passwordResetRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.Status(http.StatusAccepted)
+	}
+}
+
+func (h *userHandler) ConfirmPasswordReset() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var confirmPasswordResetRequest dto.ConfirmPasswordResetRequest
+
+		err := c.ShouldBind(&confirmPasswordResetRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(err))
+			return
+		}
+
+		err = h.service.ConfirmPasswordReset(c.Request.Context(), &confirmPasswordResetRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *userHandler) Logout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := h.service.Logout(apiUtils.GetRefreshContextFromGinContext(c))
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *userHandler) LogoutAllSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.ParseToInt64OrNotFound(c, "userID")
+		if err != nil {
+			return
+		}
+
+		err = h.service.LogoutAllSessions(apiUtils.GetContextFromGinContext(c), userID)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *userHandler) ListSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.ParseToInt64OrNotFound(c, "userID")
+		if err != nil {
+			return
+		}
+
+		sessions, err := h.service.ListSessions(apiUtils.GetContextFromGinContext(c), userID)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, sessions)
+	}
+}
+
+func (h *userHandler) RevokeSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.ParseToInt64OrNotFound(c, "userID")
+		if err != nil {
+			return
+		}
+
+		err = h.service.RevokeSession(apiUtils.GetContextFromGinContext(c), userID, c.Param("sessionID"))
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *userHandler) RevokeAccessToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := h.service.RevokeAccessToken(apiUtils.GetContextFromGinContext(c))
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *userHandler) RevokeAccessTokens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.ParseToInt64OrNotFound(c, "userID")
+		if err != nil {
+			return
+		}
+
+		// the body is optional, without it every token issued so far is revoked
+		var revokeTokensRequest dto.RevokeTokensRequest
+		if c.Request.ContentLength != 0 {
+			err = c.ShouldBind(&revokeTokensRequest)
+			if err != nil {
+				apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(err))
+				return
+			}
+		}
+
+		err = h.service.RevokeAccessTokens(apiUtils.GetContextFromGinContext(c), userID, revokeTokensRequest.IssuedBefore)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *userHandler) LoginTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var twoFactorLoginRequest dto.TwoFactorLoginRequest
+
+		err := c.ShouldBind(&twoFactorLoginRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(err))
+			return
+		}
+
+		twoFactorLoginRequest.Client = apiUtils.GetClientInfo(c)
+
+		loginResponse, err := h.service.LoginTwoFactor(c.Request.Context(), &twoFactorLoginRequest)
 		if err != nil {
 			apiUtils.SendErrorResponse(c, err)
 			return
@@ -158,3 +397,95 @@ func (h *userHandler) RefreshToken() gin.HandlerFunc {
 		c.JSON(http.StatusOK, loginResponse)
 	}
 }
+
+func (h *userHandler) EnrollTotp() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := utils.ParseToInt64OrNotFound(c, "userID")
+		if err != nil {
+			return
+		}
+
+		enrollResponse, err := h.service.EnrollTotp(apiUtils.GetContextFromGinContext(c), userID)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, enrollResponse)
+	}
+}
+
+func (h *userHandler) ConfirmTotp() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var totpCodeRequest dto.TotpCodeRequest
+
+		userID, err := utils.ParseToInt64OrNotFound(c, "userID")
+		if err != nil {
+			return
+		}
+
+		err = c.ShouldBind(&totpCodeRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(err))
+			return
+		}
+
+		recoveryCodes, err := h.service.ConfirmTotp(apiUtils.GetContextFromGinContext(c), userID, &totpCodeRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, recoveryCodes)
+	}
+}
+
+func (h *userHandler) RegenerateRecoveryCodes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var totpCodeRequest dto.TotpCodeRequest
+
+		userID, err := utils.ParseToInt64OrNotFound(c, "userID")
+		if err != nil {
+			return
+		}
+
+		err = c.ShouldBind(&totpCodeRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(err))
+			return
+		}
+
+		recoveryCodes, err := h.service.RegenerateRecoveryCodes(apiUtils.GetContextFromGinContext(c), userID, &totpCodeRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, recoveryCodes)
+	}
+}
+
+func (h *userHandler) DisableTotp() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var totpCodeRequest dto.TotpCodeRequest
+
+		userID, err := utils.ParseToInt64OrNotFound(c, "userID")
+		if err != nil {
+			return
+		}
+
+		err = c.ShouldBind(&totpCodeRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, apiUtils.ValidatorError(err))
+			return
+		}
+
+		err = h.service.DisableTotp(apiUtils.GetContextFromGinContext(c), userID, &totpCodeRequest)
+		if err != nil {
+			apiUtils.SendErrorResponse(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"backend/db"
+	"backend/db/migrations"
+	"backend/dto"
+	"backend/health"
+	"backend/i18n"
+	"backend/mailer"
+	"backend/mailer/memory"
+	"backend/mailer/smtp"
+	"backend/revocation"
+	revocationMemory "backend/revocation/memory"
+	"backend/revocation/postgres"
+	"backend/service"
+	platformService "backend/service/platform"
+	"backend/storage"
+	"backend/storage/oci"
+	"backend/token"
+	"backend/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type command func(ctx context.Context, args []string) error
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"serve":   runServe,
+		"migrate": runMigrate,
+		"user": subcommands("user", map[string]command{
+			"create":          runUserCreate,
+			"disable":         runUserDisable,
+			"set-password":    runUserSetPassword,
+			"revoke-sessions": runUserRevokeSessions,
+			"revoke-tokens":   runUserRevokeTokens,
+		}),
+		"token": subcommands("token", map[string]command{
+			"revoke": runTokenRevoke,
+		}),
+		"keys": subcommands("keys", map[string]command{
+			"generate": runKeysGenerate,
+		}),
+		"config": subcommands("config", map[string]command{
+			"check": runConfigCheck,
+		}),
+		"help": func(ctx context.Context, args []string) error {
+			fmt.Fprint(os.Stdout, usage)
+			return nil
+		},
+	}
+}
+
+func subcommands(name string, commands map[string]command) command {
+	return func(ctx context.Context, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("%s needs a subcommand", name)
+		}
+
+		command, ok := commands[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown subcommand %s %s", name, args[0])
+		}
+		return command(ctx, args[1:])
+	}
+}
+
+// newFlagSet adds the flags every command has, the config flag is the
+// directory of app.toml
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := flags.String("config", "config", "directory of the app.toml config")
+	return flags, configPath
+}
+
+func writeJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// writeError writes the error as the problem the api answers with, unlike the
+// api it keeps the message of unexpected errors for the operator
+func writeError(err error) {
+	var dtoErr *dto.Error
+	if !errors.As(err, &dtoErr) {
+		dtoErr = &dto.Error{Reason: []string{err.Error()}}
+	}
+	writeJSON(dto.NewProblem(dtoErr, i18n.DefaultLanguage))
+}
+
+// app holds everything the commands share, it is built the same way for the
+// server and the command line
+type app struct {
+	config           utils.Config
+	pool             *pgxpool.Pool
+	tokenMaker       token.Maker
+	revocations      revocation.Store
+	mailService      mailer.Mailer
+	googleService    platformService.GoogleService
+	passkeyService   platformService.PasskeyService
+	emailLinkService platformService.EmailLinkService
+	oidcServices     []platformService.OIDCService
+	userService      service.UserService
+	storage          storage.Storage
+	health           *health.Health
+	closers          []func()
+}
+
+func loadConfig(path string) (utils.Config, error) {
+	config, err := utils.LoadConfig(path)
+	if err != nil {
+		return config, fmt.Errorf("cannot load config: %w", err)
+	}
+	return config, nil
+}
+
+func newApp(ctx context.Context, config utils.Config) (*app, error) {
+	pool, err := db.Connect(ctx, config.DB_URL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to database: %w", err)
+	}
+
+	a, err := newAppWithPool(config, pool)
+	if err == nil {
+		err = a.addHealthChecks()
+	}
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// addHealthChecks registers the dependencies the readiness checks, object
+// storage is only checked when it is configured
+func (a *app) addHealthChecks() error {
+	a.health = health.New(SERVICE_NAME, CURRENT_VERSION)
+	a.health.Register(health.NewChecker("database", a.pool.Ping))
+
+	migrator, err := migrations.NewMigrator(a.pool)
+	if err != nil {
+		return err
+	}
+	a.health.Register(migrator)
+
+	if a.config.OCI_STORAGE.HOST != "" {
+		a.storage, err = oci.NewOciStorage(a.config.OCI_STORAGE)
+		if err != nil {
+			return fmt.Errorf("cannot create connection to object storage: %w", err)
+		}
+
+		if checker, ok := a.storage.(health.Checker); ok {
+			a.health.Register(checker)
+		}
+	}
+	return nil
+}
+
+// newAppWithPool builds the services, they only use the pool when called so
+// config check passes a nil pool
+func newAppWithPool(config utils.Config, pool *pgxpool.Pool) (*app, error) {
+	tokenMaker, err := newTokenMaker(config)
+	if err != nil {
+		return nil, err
+	}
+
+	revocations, err := newRevocationStore(config, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	mailService, err := newMailer(config)
+	if err != nil {
+		return nil, err
+	}
+
+	googleService := platformService.NewGoogleService(pool, config.GOOGLE)
+	passkeyService, err := platformService.NewPasskeyService(pool, config.PASSKEY)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create passkey service: %w", err)
+	}
+
+	emailLinkService := platformService.NewEmailLinkService(pool, mailService, config.EMAIL_LINK)
+
+	authPlatforms := []platformService.AuthPlatform{googleService, passkeyService, emailLinkService}
+	var oidcServices []platformService.OIDCService
+	for _, providerConfig := range config.OIDC {
+		oidcService, err := platformService.NewOIDCService(pool, providerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create oidc provider %s: %w", providerConfig.KEY, err)
+		}
+
+		for _, platform := range authPlatforms {
+			if platform.AuthKey() == oidcService.AuthKey() {
+				return nil, fmt.Errorf("oidc provider key %s is already used", providerConfig.KEY)
+			}
+		}
+		authPlatforms = append(authPlatforms, oidcService)
+		oidcServices = append(oidcServices, oidcService)
+	}
+
+	return &app{
+		config:           config,
+		pool:             pool,
+		tokenMaker:       tokenMaker,
+		revocations:      revocations,
+		mailService:      mailService,
+		googleService:    googleService,
+		passkeyService:   passkeyService,
+		emailLinkService: emailLinkService,
+		oidcServices:     oidcServices,
+		userService:      service.NewUserService(pool, tokenMaker, revocations, mailService, config, authPlatforms),
+	}, nil
+}
+
+// onClose registers a background worker to stop when the app is closed, the
+// workers are stopped in reverse order before the pool is closed
+func (a *app) onClose(closer func()) {
+	a.closers = append(a.closers, closer)
+}
+
+func (a *app) close() {
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		a.closers[i]()
+	}
+
+	if a.pool != nil {
+		a.pool.Close()
+		slog.Info("database pool closed")
+	}
+}
+
+func newTokenMaker(config utils.Config) (token.Maker, error) {
+	accessAlgorithm, refreshAlgorithm := config.TOKEN.ACCESS_ALGORITHM, config.TOKEN.REFRESH_ALGORITHM
+	newMaker := token.NewJWTMaker
+	switch config.TOKEN.FORMAT {
+	case "", "jwt":
+	case "paseto":
+		accessAlgorithm, refreshAlgorithm = token.AlgorithmV4Public, token.AlgorithmV4Local
+		newMaker = token.NewPasetoMaker
+	default:
+		return nil, fmt.Errorf("unsupported token format %q", config.TOKEN.FORMAT)
+	}
+
+	accessKeys, err := newKeyring(accessAlgorithm, config.TOKEN.ACCESS_SECRET_KEY, config.TOKEN.ACCESS_PUBLIC_KEY, config.TOKEN.ACCESS_KEYS, config.TOKEN.ACCESS_SIGNING_KID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create access keyring: %w", err)
+	}
+
+	refreshKeys, err := newKeyring(refreshAlgorithm, config.TOKEN.REFRESH_SECRET_KEY, config.TOKEN.REFRESH_PUBLIC_KEY, config.TOKEN.REFRESH_KEYS, config.TOKEN.REFRESH_SIGNING_KID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create refresh keyring: %w", err)
+	}
+
+	tokenMaker, err := newMaker(
+		"backend.user",
+		accessKeys,
+		refreshKeys,
+		config.TOKEN.ACCESS_TOKEN_DURATION,
+		config.TOKEN.REFRESH_TOKEN_DURATION,
+		token.Options{
+			Audience: config.TOKEN.AUDIENCE,
+			Leeway:   config.TOKEN.LEEWAY * time.Second,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create token maker: %w", err)
+	}
+	return tokenMaker, nil
+}
+
+// newKeyring puts the single key pair of older configs in front of the keys
+// list, so configs from before key rotation keep working. Configs without an
+// algorithm keep signing with RS256
+func newKeyring(algorithm string, secretKey string, publicKey string, keyConfigs []utils.TokenKeyConfig, signingKID string) (*token.Keyring, error) {
+	if secretKey != "" || publicKey != "" {
+		keyConfigs = append([]utils.TokenKeyConfig{{SECRET_KEY: secretKey, PUBLIC_KEY: publicKey}}, keyConfigs...)
+	}
+
+	if algorithm == "" {
+		algorithm = token.AlgorithmRS256
+	}
+
+	keys := make([]token.Key, len(keyConfigs))
+	for i, keyConfig := range keyConfigs {
+		key, err := token.ParseKey(algorithm, keyConfig.KID, keyConfig.SECRET_KEY, keyConfig.PUBLIC_KEY)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i+1, err)
+		}
+		keys[i] = key
+	}
+	return token.NewKeyring(algorithm, keys, signingKID)
+}
+
+// newRevocationStore keeps the revocations in the database unless the memory
+// driver is configured
+func newRevocationStore(config utils.Config, pool *pgxpool.Pool) (revocation.Store, error) {
+	switch config.TOKEN.REVOCATION_DRIVER {
+	case "", "postgres":
+		return postgres.NewPostgresStore(pool), nil
+	case "memory":
+		return revocationMemory.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown revocation driver %s", config.TOKEN.REVOCATION_DRIVER)
+	}
+}
+
+// newMailer needs the driver to be set, a config without a [mail] section
+// would otherwise drop every mail without anyone noticing
+func newMailer(config utils.Config) (mailer.Mailer, error) {
+	switch config.MAIL.DRIVER {
+	case "smtp":
+		mailService, err := smtp.NewSMTPMailer(config.MAIL)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create smtp mailer: %w", err)
+		}
+		return mailService, nil
+	case "memory":
+		slog.Warn("mails are kept in memory and not delivered, use the smtp driver outside of local development")
+		return memory.NewMemoryMailer(), nil
+	case "":
+		return nil, errors.New("mail driver is required, set it to smtp or memory")
+	default:
+		return nil, fmt.Errorf("unknown mail driver %s", config.MAIL.DRIVER)
+	}
+}
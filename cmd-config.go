@@ -0,0 +1,73 @@
+package main
+
+import (
+	"backend/db"
+	"backend/db/migrations"
+	"context"
+	"errors"
+)
+
+type configCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type configCheckResponse struct {
+	Valid  bool          `json:"valid"`
+	Checks []configCheck `json:"checks"`
+}
+
+// errReported is returned when the command already wrote its failure as json
+var errReported = errors.New("reported")
+
+// runConfigCheck builds every service from the config without starting the
+// server, the database is only contacted with the db flag
+func runConfigCheck(ctx context.Context, args []string) error {
+	flags, configPath := newFlagSet("config check")
+	checkDB := flags.Bool("db", false, "also connect to the database and check the migrations")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	response := configCheckResponse{Valid: true}
+	check := func(name string, err error) bool {
+		result := configCheck{Name: name, OK: err == nil}
+		if err != nil {
+			result.Error = err.Error()
+			response.Valid = false
+		}
+		response.Checks = append(response.Checks, result)
+		return err == nil
+	}
+
+	config, err := loadConfig(*configPath)
+	if check("config", err) {
+		_, err = newAppWithPool(config, nil)
+		check("services", err)
+
+		if *checkDB {
+			pool, err := db.Connect(ctx, config.DB_URL)
+			if err == nil {
+				defer pool.Close()
+				err = pool.Ping(ctx)
+			}
+
+			if check("database", err) {
+				migrator, err := migrations.NewMigrator(pool)
+				if err == nil {
+					err = migrator.Check(ctx)
+				}
+				check("migrations", err)
+			}
+		}
+	}
+
+	if err := writeJSON(response); err != nil {
+		return err
+	}
+	if !response.Valid {
+		return errReported
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"backend/token"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+)
+
+type keysResponse struct {
+	Format           string `json:"format"`
+	AccessAlgorithm  string `json:"accessAlgorithm"`
+	RefreshAlgorithm string `json:"refreshAlgorithm"`
+	AccessKID        string `json:"accessKid"`
+	AccessSecretKey  string `json:"accessSecretKey"`
+	AccessPublicKey  string `json:"accessPublicKey"`
+	RefreshKID       string `json:"refreshKid"`
+	RefreshSecretKey string `json:"refreshSecretKey"`
+	RefreshPublicKey string `json:"refreshPublicKey"`
+}
+
+// runKeysGenerate generates the access and refresh keys in the format the
+// [token] section expects for the algorithm, the kid is the one the keyring
+// gives the key when none is configured
+func runKeysGenerate(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("keys generate", flag.ContinueOnError)
+	format := flags.String("format", "jwt", "jwt or paseto")
+	algorithm := flags.String("algorithm", token.AlgorithmRS256, "RS256, ES256, EdDSA or HS256, ignored for paseto")
+	bits := flags.Int("bits", 2048, "size of the rsa keys")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *bits < 2048 {
+		return errors.New("bits must be at least 2048")
+	}
+
+	response := keysResponse{Format: *format, AccessAlgorithm: *algorithm, RefreshAlgorithm: *algorithm}
+	switch *format {
+	case "jwt":
+	case "paseto":
+		response.AccessAlgorithm, response.RefreshAlgorithm = token.AlgorithmV4Public, token.AlgorithmV4Local
+	default:
+		return fmt.Errorf("unsupported format %q", *format)
+	}
+
+	var err error
+	response.AccessKID, response.AccessSecretKey, response.AccessPublicKey, err = generateKeyPair(response.AccessAlgorithm, *bits)
+	if err != nil {
+		return err
+	}
+
+	response.RefreshKID, response.RefreshSecretKey, response.RefreshPublicKey, err = generateKeyPair(response.RefreshAlgorithm, *bits)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(response)
+}
+
+// generateKeyPair returns the kid, secret key and public key, HS256 and
+// v4.local keys are a random secret without public key
+func generateKeyPair(algorithm string, bits int) (string, string, string, error) {
+	var privateKey crypto.Signer
+	var secret []byte
+	var err error
+	switch algorithm {
+	case token.AlgorithmRS256:
+		var rsaKey *rsa.PrivateKey
+		rsaKey, err = rsa.GenerateKey(rand.Reader, bits)
+		if err == nil {
+			privateKey = rsaKey
+			secret = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+		}
+	case token.AlgorithmES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case token.AlgorithmEdDSA, token.AlgorithmV4Public:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	case token.AlgorithmHS256:
+		key := make([]byte, 32)
+		if _, err = rand.Read(key); err != nil {
+			return "", "", "", err
+		}
+		encoded := base64.RawURLEncoding.EncodeToString(key)
+		return token.Thumbprint([]byte(encoded)), encoded, "", nil
+	case token.AlgorithmV4Local:
+		key := make([]byte, 32)
+		if _, err = rand.Read(key); err != nil {
+			return "", "", "", err
+		}
+		return token.Thumbprint(key), hex.EncodeToString(key), "", nil
+	default:
+		return "", "", "", fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if secret == nil {
+		pkcs8, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return "", "", "", err
+		}
+		secret = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return "", "", "", err
+	}
+
+	public := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+	return token.Thumbprint(privateKey.Public()), string(secret), string(public), nil
+}
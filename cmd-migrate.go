@@ -0,0 +1,119 @@
+package main
+
+import (
+	"backend/db"
+	"backend/db/migrations"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const migrateUsage = "usage: migrate [flags] up | down [N] | status | force VERSION"
+
+// runMigrate only needs the database, so it works with a config that is not
+// complete yet
+func runMigrate(ctx context.Context, args []string) error {
+	flags, configPath := newFlagSet("migrate")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	pool, err := db.Connect(ctx, config.DB_URL)
+	if err != nil {
+		return fmt.Errorf("cannot connect to database: %w", err)
+	}
+	defer pool.Close()
+
+	migrator, err := migrations.NewMigrator(pool)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		return writeJSON(map[string]any{"applied": nonNil(applied)})
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return errors.New("down needs a positive number of migrations")
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, n)
+		if err != nil {
+			return err
+		}
+		return writeJSON(map[string]any{"reverted": nonNil(reverted)})
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		pending := 0
+		for _, status := range statuses {
+			if !status.Applied {
+				pending++
+			}
+		}
+		return writeJSON(map[string]any{"migrations": nonNil(statuses), "pending": pending})
+	case "force":
+		if len(args) < 2 {
+			return errors.New("force needs a version")
+		}
+
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return errors.New("force needs a version")
+		}
+
+		err = migrator.Force(ctx, version)
+		if err != nil {
+			return err
+		}
+		return writeJSON(map[string]any{"version": version})
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// checkMigrations refuses to start the server while migrations are pending
+func checkMigrations(ctx context.Context, pool *pgxpool.Pool) error {
+	migrator, err := migrations.NewMigrator(pool)
+	if err != nil {
+		return err
+	}
+
+	err = migrator.Check(ctx)
+	if err != nil {
+		return fmt.Errorf("%w, run migrate up first", err)
+	}
+	return nil
+}
+
+// nonNil keeps empty lists as [] in the json output
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"backend/api"
+	"backend/api/apiUtils"
+	"backend/api/middleware"
+	"backend/dto"
+	"backend/metrics"
+	"backend/tracing"
+	"backend/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// runServe serves until SIGINT or SIGTERM, then the running requests get to
+// finish before the app is closed
+func runServe(ctx context.Context, args []string) error {
+	flags, configPath := newFlagSet("serve")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, config.TRACING, SERVICE_NAME, CURRENT_VERSION)
+	if err != nil {
+		return err
+	}
+
+	a, err := newApp(ctx, config)
+	if err != nil {
+		shutdownTracing(context.WithoutCancel(ctx))
+		return err
+	}
+	a.onClose(func() {
+		// runs after the server stopped, so the spans of the last requests are exported
+		flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+
+		err := shutdownTracing(flushCtx)
+		if err != nil {
+			slog.WarnContext(ctx, "could not export the remaining spans", slog.Any("error", err))
+		}
+	})
+	defer a.close()
+
+	if config.MIGRATIONS.REQUIRE_UP_TO_DATE {
+		err = checkMigrations(ctx, a.pool)
+		if err != nil {
+			return fmt.Errorf("database is not up to date: %w", err)
+		}
+	}
+
+	err = apiUtils.AddCustomValidator(binding.Validator.Engine())
+	if err != nil {
+		return fmt.Errorf("could not add validator: %w", err)
+	}
+
+	r := gin.New()
+	// handlers that pass c as the context reach the values of the request context
+	r.ContextWithFallback = true
+	r.Use(gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		middleware.AbortWithProblem(c, fmt.Errorf("panic: %v", recovered))
+	}))
+	r.NoRoute(func(c *gin.Context) {
+		middleware.AbortWithProblem(c, dto.NewErrorWithStatus(http.StatusNotFound, "route not found"))
+	})
+	r.Use(otelgin.Middleware(SERVICE_NAME))
+	r.Use(middleware.RequestIDMiddleware(), middleware.AccessLogMiddleware(), middleware.LocaleMiddleware())
+	if config.METRICS.ENABLED {
+		err = metrics.RegisterPool(a.pool)
+		if err != nil {
+			return fmt.Errorf("could not register pool metrics: %w", err)
+		}
+
+		r.Use(metrics.Middleware())
+		if config.METRICS.PORT == "" {
+			r.GET("/metrics", gin.WrapH(metrics.Handler()))
+		}
+	}
+	r.Use(middleware.CORSMiddleware(config.CORS))
+	// r.Use(func(ctx *gin.Context) { time.Sleep(500 * time.Millisecond); ctx.Next() })
+	api.RegisterPath(&r.RouterGroup, config, SERVICE_NAME, CURRENT_VERSION, a.tokenMaker, a.revocations, a.userService, a.passkeyService, a.emailLinkService, a.googleService, a.oidcServices, a.health)
+
+	server, err := api.NewServer(config.PORT, config.SERVER, r)
+	if err != nil {
+		return err
+	}
+
+	if config.METRICS.ENABLED && config.METRICS.PORT != "" {
+		metricsErr, err := serveMetrics(ctx, config.METRICS.PORT)
+		if err != nil {
+			return err
+		}
+
+		err = api.ListenAndServe(ctx, server, config.SERVER, a.health.Shutdown)
+		stop()
+		return errors.Join(err, <-metricsErr)
+	}
+
+	return api.ListenAndServe(ctx, server, config.SERVER, a.health.Shutdown)
+}
+
+// serveMetrics serves /metrics on the admin port until ctx is cancelled, it
+// is not reachable through the api so it does not need authentication
+func serveMetrics(ctx context.Context, port string) (<-chan error, error) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	server, err := api.NewServer(port, utils.ServerConfig{}, mux)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		err := api.ListenAndServe(ctx, server, utils.ServerConfig{}, nil)
+		if err != nil {
+			err = fmt.Errorf("metrics server: %w", err)
+		}
+		done <- err
+	}()
+	return done, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"backend/utils"
+	"context"
+	"errors"
+)
+
+func runTokenRevoke(ctx context.Context, args []string) error {
+	flags, configPath := newFlagSet("token revoke")
+	tokenID := flags.String("id", "", "id of the access token")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *tokenID == "" {
+		return errors.New("id is required")
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if err = checkRevocationDriver(config); err != nil {
+		return err
+	}
+
+	a, err := newApp(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	response, err := a.userService.AdminRevokeAccessToken(ctx, *tokenID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(response)
+}
+
+// checkRevocationDriver refuses to revoke tokens in memory, the server would
+// never see the revocations of the command line
+func checkRevocationDriver(config utils.Config) error {
+	if config.TOKEN.REVOCATION_DRIVER == "memory" {
+		return errors.New("the memory revocation driver is not shared with the server, use the postgres driver or the api")
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"backend/dto"
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// userFlags selects the user of a user command by id or email
+type userFlags struct {
+	flags      *flag.FlagSet
+	configPath *string
+	id         *int64
+	email      *string
+}
+
+func newUserFlagSet(name string) *userFlags {
+	flags, configPath := newFlagSet(name)
+	return &userFlags{
+		flags:      flags,
+		configPath: configPath,
+		id:         flags.Int64("id", 0, "id of the user"),
+		email:      flags.String("email", "", "email of the user, used when id is not set"),
+	}
+}
+
+// open parses the flags and builds the app, the caller closes the app
+func (u *userFlags) open(ctx context.Context, args []string) (*app, int64, error) {
+	if err := u.parse(args); err != nil {
+		return nil, 0, err
+	}
+	return u.openParsed(ctx)
+}
+
+// parse checks the flags without touching the config or database, so usage
+// errors are reported even when the database is not reachable
+func (u *userFlags) parse(args []string) error {
+	if err := u.flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *u.id == 0 && *u.email == "" {
+		return errors.New("id or email is required")
+	}
+	return nil
+}
+
+// openParsed builds the app for flags that were already parsed
+func (u *userFlags) openParsed(ctx context.Context) (*app, int64, error) {
+	config, err := loadConfig(*u.configPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	a, err := newApp(ctx, config)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	userID := *u.id
+	if userID == 0 {
+		userID, err = a.userService.AdminFindUser(ctx, *u.email)
+		if err != nil {
+			a.close()
+			return nil, 0, err
+		}
+	}
+
+	return a, userID, nil
+}
+
+// readPassword reads the password from the first line of stdin, so it does
+// not show up in the process list
+func readPassword() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("could not read password from stdin")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func runUserCreate(ctx context.Context, args []string) error {
+	flags, configPath := newFlagSet("user create")
+	name := flags.String("name", "", "name of the user")
+	email := flags.String("email", "", "email of the user")
+	picture := flags.String("picture", "", "picture url of the user")
+	emailVerified := flags.Bool("email-verified", false, "mark the email as verified instead of sending a verification mail")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from stdin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if !*passwordStdin {
+		return errors.New("password-stdin is required")
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	request := &dto.AdminCreateUserRequest{
+		CreateUserPayloadNormal: dto.CreateUserPayloadNormal{
+			Name:     *name,
+			Email:    *email,
+			Password: password,
+		},
+		EmailVerified: *emailVerified,
+	}
+	if *picture != "" {
+		request.Picture = picture
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	a, err := newApp(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	response, err := a.userService.AdminCreateUser(ctx, request)
+	if err != nil {
+		return err
+	}
+	return writeJSON(response)
+}
+
+func runUserDisable(ctx context.Context, args []string) error {
+	a, userID, err := newUserFlagSet("user disable").open(ctx, args)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	response, err := a.userService.AdminDisableUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(response)
+}
+
+func runUserSetPassword(ctx context.Context, args []string) error {
+	userFlags := newUserFlagSet("user set-password")
+	passwordStdin := userFlags.flags.Bool("password-stdin", false, "read the password from stdin")
+	if err := userFlags.parse(args); err != nil {
+		return err
+	}
+
+	if !*passwordStdin {
+		return errors.New("password-stdin is required")
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	a, userID, err := userFlags.openParsed(ctx)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	response, err := a.userService.AdminSetPassword(ctx, userID, password)
+	if err != nil {
+		return err
+	}
+	return writeJSON(response)
+}
+
+func runUserRevokeSessions(ctx context.Context, args []string) error {
+	a, userID, err := newUserFlagSet("user revoke-sessions").open(ctx, args)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	response, err := a.userService.AdminRevokeSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(response)
+}
+
+func runUserRevokeTokens(ctx context.Context, args []string) error {
+	userFlags := newUserFlagSet("user revoke-tokens")
+	before := userFlags.flags.String("before", "", "revoke the tokens issued before this RFC 3339 time, defaults to now")
+
+	a, userID, err := userFlags.open(ctx, args)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	if err = checkRevocationDriver(a.config); err != nil {
+		return err
+	}
+
+	var issuedBefore time.Time
+	if *before != "" {
+		issuedBefore, err = time.Parse(time.RFC3339, *before)
+		if err != nil {
+			return fmt.Errorf("before must be an RFC 3339 time: %w", err)
+		}
+	}
+
+	response, err := a.userService.AdminRevokeAccessTokens(ctx, userID, issuedBefore)
+	if err != nil {
+		return err
+	}
+	return writeJSON(response)
+}
@@ -28,6 +28,7 @@ func Config(dsn string) *pgxpool.Config {
 	dbConfig.MaxConnIdleTime = defaultMaxConnIdleTime
 	dbConfig.HealthCheckPeriod = defaultHealthCheckPeriod
 	dbConfig.ConnConfig.ConnectTimeout = defaultConnectTimeout
+	dbConfig.ConnConfig.Tracer = queryTracer{}
 
 	dbConfig.BeforeAcquire = func(ctx context.Context, c *pgx.Conn) bool {
 		slog.Info("Before acquiring the connection pool to the database!!")
@@ -0,0 +1,78 @@
+// Package dbtest gives tests a migrated database, the tests that need one are
+// skipped unless TEST_DB_URL points to a postgres database they may use
+package dbtest
+
+import (
+	"backend/db/migrations"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const urlEnv = "TEST_DB_URL"
+
+// NewPool returns a pool on a schema of its own with all migrations applied,
+// the schema is dropped when the test ends so tests can run in parallel
+func NewPool(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv(urlEnv)
+	if url == "" {
+		t.Skipf("%s is not set", urlEnv)
+	}
+
+	ctx := context.Background()
+	schema := "test_" + randomHex(t)
+
+	admin, err := pgx.Connect(ctx, url)
+	if err != nil {
+		t.Fatalf("could not connect to the test database: %v", err)
+	}
+	t.Cleanup(func() { admin.Close(context.Background()) })
+
+	_, err = admin.Exec(ctx, "CREATE SCHEMA "+schema)
+	if err != nil {
+		t.Fatalf("could not create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		_, err := admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		if err != nil {
+			t.Errorf("could not drop schema %s: %v", schema, err)
+		}
+	})
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("could not parse %s: %v", urlEnv, err)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("could not connect to the test database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	migrator, err := migrations.NewMigrator(pool)
+	if err != nil {
+		t.Fatalf("could not load migrations: %v", err)
+	}
+	if _, err = migrator.Up(ctx); err != nil {
+		t.Fatalf("could not migrate: %v", err)
+	}
+
+	return pool
+}
+
+func randomHex(t testing.TB) string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("could not generate schema name: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
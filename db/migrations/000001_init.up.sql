@@ -11,4 +11,4 @@ CREATE TABLE users (
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
     deleted_at TIMESTAMP WITH TIME ZONE,
     CONSTRAINT unique_email UNIQUE (email)
-);
\ No newline at end of file
+);
@@ -0,0 +1 @@
+DROP TABLE email_login_tokens;
@@ -0,0 +1,13 @@
+CREATE TABLE email_login_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT unique_email_login_token_hash UNIQUE (token_hash)
+);
+
+CREATE INDEX email_login_tokens_email_created_at_idx ON email_login_tokens (email, created_at);
@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN auth_providers TEXT[] NOT NULL DEFAULT ARRAY['normal'];
+
+UPDATE users SET auth_providers = identities.providers
+FROM (
+  SELECT user_id, array_agg(provider ORDER BY created_at) AS providers
+  FROM user_identities GROUP BY user_id
+) AS identities
+WHERE users.id = identities.user_id;
+
+DROP TABLE user_identities;
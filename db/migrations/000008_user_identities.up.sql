@@ -0,0 +1,28 @@
+-- google and oidc accounts only have their email stored, they get a
+-- "legacy:<email>" subject that is replaced with the provider subject on the
+-- first login
+CREATE TABLE user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(1024) NOT NULL,
+    email VARCHAR(1024) NOT NULL,
+    profile JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT unique_user_identity UNIQUE (provider, subject),
+    CONSTRAINT unique_user_identity_provider UNIQUE (user_id, provider)
+);
+
+INSERT INTO user_identities (user_id, provider, subject, email, profile, created_at, updated_at)
+SELECT users.id, providers.provider,
+  CASE
+    WHEN providers.provider IN ('normal', 'email-link') THEN users.email
+    WHEN providers.provider = 'passkey' THEN users.id::text
+    ELSE 'legacy:' || users.email
+  END,
+  users.email, '{}', users.created_at, users.updated_at
+FROM users CROSS JOIN LATERAL unnest(users.auth_providers) AS providers(provider)
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users DROP COLUMN auth_providers;
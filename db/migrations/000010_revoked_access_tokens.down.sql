@@ -0,0 +1,2 @@
+DROP TABLE revoked_user_access_tokens;
+DROP TABLE revoked_access_tokens;
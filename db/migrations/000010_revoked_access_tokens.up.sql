@@ -0,0 +1,18 @@
+-- access tokens revoked before they expire, the rows are only needed until
+-- the token expires
+CREATE TABLE revoked_access_tokens (
+    token_id UUID PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX revoked_access_tokens_expires_at_idx ON revoked_access_tokens (expires_at);
+
+-- every access token of the user issued before issued_before is revoked, the
+-- row is only needed until the last of those tokens expires
+CREATE TABLE revoked_user_access_tokens (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    issued_before TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
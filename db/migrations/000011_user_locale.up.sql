@@ -0,0 +1,3 @@
+-- the language the user picked, it is copied into the access tokens so the
+-- error messages use it without a lookup per request
+ALTER TABLE users ADD COLUMN locale VARCHAR(35) NULL;
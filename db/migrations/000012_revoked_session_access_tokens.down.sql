@@ -0,0 +1 @@
+DROP TABLE revoked_session_access_tokens;
@@ -0,0 +1,9 @@
+-- every access token of the session is revoked, the row is only needed until
+-- the last token issued for the session expires
+CREATE TABLE revoked_session_access_tokens (
+    session_id UUID PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX revoked_session_access_tokens_expires_at_idx ON revoked_session_access_tokens (expires_at);
@@ -0,0 +1,5 @@
+-- wrong totp and recovery codes are counted per user, so new login challenges
+-- or a stolen access token do not give more guesses, no code is accepted until
+-- totp_locked_until once too many were wrong
+ALTER TABLE users ADD COLUMN totp_failed_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_locked_until TIMESTAMP WITH TIME ZONE NULL;
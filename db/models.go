@@ -8,16 +8,129 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type EmailLoginToken struct {
+	ID        int64
+	Email     string
+	TokenHash string
+	CodeHash  string
+	Attempts  int32
+	ExpiresAt pgtype.Timestamptz
+	UsedAt    pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+type OauthState struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	ExpiresAt    pgtype.Timestamptz
+	CreatedAt    pgtype.Timestamptz
+}
+
+type PasskeyCredential struct {
+	ID              int64
+	UserID          int64
+	CredentialID    []byte
+	PublicKey       []byte
+	AttestationType string
+	Aaguid          []byte
+	SignCount       int64
+	Transports      []string
+	Flags           int16
+	CreatedAt       pgtype.Timestamptz
+	LastUsedAt      pgtype.Timestamptz
+}
+
+type RecoveryCode struct {
+	ID        int64
+	UserID    int64
+	CodeHash  string
+	UsedAt    pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+type RevokedAccessToken struct {
+	TokenID   pgtype.UUID
+	ExpiresAt pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+type RevokedSessionAccessToken struct {
+	SessionID pgtype.UUID
+	ExpiresAt pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+type RevokedUserAccessToken struct {
+	UserID       int64
+	IssuedBefore pgtype.Timestamptz
+	ExpiresAt    pgtype.Timestamptz
+	CreatedAt    pgtype.Timestamptz
+}
+
+type Session struct {
+	ID             pgtype.UUID
+	UserID         int64
+	DeviceName     string
+	IpAddress      string
+	UserAgent      string
+	Secret         string
+	RefreshTokenID pgtype.UUID
+	CreatedAt      pgtype.Timestamptz
+	LastUsedAt     pgtype.Timestamptz
+	ExpiresAt      pgtype.Timestamptz
+	RevokedAt      pgtype.Timestamptz
+}
+
 type User struct {
-	ID            int64
-	Name          string
-	Email         string
-	Password      string
-	Picture       *string
-	EmailVerified bool
-	AuthProviders []string
-	TokenHash     string
-	CreatedAt     pgtype.Timestamptz
-	UpdatedAt     pgtype.Timestamptz
-	DeletedAt     pgtype.Timestamptz
+	ID                 int64
+	Name               string
+	Email              string
+	Password           string
+	Picture            *string
+	EmailVerified      bool
+	TokenHash          string
+	CreatedAt          pgtype.Timestamptz
+	UpdatedAt          pgtype.Timestamptz
+	DeletedAt          pgtype.Timestamptz
+	TotpSecret         *string
+	TotpEnabled        bool
+	TotpLastCounter    int64
+	DisabledAt         pgtype.Timestamptz
+	Locale             *string
+	TotpFailedAttempts int32
+	TotpLockedUntil    pgtype.Timestamptz
+}
+
+type UserIdentity struct {
+	ID        int64
+	UserID    int64
+	Provider  string
+	Subject   string
+	Email     string
+	Profile   []byte
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
+}
+
+type UserToken struct {
+	ID        int64
+	UserID    int64
+	Email     string
+	Purpose   string
+	TokenHash string
+	ExpiresAt pgtype.Timestamptz
+	UsedAt    pgtype.Timestamptz
+	Attempts  int32
+	CreatedAt pgtype.Timestamptz
+}
+
+type WebauthnChallenge struct {
+	ID          pgtype.UUID
+	UserID      *int64
+	Ceremony    string
+	SessionData []byte
+	ExpiresAt   pgtype.Timestamptz
+	CreatedAt   pgtype.Timestamptz
 }
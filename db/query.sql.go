@@ -11,64 +11,634 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const connectAuthPlatform = `-- name: ConnectAuthPlatform :one
-UPDATE users SET auth_providers = array_append(auth_providers, $1) WHERE id = $2 AND email = $3 AND deleted_at IS NULL AND array_position(auth_providers, $1) IS NULL
-RETURNING id, auth_providers
+const claimLegacyUserIdentity = `-- name: ClaimLegacyUserIdentity :one
+UPDATE user_identities SET subject = $1, updated_at = $2
+WHERE provider = $3 AND subject = 'legacy:' || $4::text
+RETURNING id, user_id, email
 `
 
-type ConnectAuthPlatformParams struct {
-	ArrayAppend interface{}
-	ID          int64
-	Email       string
+type ClaimLegacyUserIdentityParams struct {
+	Subject   string
+	UpdatedAt pgtype.Timestamptz
+	Provider  string
+	Email     string
 }
 
-type ConnectAuthPlatformRow struct {
-	ID            int64
-	AuthProviders []string
+type ClaimLegacyUserIdentityRow struct {
+	ID     int64
+	UserID int64
+	Email  string
+}
+
+func (q *Queries) ClaimLegacyUserIdentity(ctx context.Context, arg ClaimLegacyUserIdentityParams) (ClaimLegacyUserIdentityRow, error) {
+	row := q.db.QueryRow(ctx, claimLegacyUserIdentity,
+		arg.Subject,
+		arg.UpdatedAt,
+		arg.Provider,
+		arg.Email,
+	)
+	var i ClaimLegacyUserIdentityRow
+	err := row.Scan(&i.ID, &i.UserID, &i.Email)
+	return i, err
+}
+
+const consumeEmailLoginToken = `-- name: ConsumeEmailLoginToken :execrows
+UPDATE email_login_tokens SET used_at = $3 WHERE token_hash = $1 AND email = $2 AND used_at IS NULL AND expires_at > $3
+`
+
+type ConsumeEmailLoginTokenParams struct {
+	TokenHash string
+	Email     string
+	UsedAt    pgtype.Timestamptz
+}
+
+func (q *Queries) ConsumeEmailLoginToken(ctx context.Context, arg ConsumeEmailLoginTokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, consumeEmailLoginToken, arg.TokenHash, arg.Email, arg.UsedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const consumeOauthState = `-- name: ConsumeOauthState :one
+DELETE FROM oauth_states WHERE state_hash = $1 AND provider = $2 AND expires_at > $3
+RETURNING code_verifier, nonce
+`
+
+type ConsumeOauthStateParams struct {
+	StateHash string
+	Provider  string
+	ExpiresAt pgtype.Timestamptz
+}
+
+type ConsumeOauthStateRow struct {
+	CodeVerifier string
+	Nonce        string
+}
+
+func (q *Queries) ConsumeOauthState(ctx context.Context, arg ConsumeOauthStateParams) (ConsumeOauthStateRow, error) {
+	row := q.db.QueryRow(ctx, consumeOauthState, arg.StateHash, arg.Provider, arg.ExpiresAt)
+	var i ConsumeOauthStateRow
+	err := row.Scan(&i.CodeVerifier, &i.Nonce)
+	return i, err
+}
+
+const consumeUserToken = `-- name: ConsumeUserToken :one
+UPDATE user_tokens SET used_at = $3 WHERE purpose = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > $3
+RETURNING user_id, email
+`
+
+type ConsumeUserTokenParams struct {
+	Purpose   string
+	TokenHash string
+	UsedAt    pgtype.Timestamptz
+}
+
+type ConsumeUserTokenRow struct {
+	UserID int64
+	Email  string
+}
+
+func (q *Queries) ConsumeUserToken(ctx context.Context, arg ConsumeUserTokenParams) (ConsumeUserTokenRow, error) {
+	row := q.db.QueryRow(ctx, consumeUserToken, arg.Purpose, arg.TokenHash, arg.UsedAt)
+	var i ConsumeUserTokenRow
+	err := row.Scan(&i.UserID, &i.Email)
+	return i, err
 }
 
-func (q *Queries) ConnectAuthPlatform(ctx context.Context, arg ConnectAuthPlatformParams) (ConnectAuthPlatformRow, error) {
-	row := q.db.QueryRow(ctx, connectAuthPlatform, arg.ArrayAppend, arg.ID, arg.Email)
-	var i ConnectAuthPlatformRow
-	err := row.Scan(&i.ID, &i.AuthProviders)
+const consumeWebauthnChallenge = `-- name: ConsumeWebauthnChallenge :one
+DELETE FROM webauthn_challenges WHERE id = $1 AND ceremony = $2 AND expires_at > $3
+RETURNING user_id, session_data
+`
+
+type ConsumeWebauthnChallengeParams struct {
+	ID        pgtype.UUID
+	Ceremony  string
+	ExpiresAt pgtype.Timestamptz
+}
+
+type ConsumeWebauthnChallengeRow struct {
+	UserID      *int64
+	SessionData []byte
+}
+
+func (q *Queries) ConsumeWebauthnChallenge(ctx context.Context, arg ConsumeWebauthnChallengeParams) (ConsumeWebauthnChallengeRow, error) {
+	row := q.db.QueryRow(ctx, consumeWebauthnChallenge, arg.ID, arg.Ceremony, arg.ExpiresAt)
+	var i ConsumeWebauthnChallengeRow
+	err := row.Scan(&i.UserID, &i.SessionData)
 	return i, err
 }
 
-const createUser = `-- name: CreateUser :exec
+const createEmailLoginToken = `-- name: CreateEmailLoginToken :exec
+INSERT INTO email_login_tokens (
+  email, token_hash, code_hash, expires_at, created_at
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+`
+
+type CreateEmailLoginTokenParams struct {
+	Email     string
+	TokenHash string
+	CodeHash  string
+	ExpiresAt pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreateEmailLoginToken(ctx context.Context, arg CreateEmailLoginTokenParams) error {
+	_, err := q.db.Exec(ctx, createEmailLoginToken,
+		arg.Email,
+		arg.TokenHash,
+		arg.CodeHash,
+		arg.ExpiresAt,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const createOauthState = `-- name: CreateOauthState :exec
+INSERT INTO oauth_states (
+  state_hash, provider, code_verifier, nonce, expires_at, created_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+`
+
+type CreateOauthStateParams struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	ExpiresAt    pgtype.Timestamptz
+	CreatedAt    pgtype.Timestamptz
+}
+
+func (q *Queries) CreateOauthState(ctx context.Context, arg CreateOauthStateParams) error {
+	_, err := q.db.Exec(ctx, createOauthState,
+		arg.StateHash,
+		arg.Provider,
+		arg.CodeVerifier,
+		arg.Nonce,
+		arg.ExpiresAt,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const createPasskeyCredential = `-- name: CreatePasskeyCredential :exec
+INSERT INTO passkey_credentials (
+  user_id, credential_id, public_key, attestation_type, aaguid, sign_count, transports, flags, created_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+`
+
+type CreatePasskeyCredentialParams struct {
+	UserID          int64
+	CredentialID    []byte
+	PublicKey       []byte
+	AttestationType string
+	Aaguid          []byte
+	SignCount       int64
+	Transports      []string
+	Flags           int16
+	CreatedAt       pgtype.Timestamptz
+}
+
+func (q *Queries) CreatePasskeyCredential(ctx context.Context, arg CreatePasskeyCredentialParams) error {
+	_, err := q.db.Exec(ctx, createPasskeyCredential,
+		arg.UserID,
+		arg.CredentialID,
+		arg.PublicKey,
+		arg.AttestationType,
+		arg.Aaguid,
+		arg.SignCount,
+		arg.Transports,
+		arg.Flags,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const createRecoveryCode = `-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (
+  user_id, code_hash, created_at
+) VALUES (
+  $1, $2, $3
+)
+`
+
+type CreateRecoveryCodeParams struct {
+	UserID    int64
+	CodeHash  string
+	CreatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error {
+	_, err := q.db.Exec(ctx, createRecoveryCode, arg.UserID, arg.CodeHash, arg.CreatedAt)
+	return err
+}
+
+const createSession = `-- name: CreateSession :exec
+INSERT INTO sessions (
+  id, user_id, device_name, ip_address, user_agent, secret, refresh_token_id, created_at, last_used_at, expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+`
+
+type CreateSessionParams struct {
+	ID             pgtype.UUID
+	UserID         int64
+	DeviceName     string
+	IpAddress      string
+	UserAgent      string
+	Secret         string
+	RefreshTokenID pgtype.UUID
+	CreatedAt      pgtype.Timestamptz
+	LastUsedAt     pgtype.Timestamptz
+	ExpiresAt      pgtype.Timestamptz
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) error {
+	_, err := q.db.Exec(ctx, createSession,
+		arg.ID,
+		arg.UserID,
+		arg.DeviceName,
+		arg.IpAddress,
+		arg.UserAgent,
+		arg.Secret,
+		arg.RefreshTokenID,
+		arg.CreatedAt,
+		arg.LastUsedAt,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const createUser = `-- name: CreateUser :one
 INSERT INTO users (
-  name, email, password, auth_providers, picture, token_hash, created_at, updated_at
+  name, email, password, picture, email_verified, token_hash, created_at, updated_at
 ) VALUES (
   $1, $2, $3, $4, $5, $6, $7, $8
 )
+RETURNING id
 `
 
 type CreateUserParams struct {
 	Name          string
 	Email         string
 	Password      string
-	AuthProviders []string
 	Picture       *string
+	EmailVerified bool
 	TokenHash     string
 	CreatedAt     pgtype.Timestamptz
 	UpdatedAt     pgtype.Timestamptz
 }
 
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) error {
-	_, err := q.db.Exec(ctx, createUser,
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (int64, error) {
+	row := q.db.QueryRow(ctx, createUser,
 		arg.Name,
 		arg.Email,
 		arg.Password,
-		arg.AuthProviders,
 		arg.Picture,
+		arg.EmailVerified,
 		arg.TokenHash,
 		arg.CreatedAt,
 		arg.UpdatedAt,
 	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const createUserIdentity = `-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (
+  user_id, provider, subject, email, profile, created_at, updated_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+`
+
+type CreateUserIdentityParams struct {
+	UserID    int64
+	Provider  string
+	Subject   string
+	Email     string
+	Profile   []byte
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) error {
+	_, err := q.db.Exec(ctx, createUserIdentity,
+		arg.UserID,
+		arg.Provider,
+		arg.Subject,
+		arg.Email,
+		arg.Profile,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const createUserToken = `-- name: CreateUserToken :exec
+INSERT INTO user_tokens (
+  user_id, email, purpose, token_hash, expires_at, created_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+`
+
+type CreateUserTokenParams struct {
+	UserID    int64
+	Email     string
+	Purpose   string
+	TokenHash string
+	ExpiresAt pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreateUserToken(ctx context.Context, arg CreateUserTokenParams) error {
+	_, err := q.db.Exec(ctx, createUserToken,
+		arg.UserID,
+		arg.Email,
+		arg.Purpose,
+		arg.TokenHash,
+		arg.ExpiresAt,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const createWebauthnChallenge = `-- name: CreateWebauthnChallenge :exec
+INSERT INTO webauthn_challenges (
+  id, user_id, ceremony, session_data, expires_at, created_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+`
+
+type CreateWebauthnChallengeParams struct {
+	ID          pgtype.UUID
+	UserID      *int64
+	Ceremony    string
+	SessionData []byte
+	ExpiresAt   pgtype.Timestamptz
+	CreatedAt   pgtype.Timestamptz
+}
+
+func (q *Queries) CreateWebauthnChallenge(ctx context.Context, arg CreateWebauthnChallengeParams) error {
+	_, err := q.db.Exec(ctx, createWebauthnChallenge,
+		arg.ID,
+		arg.UserID,
+		arg.Ceremony,
+		arg.SessionData,
+		arg.ExpiresAt,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const deleteExpiredOauthStates = `-- name: DeleteExpiredOauthStates :exec
+DELETE FROM oauth_states WHERE expires_at < $1
+`
+
+func (q *Queries) DeleteExpiredOauthStates(ctx context.Context, expiresAt pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteExpiredOauthStates, expiresAt)
+	return err
+}
+
+const deleteExpiredRevokedAccessTokens = `-- name: DeleteExpiredRevokedAccessTokens :exec
+DELETE FROM revoked_access_tokens WHERE expires_at < $1
+`
+
+func (q *Queries) DeleteExpiredRevokedAccessTokens(ctx context.Context, expiresAt pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteExpiredRevokedAccessTokens, expiresAt)
+	return err
+}
+
+const deleteExpiredRevokedSessionAccessTokens = `-- name: DeleteExpiredRevokedSessionAccessTokens :exec
+DELETE FROM revoked_session_access_tokens WHERE expires_at < $1
+`
+
+func (q *Queries) DeleteExpiredRevokedSessionAccessTokens(ctx context.Context, expiresAt pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteExpiredRevokedSessionAccessTokens, expiresAt)
+	return err
+}
+
+const deleteExpiredRevokedUserAccessTokens = `-- name: DeleteExpiredRevokedUserAccessTokens :exec
+DELETE FROM revoked_user_access_tokens WHERE expires_at < $1
+`
+
+func (q *Queries) DeleteExpiredRevokedUserAccessTokens(ctx context.Context, expiresAt pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteExpiredRevokedUserAccessTokens, expiresAt)
+	return err
+}
+
+const deleteExpiredWebauthnChallenges = `-- name: DeleteExpiredWebauthnChallenges :exec
+DELETE FROM webauthn_challenges WHERE expires_at < $1
+`
+
+func (q *Queries) DeleteExpiredWebauthnChallenges(ctx context.Context, expiresAt pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteExpiredWebauthnChallenges, expiresAt)
+	return err
+}
+
+const deleteRecoveryCodes = `-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE user_id = $1
+`
+
+func (q *Queries) DeleteRecoveryCodes(ctx context.Context, userID int64) error {
+	_, err := q.db.Exec(ctx, deleteRecoveryCodes, userID)
+	return err
+}
+
+const deleteUserIdentity = `-- name: DeleteUserIdentity :execrows
+DELETE FROM user_identities WHERE user_id = $1 AND provider = $2
+`
+
+type DeleteUserIdentityParams struct {
+	UserID   int64
+	Provider string
+}
+
+func (q *Queries) DeleteUserIdentity(ctx context.Context, arg DeleteUserIdentityParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteUserIdentity, arg.UserID, arg.Provider)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const disableTotp = `-- name: DisableTotp :exec
+UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_counter = 0, updated_at = $2 WHERE id = $1 AND deleted_at IS NULL
+`
+
+type DisableTotpParams struct {
+	ID        int64
+	UpdatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) DisableTotp(ctx context.Context, arg DisableTotpParams) error {
+	_, err := q.db.Exec(ctx, disableTotp, arg.ID, arg.UpdatedAt)
+	return err
+}
+
+const disableUser = `-- name: DisableUser :execrows
+UPDATE users SET disabled_at = $2, updated_at = $2 WHERE id = $1 AND deleted_at IS NULL
+`
+
+type DisableUserParams struct {
+	ID         int64
+	DisabledAt pgtype.Timestamptz
+}
+
+func (q *Queries) DisableUser(ctx context.Context, arg DisableUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, disableUser, arg.ID, arg.DisabledAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const enableTotp = `-- name: EnableTotp :exec
+UPDATE users SET totp_enabled = TRUE, updated_at = $2 WHERE id = $1 AND deleted_at IS NULL
+`
+
+type EnableTotpParams struct {
+	ID        int64
+	UpdatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) EnableTotp(ctx context.Context, arg EnableTotpParams) error {
+	_, err := q.db.Exec(ctx, enableTotp, arg.ID, arg.UpdatedAt)
 	return err
 }
 
+const getActiveUserToken = `-- name: GetActiveUserToken :one
+SELECT id, user_id, email, attempts FROM user_tokens WHERE purpose = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > $3
+`
+
+type GetActiveUserTokenParams struct {
+	Purpose   string
+	TokenHash string
+	ExpiresAt pgtype.Timestamptz
+}
+
+type GetActiveUserTokenRow struct {
+	ID       int64
+	UserID   int64
+	Email    string
+	Attempts int32
+}
+
+func (q *Queries) GetActiveUserToken(ctx context.Context, arg GetActiveUserTokenParams) (GetActiveUserTokenRow, error) {
+	row := q.db.QueryRow(ctx, getActiveUserToken, arg.Purpose, arg.TokenHash, arg.ExpiresAt)
+	var i GetActiveUserTokenRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Email,
+		&i.Attempts,
+	)
+	return i, err
+}
+
+const getEmailLoginCode = `-- name: GetEmailLoginCode :one
+SELECT id, code_hash, attempts FROM email_login_tokens WHERE email = $1 AND used_at IS NULL AND expires_at > $2
+ORDER BY created_at DESC LIMIT 1
+`
+
+type GetEmailLoginCodeParams struct {
+	Email     string
+	ExpiresAt pgtype.Timestamptz
+}
+
+type GetEmailLoginCodeRow struct {
+	ID       int64
+	CodeHash string
+	Attempts int32
+}
+
+func (q *Queries) GetEmailLoginCode(ctx context.Context, arg GetEmailLoginCodeParams) (GetEmailLoginCodeRow, error) {
+	row := q.db.QueryRow(ctx, getEmailLoginCode, arg.Email, arg.ExpiresAt)
+	var i GetEmailLoginCodeRow
+	err := row.Scan(&i.ID, &i.CodeHash, &i.Attempts)
+	return i, err
+}
+
+const getEmailLoginTokenEmail = `-- name: GetEmailLoginTokenEmail :one
+SELECT email FROM email_login_tokens WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+`
+
+type GetEmailLoginTokenEmailParams struct {
+	TokenHash string
+	ExpiresAt pgtype.Timestamptz
+}
+
+func (q *Queries) GetEmailLoginTokenEmail(ctx context.Context, arg GetEmailLoginTokenEmailParams) (string, error) {
+	row := q.db.QueryRow(ctx, getEmailLoginTokenEmail, arg.TokenHash, arg.ExpiresAt)
+	var email string
+	err := row.Scan(&email)
+	return email, err
+}
+
+const getEmailLoginTokenSendStats = `-- name: GetEmailLoginTokenSendStats :one
+SELECT COUNT(*) AS sent_count, MAX(created_at)::timestamptz AS last_sent_at FROM email_login_tokens WHERE email = $1 AND created_at > $2
+`
+
+type GetEmailLoginTokenSendStatsParams struct {
+	Email     string
+	CreatedAt pgtype.Timestamptz
+}
+
+type GetEmailLoginTokenSendStatsRow struct {
+	SentCount  int64
+	LastSentAt pgtype.Timestamptz
+}
+
+func (q *Queries) GetEmailLoginTokenSendStats(ctx context.Context, arg GetEmailLoginTokenSendStatsParams) (GetEmailLoginTokenSendStatsRow, error) {
+	row := q.db.QueryRow(ctx, getEmailLoginTokenSendStats, arg.Email, arg.CreatedAt)
+	var i GetEmailLoginTokenSendStatsRow
+	err := row.Scan(&i.SentCount, &i.LastSentAt)
+	return i, err
+}
+
+const getSession = `-- name: GetSession :one
+SELECT id, user_id, device_name, ip_address, user_agent, secret, refresh_token_id, created_at, last_used_at, expires_at, revoked_at FROM sessions WHERE id = $1 AND user_id = $2
+`
+
+type GetSessionParams struct {
+	ID     pgtype.UUID
+	UserID int64
+}
+
+func (q *Queries) GetSession(ctx context.Context, arg GetSessionParams) (Session, error) {
+	row := q.db.QueryRow(ctx, getSession, arg.ID, arg.UserID)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.DeviceName,
+		&i.IpAddress,
+		&i.UserAgent,
+		&i.Secret,
+		&i.RefreshTokenID,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
 const getUser = `-- name: GetUser :one
-SELECT id, name, email, auth_providers, picture, email_verified, created_at, updated_at FROM users WHERE id=$1 AND deleted_at IS NULL
+SELECT id, name, email,
+  ARRAY(SELECT provider FROM user_identities WHERE user_id = users.id ORDER BY user_identities.id)::text[] AS auth_providers,
+  picture, email_verified, locale, created_at, updated_at
+FROM users WHERE id=$1 AND deleted_at IS NULL
 `
 
 type GetUserRow struct {
@@ -78,6 +648,7 @@ type GetUserRow struct {
 	AuthProviders []string
 	Picture       *string
 	EmailVerified bool
+	Locale        *string
 	CreatedAt     pgtype.Timestamptz
 	UpdatedAt     pgtype.Timestamptz
 }
@@ -92,37 +663,88 @@ func (q *Queries) GetUser(ctx context.Context, id int64) (GetUserRow, error) {
 		&i.AuthProviders,
 		&i.Picture,
 		&i.EmailVerified,
+		&i.Locale,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
 	return i, err
 }
 
+const getUserIDByEmail = `-- name: GetUserIDByEmail :one
+SELECT id FROM users WHERE email=$1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetUserIDByEmail(ctx context.Context, email string) (int64, error) {
+	row := q.db.QueryRow(ctx, getUserIDByEmail, email)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getUserIdentity = `-- name: GetUserIdentity :one
+SELECT user_identities.id, user_identities.user_id, user_identities.email FROM user_identities
+JOIN users ON users.id = user_identities.user_id
+WHERE user_identities.provider = $1 AND user_identities.subject = $2 AND users.deleted_at IS NULL
+`
+
+type GetUserIdentityParams struct {
+	Provider string
+	Subject  string
+}
+
+type GetUserIdentityRow struct {
+	ID     int64
+	UserID int64
+	Email  string
+}
+
+func (q *Queries) GetUserIdentity(ctx context.Context, arg GetUserIdentityParams) (GetUserIdentityRow, error) {
+	row := q.db.QueryRow(ctx, getUserIdentity, arg.Provider, arg.Subject)
+	var i GetUserIdentityRow
+	err := row.Scan(&i.ID, &i.UserID, &i.Email)
+	return i, err
+}
+
+const getUserLocale = `-- name: GetUserLocale :one
+SELECT locale FROM users WHERE id=$1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetUserLocale(ctx context.Context, id int64) (*string, error) {
+	row := q.db.QueryRow(ctx, getUserLocale, id)
+	var locale *string
+	err := row.Scan(&locale)
+	return locale, err
+}
+
 const getUserSecrets = `-- name: GetUserSecrets :one
-SELECT id, password, token_hash, auth_providers FROM users WHERE email=$1 AND deleted_at IS NULL
+SELECT id, email, password, token_hash, totp_enabled, disabled_at FROM users WHERE id=$1 AND deleted_at IS NULL
 `
 
 type GetUserSecretsRow struct {
-	ID            int64
-	Password      string
-	TokenHash     string
-	AuthProviders []string
+	ID          int64
+	Email       string
+	Password    string
+	TokenHash   string
+	TotpEnabled bool
+	DisabledAt  pgtype.Timestamptz
 }
 
-func (q *Queries) GetUserSecrets(ctx context.Context, email string) (GetUserSecretsRow, error) {
-	row := q.db.QueryRow(ctx, getUserSecrets, email)
+func (q *Queries) GetUserSecrets(ctx context.Context, id int64) (GetUserSecretsRow, error) {
+	row := q.db.QueryRow(ctx, getUserSecrets, id)
 	var i GetUserSecretsRow
 	err := row.Scan(
 		&i.ID,
+		&i.Email,
 		&i.Password,
 		&i.TokenHash,
-		&i.AuthProviders,
+		&i.TotpEnabled,
+		&i.DisabledAt,
 	)
 	return i, err
 }
 
 const getUserTokenHash = `-- name: GetUserTokenHash :one
-SELECT token_hash FROM users WHERE id=$1 AND deleted_at IS NULL
+SELECT token_hash FROM users WHERE id=$1 AND deleted_at IS NULL AND disabled_at IS NULL
 `
 
 func (q *Queries) GetUserTokenHash(ctx context.Context, id int64) (string, error) {
@@ -132,17 +754,416 @@ func (q *Queries) GetUserTokenHash(ctx context.Context, id int64) (string, error
 	return token_hash, err
 }
 
-const unlinkAuthPlatform = `-- name: UnlinkAuthPlatform :exec
-UPDATE users SET auth_providers = array_remove(auth_providers, $1) WHERE id = $2 AND deleted_at IS NULL
+const getUserTokenSendStats = `-- name: GetUserTokenSendStats :one
+SELECT COUNT(*) AS sent_count, MAX(created_at)::timestamptz AS last_sent_at FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND created_at > $3
+`
+
+type GetUserTokenSendStatsParams struct {
+	UserID    int64
+	Purpose   string
+	CreatedAt pgtype.Timestamptz
+}
+
+type GetUserTokenSendStatsRow struct {
+	SentCount  int64
+	LastSentAt pgtype.Timestamptz
+}
+
+func (q *Queries) GetUserTokenSendStats(ctx context.Context, arg GetUserTokenSendStatsParams) (GetUserTokenSendStatsRow, error) {
+	row := q.db.QueryRow(ctx, getUserTokenSendStats, arg.UserID, arg.Purpose, arg.CreatedAt)
+	var i GetUserTokenSendStatsRow
+	err := row.Scan(&i.SentCount, &i.LastSentAt)
+	return i, err
+}
+
+const getUserTwoFactor = `-- name: GetUserTwoFactor :one
+SELECT email, totp_secret, totp_enabled, totp_last_counter FROM users WHERE id = $1 AND deleted_at IS NULL
+`
+
+type GetUserTwoFactorRow struct {
+	Email           string
+	TotpSecret      *string
+	TotpEnabled     bool
+	TotpLastCounter int64
+}
+
+func (q *Queries) GetUserTwoFactor(ctx context.Context, id int64) (GetUserTwoFactorRow, error) {
+	row := q.db.QueryRow(ctx, getUserTwoFactor, id)
+	var i GetUserTwoFactorRow
+	err := row.Scan(
+		&i.Email,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastCounter,
+	)
+	return i, err
+}
+
+const incrementEmailLoginTokenAttempts = `-- name: IncrementEmailLoginTokenAttempts :exec
+UPDATE email_login_tokens SET
+  attempts = attempts + 1,
+  used_at = CASE WHEN attempts + 1 >= $1::int THEN $2::timestamptz ELSE used_at END
+WHERE id = $3
+`
+
+type IncrementEmailLoginTokenAttemptsParams struct {
+	MaxAttempts int32
+	Now         pgtype.Timestamptz
+	ID          int64
+}
+
+func (q *Queries) IncrementEmailLoginTokenAttempts(ctx context.Context, arg IncrementEmailLoginTokenAttemptsParams) error {
+	_, err := q.db.Exec(ctx, incrementEmailLoginTokenAttempts, arg.MaxAttempts, arg.Now, arg.ID)
+	return err
+}
+
+const incrementTotpFailedAttempts = `-- name: IncrementTotpFailedAttempts :execrows
+UPDATE users SET
+  totp_failed_attempts = CASE WHEN totp_failed_attempts + 1 >= $1::int THEN 0 ELSE totp_failed_attempts + 1 END,
+  totp_locked_until = CASE WHEN totp_failed_attempts + 1 >= $1::int THEN $2::timestamptz ELSE NULL END
+WHERE id = $3 AND (totp_locked_until IS NULL OR totp_locked_until <= $4::timestamptz)
 `
 
-type UnlinkAuthPlatformParams struct {
-	ArrayRemove interface{}
+type IncrementTotpFailedAttemptsParams struct {
+	MaxAttempts int32
+	LockedUntil pgtype.Timestamptz
 	ID          int64
+	Now         pgtype.Timestamptz
+}
+
+func (q *Queries) IncrementTotpFailedAttempts(ctx context.Context, arg IncrementTotpFailedAttemptsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, incrementTotpFailedAttempts,
+		arg.MaxAttempts,
+		arg.LockedUntil,
+		arg.ID,
+		arg.Now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const isAccessTokenRevoked = `-- name: IsAccessTokenRevoked :one
+SELECT EXISTS (
+  SELECT 1 FROM revoked_access_tokens WHERE token_id = $1
+) OR EXISTS (
+  SELECT 1 FROM revoked_session_access_tokens WHERE session_id = $2
+) OR EXISTS (
+  SELECT 1 FROM revoked_user_access_tokens WHERE user_id = $3 AND issued_before > $4
+) AS revoked
+`
+
+type IsAccessTokenRevokedParams struct {
+	TokenID   pgtype.UUID
+	SessionID pgtype.UUID
+	UserID    int64
+	IssuedAt  pgtype.Timestamptz
+}
+
+func (q *Queries) IsAccessTokenRevoked(ctx context.Context, arg IsAccessTokenRevokedParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isAccessTokenRevoked,
+		arg.TokenID,
+		arg.SessionID,
+		arg.UserID,
+		arg.IssuedAt,
+	)
+	var revoked bool
+	err := row.Scan(&revoked)
+	return revoked, err
+}
+
+const listPasskeyCredentials = `-- name: ListPasskeyCredentials :many
+SELECT id, user_id, credential_id, public_key, attestation_type, aaguid, sign_count, transports, flags, created_at, last_used_at FROM passkey_credentials WHERE user_id = $1 ORDER BY id
+`
+
+func (q *Queries) ListPasskeyCredentials(ctx context.Context, userID int64) ([]PasskeyCredential, error) {
+	rows, err := q.db.Query(ctx, listPasskeyCredentials, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PasskeyCredential
+	for rows.Next() {
+		var i PasskeyCredential
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CredentialID,
+			&i.PublicKey,
+			&i.AttestationType,
+			&i.Aaguid,
+			&i.SignCount,
+			&i.Transports,
+			&i.Flags,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserSessions = `-- name: ListUserSessions :many
+SELECT id, device_name, ip_address, user_agent, created_at, last_used_at, expires_at FROM sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+ORDER BY last_used_at DESC
+`
+
+type ListUserSessionsParams struct {
+	UserID    int64
+	ExpiresAt pgtype.Timestamptz
+}
+
+type ListUserSessionsRow struct {
+	ID         pgtype.UUID
+	DeviceName string
+	IpAddress  string
+	UserAgent  string
+	CreatedAt  pgtype.Timestamptz
+	LastUsedAt pgtype.Timestamptz
+	ExpiresAt  pgtype.Timestamptz
+}
+
+func (q *Queries) ListUserSessions(ctx context.Context, arg ListUserSessionsParams) ([]ListUserSessionsRow, error) {
+	rows, err := q.db.Query(ctx, listUserSessions, arg.UserID, arg.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserSessionsRow
+	for rows.Next() {
+		var i ListUserSessionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeviceName,
+			&i.IpAddress,
+			&i.UserAgent,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const resetTotpFailedAttempts = `-- name: ResetTotpFailedAttempts :exec
+UPDATE users SET totp_failed_attempts = 0, totp_locked_until = NULL WHERE id = $1
+`
+
+func (q *Queries) ResetTotpFailedAttempts(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, resetTotpFailedAttempts, id)
+	return err
 }
 
-func (q *Queries) UnlinkAuthPlatform(ctx context.Context, arg UnlinkAuthPlatformParams) error {
-	_, err := q.db.Exec(ctx, unlinkAuthPlatform, arg.ArrayRemove, arg.ID)
+const revokeAccessToken = `-- name: RevokeAccessToken :exec
+INSERT INTO revoked_access_tokens (
+  token_id, expires_at, created_at
+) VALUES (
+  $1, $2, $3
+) ON CONFLICT (token_id) DO UPDATE SET expires_at = GREATEST(revoked_access_tokens.expires_at, EXCLUDED.expires_at)
+`
+
+type RevokeAccessTokenParams struct {
+	TokenID   pgtype.UUID
+	ExpiresAt pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeAccessToken(ctx context.Context, arg RevokeAccessTokenParams) error {
+	_, err := q.db.Exec(ctx, revokeAccessToken, arg.TokenID, arg.ExpiresAt, arg.CreatedAt)
+	return err
+}
+
+const revokeEmailLoginTokens = `-- name: RevokeEmailLoginTokens :exec
+UPDATE email_login_tokens SET used_at = $2 WHERE email = $1 AND used_at IS NULL
+`
+
+type RevokeEmailLoginTokensParams struct {
+	Email  string
+	UsedAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeEmailLoginTokens(ctx context.Context, arg RevokeEmailLoginTokensParams) error {
+	_, err := q.db.Exec(ctx, revokeEmailLoginTokens, arg.Email, arg.UsedAt)
+	return err
+}
+
+const revokeSession = `-- name: RevokeSession :execrows
+UPDATE sessions SET revoked_at = $3 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeSessionParams struct {
+	ID        pgtype.UUID
+	UserID    int64
+	RevokedAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeSession(ctx context.Context, arg RevokeSessionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeSession, arg.ID, arg.UserID, arg.RevokedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeSessionAccessTokens = `-- name: RevokeSessionAccessTokens :exec
+INSERT INTO revoked_session_access_tokens (
+  session_id, expires_at, created_at
+) VALUES (
+  $1, $2, $3
+) ON CONFLICT (session_id) DO UPDATE SET expires_at = GREATEST(revoked_session_access_tokens.expires_at, EXCLUDED.expires_at)
+`
+
+type RevokeSessionAccessTokensParams struct {
+	SessionID pgtype.UUID
+	ExpiresAt pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeSessionAccessTokens(ctx context.Context, arg RevokeSessionAccessTokensParams) error {
+	_, err := q.db.Exec(ctx, revokeSessionAccessTokens, arg.SessionID, arg.ExpiresAt, arg.CreatedAt)
+	return err
+}
+
+const revokeUserAccessTokens = `-- name: RevokeUserAccessTokens :exec
+INSERT INTO revoked_user_access_tokens (
+  user_id, issued_before, expires_at, created_at
+) VALUES (
+  $1, $2, $3, $4
+) ON CONFLICT (user_id) DO UPDATE SET
+  issued_before = GREATEST(revoked_user_access_tokens.issued_before, EXCLUDED.issued_before),
+  expires_at = GREATEST(revoked_user_access_tokens.expires_at, EXCLUDED.expires_at)
+`
+
+type RevokeUserAccessTokensParams struct {
+	UserID       int64
+	IssuedBefore pgtype.Timestamptz
+	ExpiresAt    pgtype.Timestamptz
+	CreatedAt    pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeUserAccessTokens(ctx context.Context, arg RevokeUserAccessTokensParams) error {
+	_, err := q.db.Exec(ctx, revokeUserAccessTokens,
+		arg.UserID,
+		arg.IssuedBefore,
+		arg.ExpiresAt,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const revokeUserSessions = `-- name: RevokeUserSessions :exec
+UPDATE sessions SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+type RevokeUserSessionsParams struct {
+	UserID    int64
+	RevokedAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeUserSessions(ctx context.Context, arg RevokeUserSessionsParams) error {
+	_, err := q.db.Exec(ctx, revokeUserSessions, arg.UserID, arg.RevokedAt)
+	return err
+}
+
+const revokeUserTokens = `-- name: RevokeUserTokens :exec
+UPDATE user_tokens SET used_at = $3 WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+`
+
+type RevokeUserTokensParams struct {
+	UserID  int64
+	Purpose string
+	UsedAt  pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error {
+	_, err := q.db.Exec(ctx, revokeUserTokens, arg.UserID, arg.Purpose, arg.UsedAt)
+	return err
+}
+
+const rotateSessionRefreshToken = `-- name: RotateSessionRefreshToken :execrows
+UPDATE sessions SET
+  refresh_token_id = $1,
+  ip_address = $2,
+  user_agent = $3,
+  last_used_at = $4,
+  expires_at = $5
+WHERE id = $6 AND refresh_token_id = $7 AND revoked_at IS NULL
+`
+
+type RotateSessionRefreshTokenParams struct {
+	NewRefreshTokenID pgtype.UUID
+	IpAddress         string
+	UserAgent         string
+	LastUsedAt        pgtype.Timestamptz
+	ExpiresAt         pgtype.Timestamptz
+	ID                pgtype.UUID
+	RefreshTokenID    pgtype.UUID
+}
+
+func (q *Queries) RotateSessionRefreshToken(ctx context.Context, arg RotateSessionRefreshTokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, rotateSessionRefreshToken,
+		arg.NewRefreshTokenID,
+		arg.IpAddress,
+		arg.UserAgent,
+		arg.LastUsedAt,
+		arg.ExpiresAt,
+		arg.ID,
+		arg.RefreshTokenID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setTotpSecret = `-- name: SetTotpSecret :exec
+UPDATE users SET totp_secret = $2, totp_enabled = FALSE, totp_last_counter = 0, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL
+`
+
+type SetTotpSecretParams struct {
+	ID         int64
+	TotpSecret *string
+	UpdatedAt  pgtype.Timestamptz
+}
+
+func (q *Queries) SetTotpSecret(ctx context.Context, arg SetTotpSecretParams) error {
+	_, err := q.db.Exec(ctx, setTotpSecret, arg.ID, arg.TotpSecret, arg.UpdatedAt)
+	return err
+}
+
+const updatePasskeyCredentialUsage = `-- name: UpdatePasskeyCredentialUsage :exec
+UPDATE passkey_credentials SET sign_count = $3, flags = $4, last_used_at = $5 WHERE user_id = $1 AND credential_id = $2
+`
+
+type UpdatePasskeyCredentialUsageParams struct {
+	UserID       int64
+	CredentialID []byte
+	SignCount    int64
+	Flags        int16
+	LastUsedAt   pgtype.Timestamptz
+}
+
+func (q *Queries) UpdatePasskeyCredentialUsage(ctx context.Context, arg UpdatePasskeyCredentialUsageParams) error {
+	_, err := q.db.Exec(ctx, updatePasskeyCredentialUsage,
+		arg.UserID,
+		arg.CredentialID,
+		arg.SignCount,
+		arg.Flags,
+		arg.LastUsedAt,
+	)
 	return err
 }
 
@@ -160,3 +1181,118 @@ func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams)
 	_, err := q.db.Exec(ctx, updatePassword, arg.ID, arg.Password, arg.UpdatedAt)
 	return err
 }
+
+const updateTokenHash = `-- name: UpdateTokenHash :exec
+UPDATE users SET token_hash = $2, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL
+`
+
+type UpdateTokenHashParams struct {
+	ID        int64
+	TokenHash string
+	UpdatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) UpdateTokenHash(ctx context.Context, arg UpdateTokenHashParams) error {
+	_, err := q.db.Exec(ctx, updateTokenHash, arg.ID, arg.TokenHash, arg.UpdatedAt)
+	return err
+}
+
+const updateTotpLastCounter = `-- name: UpdateTotpLastCounter :execrows
+UPDATE users SET totp_last_counter = $2 WHERE id = $1 AND totp_last_counter < $2
+`
+
+type UpdateTotpLastCounterParams struct {
+	ID              int64
+	TotpLastCounter int64
+}
+
+func (q *Queries) UpdateTotpLastCounter(ctx context.Context, arg UpdateTotpLastCounterParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateTotpLastCounter, arg.ID, arg.TotpLastCounter)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateUserIdentityProfile = `-- name: UpdateUserIdentityProfile :exec
+UPDATE user_identities SET profile = $2, updated_at = $3 WHERE id = $1
+`
+
+type UpdateUserIdentityProfileParams struct {
+	ID        int64
+	Profile   []byte
+	UpdatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) UpdateUserIdentityProfile(ctx context.Context, arg UpdateUserIdentityProfileParams) error {
+	_, err := q.db.Exec(ctx, updateUserIdentityProfile, arg.ID, arg.Profile, arg.UpdatedAt)
+	return err
+}
+
+const updateUserLocale = `-- name: UpdateUserLocale :execrows
+UPDATE users SET locale = $2, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL
+`
+
+type UpdateUserLocaleParams struct {
+	ID        int64
+	Locale    *string
+	UpdatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) UpdateUserLocale(ctx context.Context, arg UpdateUserLocaleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateUserLocale, arg.ID, arg.Locale, arg.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const useEmailLoginToken = `-- name: UseEmailLoginToken :execrows
+UPDATE email_login_tokens SET used_at = $2 WHERE id = $1 AND used_at IS NULL
+`
+
+type UseEmailLoginTokenParams struct {
+	ID     int64
+	UsedAt pgtype.Timestamptz
+}
+
+func (q *Queries) UseEmailLoginToken(ctx context.Context, arg UseEmailLoginTokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useEmailLoginToken, arg.ID, arg.UsedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes SET used_at = $3 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+`
+
+type UseRecoveryCodeParams struct {
+	UserID   int64
+	CodeHash string
+	UsedAt   pgtype.Timestamptz
+}
+
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useRecoveryCode, arg.UserID, arg.CodeHash, arg.UsedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :exec
+UPDATE users SET email_verified = TRUE, updated_at = $3 WHERE id = $1 AND email = $2 AND deleted_at IS NULL
+`
+
+type VerifyUserEmailParams struct {
+	ID        int64
+	Email     string
+	UpdatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) error {
+	_, err := q.db.Exec(ctx, verifyUserEmail, arg.ID, arg.Email, arg.UpdatedAt)
+	return err
+}
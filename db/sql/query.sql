@@ -1,25 +1,264 @@
 -- name: GetUser :one
-SELECT id, name, email, auth_providers, picture, email_verified, created_at, updated_at FROM users WHERE id=$1 AND deleted_at IS NULL;
+SELECT id, name, email,
+  ARRAY(SELECT provider FROM user_identities WHERE user_id = users.id ORDER BY user_identities.id)::text[] AS auth_providers,
+  picture, email_verified, locale, created_at, updated_at
+FROM users WHERE id=$1 AND deleted_at IS NULL;
 
 -- name: GetUserTokenHash :one
-SELECT token_hash FROM users WHERE id=$1 AND deleted_at IS NULL;
+SELECT token_hash FROM users WHERE id=$1 AND deleted_at IS NULL AND disabled_at IS NULL;
 
--- name: CreateUser :exec
+-- name: GetUserLocale :one
+SELECT locale FROM users WHERE id=$1 AND deleted_at IS NULL;
+
+-- name: GetUserIDByEmail :one
+SELECT id FROM users WHERE email=$1 AND deleted_at IS NULL;
+
+-- name: CreateUser :one
 INSERT INTO users (
-  name, email, password, auth_providers, picture, token_hash, created_at, updated_at
+  name, email, password, picture, email_verified, token_hash, created_at, updated_at
 ) VALUES (
   $1, $2, $3, $4, $5, $6, $7, $8
-);
+)
+RETURNING id;
 
 -- name: GetUserSecrets :one
-SELECT id, password, token_hash, auth_providers FROM users WHERE email=$1 AND deleted_at IS NULL;
+SELECT id, email, password, token_hash, totp_enabled, disabled_at FROM users WHERE id=$1 AND deleted_at IS NULL;
+
+-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (
+  user_id, provider, subject, email, profile, created_at, updated_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+);
+
+-- name: GetUserIdentity :one
+SELECT user_identities.id, user_identities.user_id, user_identities.email FROM user_identities
+JOIN users ON users.id = user_identities.user_id
+WHERE user_identities.provider = $1 AND user_identities.subject = $2 AND users.deleted_at IS NULL;
+
+-- name: ClaimLegacyUserIdentity :one
+UPDATE user_identities SET subject = sqlc.arg(subject), updated_at = sqlc.arg(updated_at)
+WHERE provider = sqlc.arg(provider) AND subject = 'legacy:' || sqlc.arg(email)::text
+RETURNING id, user_id, email;
 
--- name: ConnectAuthPlatform :one
-UPDATE users SET auth_providers = array_append(auth_providers, $1) WHERE id = $2 AND email = $3 AND deleted_at IS NULL AND array_position(auth_providers, $1) IS NULL
-RETURNING id, auth_providers;
+-- name: UpdateUserIdentityProfile :exec
+UPDATE user_identities SET profile = $2, updated_at = $3 WHERE id = $1;
 
--- name: UnlinkAuthPlatform :exec
-UPDATE users SET auth_providers = array_remove(auth_providers, $1) WHERE id = $2 AND deleted_at IS NULL;
+-- name: DeleteUserIdentity :execrows
+DELETE FROM user_identities WHERE user_id = $1 AND provider = $2;
 
 -- name: UpdatePassword :exec
 UPDATE users SET password = $2, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: DisableUser :execrows
+UPDATE users SET disabled_at = $2, updated_at = $2 WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: UpdateUserLocale :execrows
+UPDATE users SET locale = $2, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: UpdateTokenHash :exec
+UPDATE users SET token_hash = $2, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: VerifyUserEmail :exec
+UPDATE users SET email_verified = TRUE, updated_at = $3 WHERE id = $1 AND email = $2 AND deleted_at IS NULL;
+
+-- name: CreateUserToken :exec
+INSERT INTO user_tokens (
+  user_id, email, purpose, token_hash, expires_at, created_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+);
+
+-- name: ConsumeUserToken :one
+UPDATE user_tokens SET used_at = $3 WHERE purpose = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > $3
+RETURNING user_id, email;
+
+-- name: GetActiveUserToken :one
+SELECT id, user_id, email, attempts FROM user_tokens WHERE purpose = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > $3;
+
+-- name: RevokeUserTokens :exec
+UPDATE user_tokens SET used_at = $3 WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL;
+
+-- name: GetUserTokenSendStats :one
+SELECT COUNT(*) AS sent_count, MAX(created_at)::timestamptz AS last_sent_at FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND created_at > $3;
+
+-- name: CreateSession :exec
+INSERT INTO sessions (
+  id, user_id, device_name, ip_address, user_agent, secret, refresh_token_id, created_at, last_used_at, expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+);
+
+-- name: GetSession :one
+SELECT * FROM sessions WHERE id = $1 AND user_id = $2;
+
+-- name: ListUserSessions :many
+SELECT id, device_name, ip_address, user_agent, created_at, last_used_at, expires_at FROM sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+ORDER BY last_used_at DESC;
+
+-- name: RotateSessionRefreshToken :execrows
+UPDATE sessions SET
+  refresh_token_id = sqlc.arg(new_refresh_token_id),
+  ip_address = sqlc.arg(ip_address),
+  user_agent = sqlc.arg(user_agent),
+  last_used_at = sqlc.arg(last_used_at),
+  expires_at = sqlc.arg(expires_at)
+WHERE id = sqlc.arg(id) AND refresh_token_id = sqlc.arg(refresh_token_id) AND revoked_at IS NULL;
+
+-- name: RevokeSession :execrows
+UPDATE sessions SET revoked_at = $3 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :exec
+UPDATE sessions SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: GetUserTwoFactor :one
+SELECT email, totp_secret, totp_enabled, totp_last_counter FROM users WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: SetTotpSecret :exec
+UPDATE users SET totp_secret = $2, totp_enabled = FALSE, totp_last_counter = 0, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: EnableTotp :exec
+UPDATE users SET totp_enabled = TRUE, updated_at = $2 WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: DisableTotp :exec
+UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_counter = 0, updated_at = $2 WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: UpdateTotpLastCounter :execrows
+UPDATE users SET totp_last_counter = $2 WHERE id = $1 AND totp_last_counter < $2;
+
+-- name: IncrementTotpFailedAttempts :execrows
+UPDATE users SET
+  totp_failed_attempts = CASE WHEN totp_failed_attempts + 1 >= sqlc.arg(max_attempts)::int THEN 0 ELSE totp_failed_attempts + 1 END,
+  totp_locked_until = CASE WHEN totp_failed_attempts + 1 >= sqlc.arg(max_attempts)::int THEN sqlc.arg(locked_until)::timestamptz ELSE NULL END
+WHERE id = sqlc.arg(id) AND (totp_locked_until IS NULL OR totp_locked_until <= sqlc.arg(now)::timestamptz);
+
+-- name: ResetTotpFailedAttempts :exec
+UPDATE users SET totp_failed_attempts = 0, totp_locked_until = NULL WHERE id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (
+  user_id, code_hash, created_at
+) VALUES (
+  $1, $2, $3
+);
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE user_id = $1;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes SET used_at = $3 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CreatePasskeyCredential :exec
+INSERT INTO passkey_credentials (
+  user_id, credential_id, public_key, attestation_type, aaguid, sign_count, transports, flags, created_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+);
+
+-- name: ListPasskeyCredentials :many
+SELECT * FROM passkey_credentials WHERE user_id = $1 ORDER BY id;
+
+-- name: UpdatePasskeyCredentialUsage :exec
+UPDATE passkey_credentials SET sign_count = $3, flags = $4, last_used_at = $5 WHERE user_id = $1 AND credential_id = $2;
+
+-- name: CreateWebauthnChallenge :exec
+INSERT INTO webauthn_challenges (
+  id, user_id, ceremony, session_data, expires_at, created_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+);
+
+-- name: ConsumeWebauthnChallenge :one
+DELETE FROM webauthn_challenges WHERE id = $1 AND ceremony = $2 AND expires_at > $3
+RETURNING user_id, session_data;
+
+-- name: DeleteExpiredWebauthnChallenges :exec
+DELETE FROM webauthn_challenges WHERE expires_at < $1;
+
+-- name: CreateEmailLoginToken :exec
+INSERT INTO email_login_tokens (
+  email, token_hash, code_hash, expires_at, created_at
+) VALUES (
+  $1, $2, $3, $4, $5
+);
+
+-- name: GetEmailLoginTokenEmail :one
+SELECT email FROM email_login_tokens WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2;
+
+-- name: ConsumeEmailLoginToken :execrows
+UPDATE email_login_tokens SET used_at = $3 WHERE token_hash = $1 AND email = $2 AND used_at IS NULL AND expires_at > $3;
+
+-- name: GetEmailLoginCode :one
+SELECT id, code_hash, attempts FROM email_login_tokens WHERE email = $1 AND used_at IS NULL AND expires_at > $2
+ORDER BY created_at DESC LIMIT 1;
+
+-- name: UseEmailLoginToken :execrows
+UPDATE email_login_tokens SET used_at = $2 WHERE id = $1 AND used_at IS NULL;
+
+-- name: IncrementEmailLoginTokenAttempts :exec
+UPDATE email_login_tokens SET
+  attempts = attempts + 1,
+  used_at = CASE WHEN attempts + 1 >= sqlc.arg(max_attempts)::int THEN sqlc.arg(now)::timestamptz ELSE used_at END
+WHERE id = sqlc.arg(id);
+
+-- name: RevokeEmailLoginTokens :exec
+UPDATE email_login_tokens SET used_at = $2 WHERE email = $1 AND used_at IS NULL;
+
+-- name: GetEmailLoginTokenSendStats :one
+SELECT COUNT(*) AS sent_count, MAX(created_at)::timestamptz AS last_sent_at FROM email_login_tokens WHERE email = $1 AND created_at > $2;
+
+-- name: CreateOauthState :exec
+INSERT INTO oauth_states (
+  state_hash, provider, code_verifier, nonce, expires_at, created_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+);
+
+-- name: ConsumeOauthState :one
+DELETE FROM oauth_states WHERE state_hash = $1 AND provider = $2 AND expires_at > $3
+RETURNING code_verifier, nonce;
+
+-- name: DeleteExpiredOauthStates :exec
+DELETE FROM oauth_states WHERE expires_at < $1;
+
+-- name: RevokeAccessToken :exec
+INSERT INTO revoked_access_tokens (
+  token_id, expires_at, created_at
+) VALUES (
+  $1, $2, $3
+) ON CONFLICT (token_id) DO UPDATE SET expires_at = GREATEST(revoked_access_tokens.expires_at, EXCLUDED.expires_at);
+
+-- name: RevokeUserAccessTokens :exec
+INSERT INTO revoked_user_access_tokens (
+  user_id, issued_before, expires_at, created_at
+) VALUES (
+  $1, $2, $3, $4
+) ON CONFLICT (user_id) DO UPDATE SET
+  issued_before = GREATEST(revoked_user_access_tokens.issued_before, EXCLUDED.issued_before),
+  expires_at = GREATEST(revoked_user_access_tokens.expires_at, EXCLUDED.expires_at);
+
+-- name: RevokeSessionAccessTokens :exec
+INSERT INTO revoked_session_access_tokens (
+  session_id, expires_at, created_at
+) VALUES (
+  $1, $2, $3
+) ON CONFLICT (session_id) DO UPDATE SET expires_at = GREATEST(revoked_session_access_tokens.expires_at, EXCLUDED.expires_at);
+
+-- name: IsAccessTokenRevoked :one
+SELECT EXISTS (
+  SELECT 1 FROM revoked_access_tokens WHERE token_id = sqlc.arg(token_id)
+) OR EXISTS (
+  SELECT 1 FROM revoked_session_access_tokens WHERE session_id = sqlc.arg(session_id)
+) OR EXISTS (
+  SELECT 1 FROM revoked_user_access_tokens WHERE user_id = sqlc.arg(user_id) AND issued_before > sqlc.arg(issued_at)
+) AS revoked;
+
+-- name: DeleteExpiredRevokedAccessTokens :exec
+DELETE FROM revoked_access_tokens WHERE expires_at < $1;
+
+-- name: DeleteExpiredRevokedSessionAccessTokens :exec
+DELETE FROM revoked_session_access_tokens WHERE expires_at < $1;
+
+-- name: DeleteExpiredRevokedUserAccessTokens :exec
+DELETE FROM revoked_user_access_tokens WHERE expires_at < $1;
@@ -0,0 +1,92 @@
+package dto
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Error codes of the api, they are part of the api and must not change
+const (
+	ErrCodeBadRequest      = "bad_request"
+	ErrCodeUnauthorized    = "unauthorized"
+	ErrCodeForbidden       = "forbidden"
+	ErrCodeNotFound        = "not_found"
+	ErrCodeConflict        = "conflict"
+	ErrCodeTooManyRequests = "too_many_requests"
+	ErrCodeInternal        = "internal_error"
+
+	ErrCodeValidationFailed = "validation_failed"
+	ErrCodeInvalidPayload   = "invalid_payload"
+	ErrCodeInvalidProvider  = "invalid_provider"
+
+	ErrCodeInvalidCredentials = "invalid_credentials"
+	ErrCodeAccountDisabled    = "account_disabled"
+	ErrCodeUserNotFound       = "user_not_found"
+	ErrCodeEmailTaken         = "email_taken"
+	ErrCodeEmailVerified      = "email_already_verified"
+	ErrCodeWeakPassword       = "weak_password"
+	ErrCodeEmailRateLimited   = "email_rate_limited"
+	ErrCodeLocaleUnsupported  = "locale_unsupported"
+
+	ErrCodeTokenMissing = "token_missing"
+	ErrCodeTokenInvalid = "token_invalid"
+	ErrCodeTokenExpired = "token_expired"
+	ErrCodeTokenRevoked = "token_revoked"
+
+	ErrCodeInsufficientScope = "insufficient_scope"
+	ErrCodeMissingRole       = "missing_role"
+
+	ErrCodeSessionNotFound = "session_not_found"
+
+	ErrCodeVerificationTokenInvalid = "verification_token_invalid"
+	ErrCodeResetTokenInvalid        = "reset_token_invalid"
+
+	ErrCodeLastProvider          = "last_provider"
+	ErrCodeProviderAlreadyLinked = "provider_already_linked"
+	ErrCodeProviderLinkedToOther = "provider_linked_to_other_user"
+	ErrCodeAccountNotFound       = "account_not_found"
+	ErrCodeExternalLoginFailed   = "external_login_failed"
+	ErrCodeOAuthStateInvalid     = "oauth_state_invalid"
+	ErrCodeSignupDisabled        = "signup_disabled"
+
+	ErrCodeTwoFactorChallengeInvalid = "two_factor_challenge_invalid"
+	ErrCodeTwoFactorNotEnabled       = "two_factor_not_enabled"
+	ErrCodeTwoFactorAlreadyEnabled   = "two_factor_already_enabled"
+	ErrCodeTwoFactorNotStarted       = "two_factor_not_started"
+	ErrCodeTwoFactorLocked           = "two_factor_locked"
+	ErrCodeInvalidCode               = "invalid_code"
+
+	ErrCodePasskeyChallengeInvalid  = "passkey_challenge_invalid"
+	ErrCodePasskeyCredentialInvalid = "passkey_credential_invalid"
+	ErrCodePasskeyAlreadyRegistered = "passkey_already_registered"
+
+	ErrCodeLoginLinkInvalid = "login_link_invalid"
+
+	ErrCodeUpstreamFailed = "upstream_failed"
+)
+
+// StatusErrorCode is the code of errors that were not given one
+func StatusErrorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrCodeBadRequest
+	case http.StatusUnauthorized:
+		return ErrCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusConflict:
+		return ErrCodeConflict
+	case http.StatusTooManyRequests:
+		return ErrCodeTooManyRequests
+	case 0, http.StatusInternalServerError:
+		return ErrCodeInternal
+	}
+
+	text := http.StatusText(status)
+	if text == "" {
+		return ErrCodeInternal
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
@@ -6,15 +6,42 @@ import (
 	"strings"
 )
 
+// Error is returned by the services, Code is the http status and ErrorCode
+// the stable code clients match on, it defaults to one derived from the status.
+// The reason is english, responses use the message of the code in the
+// language of the client, with the params filled in
 type Error struct {
-	Reason []string `json:"reason"`
-	Code   int      `json:"-"`
+	Reason    []string          `json:"reason"`
+	Code      int               `json:"-"`
+	ErrorCode string            `json:"code"`
+	Params    map[string]string `json:"-"`
+	Fields    []FieldError      `json:"errors,omitempty"`
+}
+
+// FieldError is a validation error of one field of the request, Code is the
+// failed validation tag like "required" or "email", Key is the message of the
+// tag in the catalogs, it depends on the type of the field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Key     string `json:"-"`
+	Param   string `json:"-"`
 }
 
 func (e *Error) Error() string {
 	return fmt.Sprintf("[reason: %s, code: %d]", strings.Join(e.Reason, ", "), e.Code)
 }
 
+// GetErrorCode returns the code of the error, or the one of its status when
+// it has none
+func (e *Error) GetErrorCode() string {
+	if e.ErrorCode != "" {
+		return e.ErrorCode
+	}
+	return StatusErrorCode(e.Code)
+}
+
 func NewError(reason string) error {
 	var err Error
 	err.Code = http.StatusInternalServerError
@@ -29,6 +56,27 @@ func NewErrorWithStatus(status int, reason string) error {
 	return &err
 }
 
+// NewErrorWithCode is NewErrorWithStatus for errors clients need to tell
+// apart, the code is one of the ErrCode constants
+func NewErrorWithCode(status int, code string, reason string) error {
+	var err Error
+	err.Code = status
+	err.ErrorCode = code
+	err.AddReason(reason)
+	return &err
+}
+
+// NewErrorWithParams is NewErrorWithCode for messages that name something,
+// like the provider in "another google account is linked"
+func NewErrorWithParams(status int, code string, reason string, params map[string]string) error {
+	var err Error
+	err.Code = status
+	err.ErrorCode = code
+	err.Params = params
+	err.AddReason(reason)
+	return &err
+}
+
 func NewErrors(reasons []error) error {
 	var err Error
 	err.Code = http.StatusInternalServerError
@@ -0,0 +1,64 @@
+package dto
+
+import (
+	"backend/i18n"
+	"net/http"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem is the RFC 7807 body of error responses, Code is the stable code
+// clients match on and Errors has the invalid fields of the request
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem builds the problem of the error in the language, errors without
+// an explicit code keep their more specific english reason, other languages
+// get the message of the status
+func NewProblem(err *Error, lang language.Tag) *Problem {
+	status := err.Code
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	problem := &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: strings.Join(err.Reason, ", "),
+		Code:   err.GetErrorCode(),
+	}
+
+	if err.ErrorCode != "" || lang != i18n.DefaultLanguage {
+		if detail, ok := i18n.Error(lang, problem.Code, err.Params); ok {
+			problem.Detail = detail
+		}
+	}
+
+	if len(err.Fields) > 0 {
+		messages := make([]string, len(err.Fields))
+		problem.Errors = make([]FieldError, len(err.Fields))
+		for i, field := range err.Fields {
+			if field.Key != "" {
+				field.Message = i18n.Validation(lang, field.Key, map[string]string{
+					"field": field.Field,
+					"param": field.Param,
+				})
+			}
+			problem.Errors[i] = field
+			messages[i] = field.Message
+		}
+		problem.Detail = strings.Join(messages, ", ")
+	}
+	return problem
+}
@@ -2,12 +2,51 @@ package dto
 
 import (
 	"backend/db"
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type LoginRequest struct {
-	Provider string `json:"provider" binding:"required"`
-	Payload  string `json:"payload" binding:"required"`
+	Provider   string     `json:"provider" binding:"required"`
+	Payload    string     `json:"payload" binding:"required"`
+	DeviceName string     `json:"deviceName" binding:"max=255"`
+	Client     ClientInfo `json:"-"`
+}
+
+// LoginChallengeResponse is returned by login instead of tokens when the user
+// has two factor authentication enabled
+type LoginChallengeResponse struct {
+	ChallengeToken string    `json:"challengeToken"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string     `json:"challengeToken" binding:"required"`
+	Code           string     `json:"code" binding:"required"`
+	DeviceName     string     `json:"deviceName" binding:"max=255"`
+	Client         ClientInfo `json:"-"`
+}
+
+type TotpEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type TotpCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// ClientInfo describes the device a request came from, it is filled by the
+// handlers and stored with the session
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
 }
 
 type LoginResponse struct {
@@ -26,8 +65,77 @@ type CreateUserPayloadNormal struct {
 	Picture  *string `json:"picture"`
 }
 
-type CreateUserPayloadGoogle struct {
+// AdminCreateUserRequest creates a user with a password from the command line,
+// the email can be marked as verified so no mail is sent
+type AdminCreateUserRequest struct {
+	CreateUserPayloadNormal
+	EmailVerified bool `json:"emailVerified"`
+}
+
+type AdminUserResponse struct {
+	ID     int64  `json:"id"`
+	Email  string `json:"email"`
+	Action string `json:"action"`
+}
+
+// AdminTokenResponse is the access token an admin command acted on
+type AdminTokenResponse struct {
+	ID     string `json:"id"`
+	Action string `json:"action"`
+}
+
+// RevokeTokensRequest revokes the access tokens issued before IssuedBefore,
+// all tokens issued so far when it is not set
+type RevokeTokensRequest struct {
+	IssuedBefore time.Time `json:"issuedBefore"`
+}
+
+// GooglePayload is the payload of the google provider, the state is the one
+// returned when the authorization was started
+type GooglePayload struct {
 	AuthorizationCode string `json:"authorizationCode" binding:"required"`
+	State             string `json:"state" binding:"required"`
+}
+
+type AuthorizationStartResponse struct {
+	AuthorizationURL string    `json:"authorizationUrl"`
+	State            string    `json:"state"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+}
+
+// OIDCPayload is the payload of the oidc providers, the state is the one
+// returned when the authorization was started
+type OIDCPayload struct {
+	AuthorizationCode string `json:"authorizationCode" binding:"required"`
+	State             string `json:"state" binding:"required"`
+}
+
+// EmailLinkPayload is the payload of the email-link provider, either the token
+// from the mailed link or the email with the mailed code
+type EmailLinkPayload struct {
+	Token string `json:"token"`
+	Email string `json:"email" binding:"omitempty,email,max=255"`
+	Code  string `json:"code" binding:"omitempty,len=6,numeric"`
+}
+
+type CreateUserPayloadEmailLink struct {
+	Name string `json:"name" binding:"required,ascii,min=3,max=255"`
+	EmailLinkPayload
+}
+
+// PasskeyPayload is the payload of the passkey provider for login and linking,
+// the credential is the json encoded PublicKeyCredential from the browser
+type PasskeyPayload struct {
+	ChallengeID string          `json:"challengeId" binding:"required,uuid"`
+	Credential  json.RawMessage `json:"credential" binding:"required"`
+}
+
+// PasskeyOptionsResponse holds the options to pass to navigator.credentials,
+// the challenge id has to be sent back in the PasskeyPayload
+type PasskeyOptionsResponse struct {
+	ChallengeID string    `json:"challengeId"`
+	Options     any       `json:"options"`
+	ExpiresAt   time.Time `json:"expiresAt"`
 }
 
 type ConnectAuthPlatformRequest struct {
@@ -35,6 +143,53 @@ type ConnectAuthPlatformRequest struct {
 	Payload  string `json:"payload" binding:"required"`
 }
 
+// UpdateLocaleRequest stores the language of the user, an empty locale goes
+// back to the Accept-Language header
+type UpdateLocaleRequest struct {
+	Locale string `json:"locale" binding:"max=35"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email,min=3,max=255"`
+}
+
+type EmailLinkRequest struct {
+	Email string `json:"email" binding:"required,email,min=3,max=255"`
+}
+
+type ConfirmPasswordResetRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=255"`
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	DeviceName string    `json:"deviceName"`
+	IPAddress  string    `json:"ipAddress"`
+	UserAgent  string    `json:"userAgent"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+func SessionResponseFromDB(db *db.ListUserSessionsRow) *SessionResponse {
+	response := SessionResponse{
+		ID:         uuid.UUID(db.ID.Bytes).String(),
+		DeviceName: db.DeviceName,
+		IPAddress:  db.IpAddress,
+		UserAgent:  db.UserAgent,
+		CreatedAt:  db.CreatedAt.Time,
+		LastUsedAt: db.LastUsedAt.Time,
+		ExpiresAt:  db.ExpiresAt.Time,
+	}
+
+	return &response
+}
+
 type GetUserResponse struct {
 	ID            int64     `json:"id"`
 	Name          string    `json:"name"`
@@ -42,6 +197,7 @@ type GetUserResponse struct {
 	Picture       *string   `json:"picture"`
 	AuthProviders []string  `json:"authProviders"`
 	EmailVerified bool      `json:"emailVerified"`
+	Locale        *string   `json:"locale"`
 	CreatedAt     time.Time `json:"createdAt"`
 	UpdatedAt     time.Time `json:"updatedAt"`
 }
@@ -54,6 +210,7 @@ func GetUserResponseFromDB(db *db.GetUserRow) *GetUserResponse {
 		Picture:       db.Picture,
 		EmailVerified: db.EmailVerified,
 		AuthProviders: db.AuthProviders,
+		Locale:        db.Locale,
 		CreatedAt:     db.CreatedAt.Time,
 		UpdatedAt:     db.UpdatedAt.Time,
 	}
@@ -1,71 +1,98 @@
 package google
 
 import (
+	"backend/tracing"
 	"backend/utils"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log/slog"
-	"net/http"
-)
 
-var ACCESS_TOKEN_URL = "https://oauth2.googleapis.com/token"
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
 
-func GetAccessToken(authorizationCode string, config utils.GoogleConfig) (*AccessTokenResponse, error) {
-	slog.Info("getting access token for auth code ", "authorizationCode", authorizationCode)
+var (
+	ISSUER    = "https://accounts.google.com"
+	AUTH_URL  = "https://accounts.google.com/o/oauth2/v2/auth"
+	TOKEN_URL = "https://oauth2.googleapis.com/token"
+	JWKS_URL  = "https://www.googleapis.com/oauth2/v3/certs"
+)
 
-	var accessTokenResponseData AccessTokenResponse
+// Client runs the authorization code flow with PKCE against google, the
+// endpoints from the config replace the google ones when they are set
+type Client struct {
+	oauth2   *oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
 
-	err := utils.HttpPostFormData(ACCESS_TOKEN_URL, map[string]string{}, map[string]string{
-		"client_id":     config.CLIENT_ID,
-		"client_secret": config.CLIENT_SECRET,
-		"grant_type":    "authorization_code",
-		"code":          authorizationCode,
-		"redirect_uri":  config.REDIRECT_URI,
-	}, &accessTokenResponseData)
-	slog.Info("access Token Response ", "accessTokenResponseData", accessTokenResponseData)
+func NewClient(config utils.GoogleConfig) *Client {
+	issuer := orDefault(config.ISSUER, ISSUER)
+	keySet := gooidc.NewRemoteKeySet(utils.WithHTTPClient(context.Background()), orDefault(config.JWKS_URL, JWKS_URL))
 
-	if err != nil || accessTokenResponseData.AccessToken == "" {
-		slog.Error("could not get google access token")
-		return nil, err
+	return &Client{
+		oauth2: &oauth2.Config{
+			ClientID:     config.CLIENT_ID,
+			ClientSecret: config.CLIENT_SECRET,
+			RedirectURL:  config.REDIRECT_URI,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  orDefault(config.AUTH_URL, AUTH_URL),
+				TokenURL: orDefault(config.TOKEN_URL, TOKEN_URL),
+			},
+			Scopes: []string{gooidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: gooidc.NewVerifier(issuer, keySet, &gooidc.Config{ClientID: config.CLIENT_ID}),
 	}
+}
 
-	return &accessTokenResponseData, nil
+// AuthCodeURL returns the url of the google login page
+func (c *Client) AuthCodeURL(state string, nonce string, codeVerifier string) string {
+	return c.oauth2.AuthCodeURL(state,
+		oauth2.S256ChallengeOption(codeVerifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	)
 }
 
-func UserInformation(accessToken string, config utils.GoogleConfig) (*UserInfoResponse, error) {
-	// Create the request URL
-	url := "https://www.googleapis.com/oauth2/v2/userinfo"
+// ExchangeCode trades the authorization code for tokens and returns the claims
+// of the id token once its signature, audience, nonce and email are verified
+func (c *Client) ExchangeCode(ctx context.Context, authorizationCode string, codeVerifier string, nonce string) (claims *Claims, err error) {
+	ctx, span := tracing.Tracer("backend/google").Start(ctx, "google.ExchangeCode")
+	defer func() { tracing.End(span, err) }()
 
-	// Create the HTTP request
-	req, err := http.NewRequest("GET", url, nil)
+	ctx = utils.WithHTTPClient(ctx)
+	token, err := c.oauth2.Exchange(ctx, authorizationCode, oauth2.VerifierOption(codeVerifier))
 	if err != nil {
-		return nil, fmt.Errorf("unable to create request: %v", err)
+		return nil, fmt.Errorf("could not exchange authorization code: %w", err)
 	}
 
-	// Add the Authorization header with the access token
-	req.Header.Add("Authorization", "Bearer "+accessToken)
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
 
-	// Make the HTTP request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	idToken, err := c.verifier.Verify(ctx, rawIDToken)
 	if err != nil {
-		return nil, fmt.Errorf("unable to send request: %v", err)
+		return nil, fmt.Errorf("could not verify id_token: %w", err)
 	}
-	defer resp.Body.Close()
 
-	// Check if the response was successful
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get user info, status: %d, response: %s", resp.StatusCode, string(body))
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce does not match")
 	}
 
-	// Parse the response body into the UserInfo struct
-	var userInfo UserInfoResponse
-	err = json.NewDecoder(resp.Body).Decode(&userInfo)
-	if err != nil {
-		return nil, fmt.Errorf("unable to parse user info: %v", err)
+	claims = &Claims{}
+	if err = idToken.Claims(claims); err != nil {
+		return nil, fmt.Errorf("could not parse id_token claims: %w", err)
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, errors.New("google email is missing or not verified")
 	}
 
-	return &userInfo, nil
+	return claims, nil
+}
+
+func orDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
 }
@@ -0,0 +1,274 @@
+package google
+
+import (
+	"backend/utils"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	testClientID     = "backend.apps.googleusercontent.com"
+	testCode         = "authorization-code"
+	testCodeVerifier = "code-verifier-code-verifier-code-verifier-1234"
+	testNonce        = "nonce"
+)
+
+// fakeGoogle stands in for the google token endpoint and its keys, the
+// client is pointed at it through the endpoint settings of the config
+type fakeGoogle struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	// signingKey signs the id token, it is the published key unless a test
+	// replaces it
+	signingKey *rsa.PrivateKey
+
+	// idToken are the claims of the issued id token next to iss, aud, exp, iat
+	// and nonce, audience replaces the client id as aud when it is set
+	idToken  map[string]any
+	audience string
+
+	// codeVerifier is the PKCE verifier the last token request sent
+	codeVerifier string
+}
+
+func newFakeGoogle(t *testing.T) *fakeGoogle {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &fakeGoogle{
+		key:        key,
+		signingKey: key,
+		idToken: map[string]any{
+			"sub":            "google-subject-1",
+			"email":          "google@example.com",
+			"email_verified": true,
+			"name":           "Google User",
+			"picture":        "https://example.com/picture.png",
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /certs", g.keys)
+	mux.HandleFunc("POST /token", g.token)
+	g.Server = httptest.NewServer(mux)
+	t.Cleanup(g.Close)
+
+	return g
+}
+
+func (g *fakeGoogle) client() *Client {
+	return NewClient(utils.GoogleConfig{
+		CLIENT_ID:     testClientID,
+		CLIENT_SECRET: "secret",
+		REDIRECT_URI:  "https://example.com/login/google",
+		ISSUER:        g.URL,
+		AUTH_URL:      g.URL + "/auth",
+		TOKEN_URL:     g.URL + "/token",
+		JWKS_URL:      g.URL + "/certs",
+	})
+}
+
+func (g *fakeGoogle) keys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]any{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   encode(g.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(g.key.E)).Bytes()),
+		}},
+	})
+}
+
+// token only accepts the test code, it remembers the PKCE verifier so tests
+// can check it was sent
+func (g *fakeGoogle) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request"})
+		return
+	}
+	g.codeVerifier = r.PostForm.Get("code_verifier")
+	if r.PostForm.Get("code") != testCode {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+
+	audience := g.audience
+	if audience == "" {
+		audience = testClientID
+	}
+
+	now := time.Now()
+	claims := map[string]any{
+		"iss":   g.URL,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": testNonce,
+	}
+	for name, value := range g.idToken {
+		claims[name] = value
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     g.sign(claims),
+	})
+}
+
+// sign returns the claims as a RS256 signed jwt
+func (g *fakeGoogle) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]any{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := encode(header) + "." + encode(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, g.signingKey, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signingInput + "." + encode(signature)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	g := newFakeGoogle(t)
+
+	authURL := g.client().AuthCodeURL("state", testNonce, testCodeVerifier)
+	if !strings.HasPrefix(authURL, g.URL+"/auth?") {
+		t.Fatalf("authorization url = %s, want the configured endpoint", authURL)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	want := map[string]string{
+		"client_id":             testClientID,
+		"redirect_uri":          "https://example.com/login/google",
+		"response_type":         "code",
+		"state":                 "state",
+		"nonce":                 testNonce,
+		"code_challenge":        oauth2.S256ChallengeFromVerifier(testCodeVerifier),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := query.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestExchangeCode(t *testing.T) {
+	g := newFakeGoogle(t)
+
+	claims, err := g.client().ExchangeCode(context.Background(), testCode, testCodeVerifier, testNonce)
+	if err != nil {
+		t.Fatalf("could not exchange code: %v", err)
+	}
+	if claims.Subject != "google-subject-1" || claims.Email != "google@example.com" || claims.Name != "Google User" {
+		t.Errorf("claims = %+v, want the ones of the id token", claims)
+	}
+	if claims.Picture == nil || *claims.Picture != "https://example.com/picture.png" {
+		t.Errorf("picture = %v, want the one of the id token", claims.Picture)
+	}
+	if g.codeVerifier != testCodeVerifier {
+		t.Errorf("code_verifier = %q, want %q", g.codeVerifier, testCodeVerifier)
+	}
+}
+
+func TestExchangeCodeRejectsInvalidTokens(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		audience   string
+		signingKey *rsa.PrivateKey
+		nonce      string
+		wantErr    string
+	}{
+		{name: "wrong nonce", nonce: "another-nonce", wantErr: "nonce does not match"},
+		{name: "audience of another client", audience: "another-client", nonce: testNonce, wantErr: "could not verify id_token"},
+		{name: "signed with an unpublished key", signingKey: otherKey, nonce: testNonce, wantErr: "could not verify id_token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t)
+			g.audience = tt.audience
+			if tt.signingKey != nil {
+				g.signingKey = tt.signingKey
+			}
+
+			_, err := g.client().ExchangeCode(context.Background(), testCode, testCodeVerifier, tt.nonce)
+			if err == nil {
+				t.Fatal("code was exchanged")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExchangeCodeEmailVerified(t *testing.T) {
+	tests := []struct {
+		name          string
+		emailVerified any
+		wantErr       bool
+	}{
+		{name: "verified", emailVerified: true},
+		{name: "not verified", emailVerified: false, wantErr: true},
+		{name: "missing", emailVerified: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGoogle(t)
+			delete(g.idToken, "email_verified")
+			if tt.emailVerified != nil {
+				g.idToken["email_verified"] = tt.emailVerified
+			}
+
+			_, err := g.client().ExchangeCode(context.Background(), testCode, testCodeVerifier, testNonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,12 +1,9 @@
 package google
 
-type AccessTokenResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	ExpiresIn    string `json:"expires_in"`
-}
-
-type UserInfoResponse struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+type Claims struct {
+	Subject       string  `json:"sub"`
+	Email         string  `json:"email"`
+	EmailVerified bool    `json:"email_verified"`
+	Name          string  `json:"name"`
+	Picture       *string `json:"picture"`
 }
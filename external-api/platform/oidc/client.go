@@ -0,0 +1,153 @@
+package oidc
+
+import (
+	"backend/tracing"
+	"backend/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+)
+
+// Client talks to one OpenID Connect provider, the endpoints are discovered
+// from the issuer on first use so the service starts while a provider is down
+type Client struct {
+	config utils.OIDCProviderConfig
+
+	mu       sync.Mutex
+	provider *gooidc.Provider
+	verifier *gooidc.IDTokenVerifier
+}
+
+func NewClient(config utils.OIDCProviderConfig) *Client {
+	return &Client{
+		config: config,
+	}
+}
+
+func (c *Client) discover(ctx context.Context) (*gooidc.Provider, *gooidc.IDTokenVerifier, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.provider != nil {
+		return c.provider, c.verifier, nil
+	}
+
+	// the provider keeps using this context to refresh the keys
+	provider, err := gooidc.NewProvider(utils.WithHTTPClient(context.WithoutCancel(ctx)), c.config.ISSUER_URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not discover %s: %w", c.config.ISSUER_URL, err)
+	}
+
+	c.provider = provider
+	c.verifier = provider.Verifier(&gooidc.Config{ClientID: c.config.CLIENT_ID})
+	return c.provider, c.verifier, nil
+}
+
+func (c *Client) oauth2Config(provider *gooidc.Provider) *oauth2.Config {
+	scopes := c.config.SCOPES
+	if len(scopes) == 0 {
+		scopes = []string{gooidc.ScopeOpenID, "email", "profile"}
+	}
+
+	return &oauth2.Config{
+		ClientID:     c.config.CLIENT_ID,
+		ClientSecret: c.config.CLIENT_SECRET,
+		RedirectURL:  c.config.REDIRECT_URI,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       scopes,
+	}
+}
+
+// AuthCodeURL returns the url of the provider login page, the code challenge
+// of the PKCE verifier and the nonce are sent along
+func (c *Client) AuthCodeURL(ctx context.Context, state string, nonce string, codeVerifier string) (string, error) {
+	provider, _, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return c.oauth2Config(provider).AuthCodeURL(state,
+		oauth2.S256ChallengeOption(codeVerifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	), nil
+}
+
+// ExchangeCode trades the authorization code for tokens with the PKCE
+// verifier and returns the claims of the id token once its signature,
+// audience, nonce and email are verified
+func (c *Client) ExchangeCode(ctx context.Context, authorizationCode string, codeVerifier string, nonce string) (claims *Claims, err error) {
+	ctx, span := tracing.Tracer("backend/oidc").Start(ctx, "oidc.ExchangeCode", trace.WithAttributes(attribute.String("oidc.provider", c.config.KEY)))
+	defer func() { tracing.End(span, err) }()
+
+	ctx = utils.WithHTTPClient(ctx)
+	provider, verifier, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	oauth2Token, err := c.oauth2Config(provider).Exchange(ctx, authorizationCode, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("could not exchange authorization code: %w", err)
+	}
+
+	rawIDToken, ok := oauth2Token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("could not verify id_token: %w", err)
+	}
+
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce does not match")
+	}
+
+	claims = &Claims{}
+	if err = idToken.Claims(claims); err != nil {
+		return nil, fmt.Errorf("could not parse id_token claims: %w", err)
+	}
+
+	if claims.Email == "" && provider.UserInfoEndpoint() != "" {
+		slog.InfoContext(ctx, "id_token has no email, asking userinfo", slog.String("issuer", c.config.ISSUER_URL))
+		userInfo, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(oauth2Token))
+		if err != nil {
+			return nil, fmt.Errorf("could not get userinfo: %w", err)
+		}
+
+		var userInfoClaims Claims
+		if err = userInfo.Claims(&userInfoClaims); err != nil {
+			return nil, fmt.Errorf("could not parse userinfo claims: %w", err)
+		}
+
+		// the userinfo response must be about the same user as the id token
+		if userInfoClaims.Subject != claims.Subject {
+			return nil, errors.New("userinfo subject does not match id_token")
+		}
+
+		claims.Email = userInfoClaims.Email
+		claims.EmailVerified = userInfoClaims.EmailVerified
+		if claims.Name == "" {
+			claims.Name = userInfoClaims.Name
+		}
+		if claims.Picture == nil {
+			claims.Picture = userInfoClaims.Picture
+		}
+	}
+
+	// the email is what links the login to an account, a missing
+	// email_verified claim counts as not verified
+	if claims.Email == "" || claims.EmailVerified == nil || !*claims.EmailVerified {
+		return nil, errors.New("provider did not return a verified email")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,294 @@
+package oidc
+
+import (
+	"backend/utils"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	testClientID     = "backend"
+	testCode         = "authorization-code"
+	testCodeVerifier = "code-verifier-code-verifier-code-verifier-1234"
+	testNonce        = "nonce"
+)
+
+// fakeProvider is an openid connect provider with discovery, keys, a token
+// endpoint for one authorization code and userinfo
+type fakeProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	// idToken are the claims of the issued id token next to iss, aud, exp, iat
+	// and nonce, audience replaces the client id as aud when it is set
+	idToken  map[string]any
+	audience string
+	userInfo map[string]any
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &fakeProvider{
+		key: key,
+		idToken: map[string]any{
+			"sub":            "subject-1",
+			"email":          "oidc@example.com",
+			"email_verified": true,
+			"name":           "OIDC User",
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /keys", p.keys)
+	mux.HandleFunc("POST /token", p.token)
+	mux.HandleFunc("GET /userinfo", p.userinfo)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func (p *fakeProvider) client() *Client {
+	return NewClient(utils.OIDCProviderConfig{
+		KEY:          "fake",
+		ISSUER_URL:   p.URL,
+		CLIENT_ID:    testClientID,
+		REDIRECT_URI: "https://example.com/login/fake",
+	})
+}
+
+func (p *fakeProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.URL,
+		"authorization_endpoint":                p.URL + "/authorize",
+		"token_endpoint":                        p.URL + "/token",
+		"jwks_uri":                              p.URL + "/keys",
+		"userinfo_endpoint":                     p.URL + "/userinfo",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (p *fakeProvider) keys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]any{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   encode(p.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// token only accepts the test code with its PKCE verifier
+func (p *fakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request"})
+		return
+	}
+	if r.PostForm.Get("code") != testCode || r.PostForm.Get("code_verifier") != testCodeVerifier {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+
+	audience := p.audience
+	if audience == "" {
+		audience = testClientID
+	}
+
+	now := time.Now()
+	claims := map[string]any{
+		"iss":   p.URL,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": testNonce,
+	}
+	for name, value := range p.idToken {
+		claims[name] = value
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     p.sign(claims),
+	})
+}
+
+func (p *fakeProvider) userinfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer access-token" || p.userInfo == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, p.userInfo)
+}
+
+// sign returns the claims as a RS256 signed jwt
+func (p *fakeProvider) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]any{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := encode(header) + "." + encode(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signingInput + "." + encode(signature)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	p := newFakeProvider(t)
+
+	authURL, err := p.client().AuthCodeURL(context.Background(), "state", testNonce, testCodeVerifier)
+	if err != nil {
+		t.Fatalf("could not get authorization url: %v", err)
+	}
+	if !strings.HasPrefix(authURL, p.URL+"/authorize?") {
+		t.Fatalf("authorization url = %s, want the discovered endpoint", authURL)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	want := map[string]string{
+		"client_id":             testClientID,
+		"redirect_uri":          "https://example.com/login/fake",
+		"response_type":         "code",
+		"state":                 "state",
+		"nonce":                 testNonce,
+		"code_challenge":        oauth2.S256ChallengeFromVerifier(testCodeVerifier),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := query.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestExchangeCode(t *testing.T) {
+	p := newFakeProvider(t)
+
+	claims, err := p.client().ExchangeCode(context.Background(), testCode, testCodeVerifier, testNonce)
+	if err != nil {
+		t.Fatalf("could not exchange code: %v", err)
+	}
+	if claims.Subject != "subject-1" || claims.Email != "oidc@example.com" || claims.Name != "OIDC User" {
+		t.Errorf("claims = %+v, want the ones of the id token", claims)
+	}
+}
+
+func TestExchangeCodeRejectsInvalidTokens(t *testing.T) {
+	tests := []struct {
+		name         string
+		audience     string
+		codeVerifier string
+		nonce        string
+	}{
+		{name: "audience of another client", audience: "another-client", codeVerifier: testCodeVerifier, nonce: testNonce},
+		{name: "wrong code verifier", codeVerifier: "another-code-verifier-another-code-verifier", nonce: testNonce},
+		{name: "wrong nonce", codeVerifier: testCodeVerifier, nonce: "another-nonce"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newFakeProvider(t)
+			p.audience = tt.audience
+
+			if _, err := p.client().ExchangeCode(context.Background(), testCode, tt.codeVerifier, tt.nonce); err == nil {
+				t.Fatal("code was exchanged")
+			}
+		})
+	}
+}
+
+func TestExchangeCodeEmailVerified(t *testing.T) {
+	tests := []struct {
+		name          string
+		emailVerified any
+		wantErr       bool
+	}{
+		{name: "verified", emailVerified: true},
+		{name: "not verified", emailVerified: false, wantErr: true},
+		{name: "missing", emailVerified: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newFakeProvider(t)
+			delete(p.idToken, "email_verified")
+			if tt.emailVerified != nil {
+				p.idToken["email_verified"] = tt.emailVerified
+			}
+
+			_, err := p.client().ExchangeCode(context.Background(), testCode, testCodeVerifier, testNonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExchangeCodeEmailFromUserInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		userInfo map[string]any
+		wantErr  bool
+	}{
+		{name: "verified", userInfo: map[string]any{"sub": "subject-1", "email": "info@example.com", "email_verified": true}},
+		{name: "not verified", userInfo: map[string]any{"sub": "subject-1", "email": "info@example.com", "email_verified": false}, wantErr: true},
+		{name: "another subject", userInfo: map[string]any{"sub": "subject-2", "email": "info@example.com", "email_verified": true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newFakeProvider(t)
+			p.idToken = map[string]any{"sub": "subject-1"}
+			p.userInfo = tt.userInfo
+
+			claims, err := p.client().ExchangeCode(context.Background(), testCode, testCodeVerifier, testNonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && claims.Email != "info@example.com" {
+				t.Errorf("email = %q, want the one from userinfo", claims.Email)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package oidc
+
+// Claims are the identity claims read from the id token, missing ones are
+// filled from the userinfo endpoint
+type Claims struct {
+	Subject       string  `json:"sub"`
+	Email         string  `json:"email"`
+	EmailVerified *bool   `json:"email_verified"`
+	Name          string  `json:"name"`
+	Picture       *string `json:"picture"`
+}
@@ -0,0 +1,156 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "UP"
+	StatusDown = "DOWN"
+)
+
+const defaultCheckTimeout = 3 * time.Second
+
+// Checker is a dependency the service needs to be ready, Check returns an
+// error when the dependency cannot be used
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checkerFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c *checkerFunc) Name() string {
+	return c.name
+}
+
+func (c *checkerFunc) Check(ctx context.Context) error {
+	return c.check(ctx)
+}
+
+// NewChecker turns a function into a Checker, for dependencies that cannot
+// implement the interface themselves
+func NewChecker(name string, check func(ctx context.Context) error) Checker {
+	return &checkerFunc{name: name, check: check}
+}
+
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status  string        `json:"status"`
+	Service string        `json:"service"`
+	Version string        `json:"version"`
+	Checks  []CheckResult `json:"checks,omitempty"`
+}
+
+type Health struct {
+	service      string
+	version      string
+	timeout      time.Duration
+	mu           sync.RWMutex
+	checkers     []Checker
+	shuttingDown atomic.Bool
+}
+
+func New(service string, version string) *Health {
+	return &Health{
+		service: service,
+		version: version,
+		timeout: defaultCheckTimeout,
+	}
+}
+
+func (h *Health) Register(checkers ...Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkers = append(h.checkers, checkers...)
+}
+
+// Shutdown marks the service as not ready, so load balancers stop sending
+// requests while the running ones finish
+func (h *Health) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// Live reports whether the process is running, it does not look at the
+// dependencies so a database outage does not restart the service
+func (h *Health) Live() Report {
+	return Report{Status: StatusUp, Service: h.service, Version: h.version}
+}
+
+// Ready runs all checks at the same time, the service is ready when every
+// check passes and it is not shutting down
+func (h *Health) Ready(ctx context.Context) Report {
+	report := Report{Status: StatusUp, Service: h.service, Version: h.version}
+	if h.shuttingDown.Load() {
+		report.Status = StatusDown
+		report.Checks = []CheckResult{{Name: "shutdown", Status: StatusDown, Error: "service is shutting down"}}
+		return report
+	}
+
+	h.mu.RLock()
+	checkers := h.checkers
+	h.mu.RUnlock()
+
+	report.Checks = make([]CheckResult, len(checkers))
+	var wg sync.WaitGroup
+	for i, checker := range checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = h.run(ctx, checker)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// run stops waiting for a check after the timeout, also when the check itself
+// does not watch the context
+func (h *Health) run(ctx context.Context, checker Checker) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Name:      checker.Name(),
+		Status:    StatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "health check failed", slog.String("check", checker.Name()), slog.Any("error", err))
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,127 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeChecker passes or fails after the delay, it ignores the context like a
+// dependency that hangs
+type fakeChecker struct {
+	name  string
+	delay time.Duration
+	err   error
+	calls atomic.Int32
+}
+
+func (c *fakeChecker) Name() string {
+	return c.name
+}
+
+func (c *fakeChecker) Check(ctx context.Context) error {
+	c.calls.Add(1)
+	time.Sleep(c.delay)
+	return c.err
+}
+
+func TestReady(t *testing.T) {
+	h := New("backend", "1.0.0")
+	h.Register(
+		&fakeChecker{name: "database", delay: 20 * time.Millisecond},
+		&fakeChecker{name: "storage", delay: 20 * time.Millisecond},
+	)
+
+	report := h.Ready(context.Background())
+	if report.Status != StatusUp || report.Service != "backend" || report.Version != "1.0.0" {
+		t.Fatalf("report = %+v, want backend 1.0.0 up", report)
+	}
+	if len(report.Checks) != 2 || report.Checks[0].Name != "database" || report.Checks[1].Name != "storage" {
+		t.Fatalf("checks = %+v, want database and storage in order", report.Checks)
+	}
+	for _, result := range report.Checks {
+		if result.Status != StatusUp || result.Error != "" {
+			t.Errorf("check %s = %+v, want it up", result.Name, result)
+		}
+		if result.LatencyMs < 20 {
+			t.Errorf("check %s latency = %vms, want at least the 20ms it took", result.Name, result.LatencyMs)
+		}
+	}
+}
+
+func TestReadyRunsChecksAtTheSameTime(t *testing.T) {
+	h := New("backend", "1.0.0")
+	for _, name := range []string{"database", "migrations", "storage"} {
+		h.Register(&fakeChecker{name: name, delay: 100 * time.Millisecond})
+	}
+
+	start := time.Now()
+	h.Ready(context.Background())
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Errorf("ready took %s, want the checks to run at the same time", elapsed)
+	}
+}
+
+func TestReadyWithFailingCheck(t *testing.T) {
+	h := New("backend", "1.0.0")
+	h.Register(
+		&fakeChecker{name: "database"},
+		&fakeChecker{name: "storage", err: errors.New("bucket not found")},
+	)
+
+	report := h.Ready(context.Background())
+	if report.Status != StatusDown {
+		t.Fatalf("status = %s, want %s", report.Status, StatusDown)
+	}
+	if report.Checks[0].Status != StatusUp {
+		t.Errorf("database = %+v, want it up", report.Checks[0])
+	}
+	if report.Checks[1].Status != StatusDown || report.Checks[1].Error != "bucket not found" {
+		t.Errorf("storage = %+v, want it down with its error", report.Checks[1])
+	}
+}
+
+func TestReadyCheckTimeout(t *testing.T) {
+	h := New("backend", "1.0.0")
+	h.timeout = 50 * time.Millisecond
+	h.Register(&fakeChecker{name: "database", delay: time.Second})
+
+	start := time.Now()
+	report := h.Ready(context.Background())
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("ready took %s, want it to stop waiting after the timeout", elapsed)
+	}
+	if report.Status != StatusDown || report.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("report = %+v, want the database down with a timeout", report)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	h := New("backend", "1.0.0")
+	checker := &fakeChecker{name: "database"}
+	h.Register(checker)
+
+	if report := h.Ready(context.Background()); report.Status != StatusUp {
+		t.Fatalf("status before shutdown = %s, want %s", report.Status, StatusUp)
+	}
+
+	h.Shutdown()
+
+	report := h.Ready(context.Background())
+	if report.Status != StatusDown {
+		t.Fatalf("status after shutdown = %s, want %s", report.Status, StatusDown)
+	}
+	if len(report.Checks) != 1 || report.Checks[0].Name != "shutdown" {
+		t.Errorf("checks = %+v, want only the shutdown check", report.Checks)
+	}
+	if calls := checker.calls.Load(); calls != 1 {
+		t.Errorf("database was checked %d times, want once before the shutdown", calls)
+	}
+
+	// the process still runs, so it stays live
+	if live := h.Live(); live.Status != StatusUp {
+		t.Errorf("live status after shutdown = %s, want %s", live.Status, StatusUp)
+	}
+}
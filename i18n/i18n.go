@@ -0,0 +1,162 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// catalog holds the messages of one language, errors are keyed by the error
+// code and validation by the validator tag
+type catalog struct {
+	Errors     map[string]string `json:"errors"`
+	Validation map[string]string `json:"validation"`
+}
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// DefaultLanguage is used when the client asks for none of the supported
+// languages, its catalog also fills the gaps of the other catalogs
+var DefaultLanguage = language.English
+
+var (
+	supported []language.Tag
+	catalogs  = map[language.Tag]*catalog{}
+	matcher   language.Matcher
+)
+
+var placeholder = regexp.MustCompile(`\{[a-z_]+\}`)
+
+func init() {
+	entries, err := locales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	// the default language comes first, the matcher falls back to the first tag
+	supported = []language.Tag{DefaultLanguage}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))
+		tag, err := language.Parse(name)
+		if err != nil {
+			panic(fmt.Sprintf("invalid locale file %s: %v", entry.Name(), err))
+		}
+
+		data, err := locales.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			panic(err)
+		}
+
+		var c catalog
+		if err = json.Unmarshal(data, &c); err != nil {
+			panic(fmt.Sprintf("invalid locale file %s: %v", entry.Name(), err))
+		}
+
+		catalogs[tag] = &c
+		if tag != DefaultLanguage {
+			supported = append(supported, tag)
+		}
+	}
+
+	if catalogs[DefaultLanguage] == nil {
+		panic("missing catalog of the default language")
+	}
+	matcher = language.NewMatcher(supported)
+}
+
+// Match returns the supported language of the first preference that has one,
+// a preference is a language tag or an Accept-Language header
+func Match(preferences ...string) language.Tag {
+	for _, preference := range preferences {
+		if preference == "" {
+			continue
+		}
+
+		tags, _, err := language.ParseAcceptLanguage(preference)
+		if err != nil || len(tags) == 0 {
+			continue
+		}
+
+		_, index, confidence := matcher.Match(tags...)
+		if confidence != language.No {
+			return supported[index]
+		}
+	}
+	return DefaultLanguage
+}
+
+// Lookup returns the supported language of a language tag, so "de-AT" is
+// stored as "de", false when no supported language is close enough
+func Lookup(locale string) (language.Tag, bool) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return language.Und, false
+	}
+
+	_, index, confidence := matcher.Match(tag)
+	if confidence < language.High {
+		return language.Und, false
+	}
+	return supported[index], true
+}
+
+type ctxKey struct{}
+
+func WithLanguage(ctx context.Context, tag language.Tag) context.Context {
+	return context.WithValue(ctx, ctxKey{}, tag)
+}
+
+// FromContext returns the language of the request, or the default one
+func FromContext(ctx context.Context) language.Tag {
+	if tag, ok := ctx.Value(ctxKey{}).(language.Tag); ok {
+		return tag
+	}
+	return DefaultLanguage
+}
+
+// Error returns the message of the error code, false when no catalog has it
+// or a parameter of the message is missing
+func Error(tag language.Tag, code string, params map[string]string) (string, bool) {
+	return message(tag, func(c *catalog) string { return c.Errors[code] }, params)
+}
+
+// Validation returns the message of the validator tag, unknown tags use the
+// "default" message
+func Validation(tag language.Tag, key string, params map[string]string) string {
+	if msg, ok := message(tag, func(c *catalog) string { return c.Validation[key] }, params); ok {
+		return msg
+	}
+	msg, _ := message(tag, func(c *catalog) string { return c.Validation["default"] }, params)
+	return msg
+}
+
+func message(tag language.Tag, lookup func(*catalog) string, params map[string]string) (string, bool) {
+	msg := ""
+	if c, ok := catalogs[tag]; ok {
+		msg = lookup(c)
+	}
+	if msg == "" {
+		msg = lookup(catalogs[DefaultLanguage])
+	}
+	if msg == "" {
+		return "", false
+	}
+
+	replacements := make([]string, 0, len(params)*2)
+	for key, value := range params {
+		replacements = append(replacements, "{"+key+"}", value)
+	}
+	msg = strings.NewReplacer(replacements...).Replace(msg)
+
+	if placeholder.MatchString(msg) {
+		return "", false
+	}
+	return msg, true
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"backend/mailer"
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// maxMessages is how many messages the mailer keeps, older ones are dropped
+// so a long running process does not grow without limit
+const maxMessages = 100
+
+// MemoryMailer keeps the last messages in memory instead of delivering them
+// and only logs their recipient and subject, it is meant for local development
+// and tests
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, message mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	slog.InfoContext(ctx, "mail stored in memory", slog.String("to", message.To), slog.String("subject", message.Subject))
+	m.messages = append(m.messages, message)
+	if len(m.messages) > maxMessages {
+		m.messages = m.messages[len(m.messages)-maxMessages:]
+	}
+	return nil
+}
+
+// Sent returns a copy of the messages kept so far, the oldest first
+func (m *MemoryMailer) Sent() []mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sent := make([]mailer.Message, len(m.messages))
+	copy(sent, m.messages)
+	return sent
+}
+
+// SentTo returns the messages sent to the given address
+func (m *MemoryMailer) SentTo(to string) []mailer.Message {
+	var sent []mailer.Message
+	for _, message := range m.Sent() {
+		if message.To == to {
+			sent = append(sent, message)
+		}
+	}
+	return sent
+}
+
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = nil
+}
@@ -0,0 +1,55 @@
+package smtp
+
+import (
+	"backend/mailer"
+	"backend/utils"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+type SMTPMailer struct {
+	address string
+	from    string
+	auth    smtp.Auth
+}
+
+func NewSMTPMailer(config utils.MailConfig) (mailer.Mailer, error) {
+	if config.HOST == "" || config.FROM == "" {
+		return nil, fmt.Errorf("smtp host and from address are required")
+	}
+
+	var auth smtp.Auth
+	if config.USERNAME != "" {
+		auth = smtp.PlainAuth("", config.USERNAME, config.PASSWORD, config.HOST)
+	}
+
+	return &SMTPMailer{
+		address: net.JoinHostPort(config.HOST, strconv.Itoa(config.PORT)),
+		from:    config.FROM,
+		auth:    auth,
+	}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, message mailer.Message) error {
+	var body strings.Builder
+	body.WriteString("From: " + m.from + "\r\n")
+	body.WriteString("To: " + message.To + "\r\n")
+	body.WriteString("Subject: " + message.Subject + "\r\n")
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	body.WriteString("\r\n")
+	body.WriteString(message.Body)
+
+	err := smtp.SendMail(m.address, m.auth, m.from, []string{message.To}, []byte(body.String()))
+	if err != nil {
+		slog.ErrorContext(ctx, "could not send mail", slog.String("to", message.To), slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
@@ -1,20 +1,15 @@
 package main
 
 import (
-	"backend/api"
-	"backend/api/apiUtils"
-	"backend/api/middleware"
-	"backend/db"
-	"backend/service"
-	platformService "backend/service/platform"
-	"backend/token"
 	"backend/utils"
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
-
-	"github.com/gin-gonic/gin"
-	"github.com/gin-gonic/gin/binding"
+	"strings"
 )
 
 const (
@@ -22,61 +17,60 @@ const (
 	CURRENT_VERSION = "0.1.0"
 )
 
+const usage = `usage: backend [command] [flags]
+
+commands:
+  serve                 start the http server (default)
+  migrate               up | down [N] | status | force VERSION
+  user create           create a user with a password
+  user disable          disable a user and end their sessions
+  user set-password     replace the password of a user
+  user revoke-sessions  end every session of a user
+  user revoke-tokens    revoke the access tokens of a user issued before a time
+  token revoke          revoke an access token by its id
+  keys generate         generate the key pairs of the [token] section
+  config check          validate the configuration
+
+run "backend <command> -h" for the flags of a command, every command except
+serve writes json to stdout and logs to stderr
+`
+
 func main() {
 	ctx := context.Background()
-	logger := slog.New(&utils.ContextHandler{Handler: slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	})})
-	slog.SetDefault(logger)
 
-	config, err := utils.LoadConfig("config")
-	if err != nil {
-		slog.Error("cannot load config", slog.Any("error", err))
-		os.Exit(1)
+	args := os.Args[1:]
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name = args[0]
+		args = args[1:]
 	}
 
-	// storage, err := oci.NewOciStorage(config.OCI_STORAGE)
-	if err != nil {
-		slog.Error("cannot create connection to object storage", slog.Any("error", err))
-		os.Exit(1)
+	// the other commands keep stdout for their json output
+	var logOutput io.Writer = os.Stderr
+	if name == "serve" {
+		logOutput = os.Stdout
 	}
+	logger := slog.New(utils.NewContextHandler(slog.NewJSONHandler(logOutput, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}), nil))
+	slog.SetDefault(logger)
 
-	pool, err := db.Connect(ctx, config.DB_URL)
-	if err != nil {
-		slog.Error("cannot connect to database", slog.Any("error", err))
-		os.Exit(1)
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
 	}
-	defer pool.Close()
 
-	tokenMaker, err := token.NewJWTMaker(
-		"backend.user",
-		config.TOKEN.ACCESS_SECRET_KEY,
-		config.TOKEN.ACCESS_PUBLIC_KEY,
-		config.TOKEN.REFRESH_SECRET_KEY,
-		config.TOKEN.REFRESH_PUBLIC_KEY,
-		config.TOKEN.ACCESS_TOKEN_DURATION,
-		config.TOKEN.REFRESH_TOKEN_DURATION,
-	)
-	if err != nil {
-		slog.Error("cannot create token maker", slog.Any("error", err))
-		os.Exit(1)
+	err := command(ctx, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-
-	// services
-	googleService := platformService.NewGoogleService(pool, config.GOOGLE)
-
-	userService := service.NewUserService(pool, tokenMaker, []platformService.AuthPlatform{googleService})
-
-	err = apiUtils.AddCustomValidator(binding.Validator.Engine())
 	if err != nil {
-		slog.Error("could not add validator", slog.Any("error", err))
+		if name == "serve" {
+			slog.Error("server failed", slog.Any("error", err))
+		} else if !errors.Is(err, errReported) {
+			writeError(err)
+		}
 		os.Exit(1)
 	}
-
-	r := gin.Default()
-	r.Use(middleware.CORSMiddleware(config.CORS))
-	// r.Use(func(ctx *gin.Context) { time.Sleep(500 * time.Millisecond); ctx.Next() })
-	api.RegisterPath(&r.RouterGroup, config, SERVICE_NAME, CURRENT_VERSION, tokenMaker, userService)
-
-	r.Run(":" + config.PORT)
 }
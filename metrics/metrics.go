@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "backend"
+
+// Registry holds the metrics of the service, the default prometheus registry
+// is not used so libraries cannot add metrics by accident
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Handled http requests by route template, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of handled http requests by route template, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by auth platform and result.",
+	}, []string{"provider", "result"})
+
+	tokensIssued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_issued_total",
+		Help:      "Issued tokens by type.",
+	}, []string{"type"})
+
+	tokenValidationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_validation_failures_total",
+		Help:      "Rejected tokens by type and reason.",
+	}, []string{"type", "reason"})
+
+	outboundRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbound_http_requests_total",
+		Help:      "Http calls to other services by host, method and status.",
+	}, []string{"host", "method", "status"})
+
+	outboundRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "outbound_http_request_duration_seconds",
+		Help:      "Latency of http calls to other services by host and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"host", "method"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		logins,
+		tokensIssued,
+		tokenValidationFailures,
+		outboundRequests,
+		outboundRequestDuration,
+	)
+}
+
+// Handler serves the metrics in the prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Middleware counts the requests by the route template, so /users/1 and
+// /users/2 are the same series, requests without a route are "unmatched"
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		httpRequests.WithLabelValues(route, c.Request.Method, status).Inc()
+		httpRequestDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Login results
+const (
+	LoginSuccess   = "success"
+	LoginChallenge = "challenge"
+	LoginFailure   = "failure"
+)
+
+func ObserveLogin(provider string, result string) {
+	logins.WithLabelValues(provider, result).Inc()
+}
+
+func ObserveTokenIssued(tokenType string) {
+	tokensIssued.WithLabelValues(tokenType).Inc()
+}
+
+// ObserveTokenValidationFailure counts a rejected token, the reason is
+// "expired" or "invalid"
+func ObserveTokenValidationFailure(tokenType string, reason string) {
+	tokenValidationFailures.WithLabelValues(tokenType, reason).Inc()
+}
+
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+// InstrumentTransport counts the calls made through the transport, a nil
+// transport uses http.DefaultTransport
+func InstrumentTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &instrumentedTransport{next: next}
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	} else if req.Context().Err() != nil {
+		status = "canceled"
+	}
+
+	outboundRequests.WithLabelValues(req.URL.Host, req.Method, status).Inc()
+	outboundRequestDuration.WithLabelValues(req.URL.Host, req.Method).Observe(time.Since(start).Seconds())
+	return resp, err
+}
@@ -0,0 +1,28 @@
+package revocation
+
+import (
+	"backend/token"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store remembers revoked access tokens until they expire, AuthMiddleware
+// rejects the tokens it reports as revoked
+type Store interface {
+	// RevokeToken revokes the token with the id, it is kept until expiresAt
+	RevokeToken(ctx context.Context, tokenID uuid.UUID, expiresAt time.Time) error
+
+	// RevokeSessionTokens revokes every token issued for the session, it is
+	// kept until expiresAt when those tokens have expired
+	RevokeSessionTokens(ctx context.Context, sessionID uuid.UUID, expiresAt time.Time) error
+
+	// RevokeUserTokens revokes every token of the user issued before
+	// issuedBefore, it is kept until expiresAt when those tokens have expired
+	RevokeUserTokens(ctx context.Context, userID int64, issuedBefore time.Time, expiresAt time.Time) error
+
+	// IsRevoked reports whether the token was revoked by its id or by a
+	// revocation of its session or user
+	IsRevoked(ctx context.Context, payload *token.Payload) (bool, error)
+}
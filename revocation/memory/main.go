@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"backend/token"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type userRevocation struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+// MemoryStore keeps the revocations in memory, they are lost on restart and
+// not shared between replicas, so it is meant for local development and tests
+type MemoryStore struct {
+	mu       sync.Mutex
+	tokens   map[uuid.UUID]time.Time
+	sessions map[uuid.UUID]time.Time
+	users    map[int64]userRevocation
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tokens:   make(map[uuid.UUID]time.Time),
+		sessions: make(map[uuid.UUID]time.Time),
+		users:    make(map[int64]userRevocation),
+	}
+}
+
+func (s *MemoryStore) RevokeToken(ctx context.Context, tokenID uuid.UUID, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteExpired(time.Now())
+	if expiresAt.After(s.tokens[tokenID]) {
+		s.tokens[tokenID] = expiresAt
+	}
+	return nil
+}
+
+func (s *MemoryStore) RevokeSessionTokens(ctx context.Context, sessionID uuid.UUID, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteExpired(time.Now())
+	if expiresAt.After(s.sessions[sessionID]) {
+		s.sessions[sessionID] = expiresAt
+	}
+	return nil
+}
+
+func (s *MemoryStore) RevokeUserTokens(ctx context.Context, userID int64, issuedBefore time.Time, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteExpired(time.Now())
+	revocation := s.users[userID]
+	if issuedBefore.After(revocation.issuedBefore) {
+		revocation.issuedBefore = issuedBefore
+	}
+	if expiresAt.After(revocation.expiresAt) {
+		revocation.expiresAt = expiresAt
+	}
+	s.users[userID] = revocation
+	return nil
+}
+
+func (s *MemoryStore) IsRevoked(ctx context.Context, payload *token.Payload) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokens[payload.ID]; ok {
+		return true, nil
+	}
+	if _, ok := s.sessions[payload.SessionID]; ok && payload.SessionID != uuid.Nil {
+		return true, nil
+	}
+	revocation, ok := s.users[payload.UserID]
+	return ok && payload.IssuedAt.Before(revocation.issuedBefore), nil
+}
+
+// deleteExpired forgets the revocations of tokens that have expired, it runs
+// on every revocation so the maps only hold live tokens
+func (s *MemoryStore) deleteExpired(now time.Time) {
+	for tokenID, expiresAt := range s.tokens {
+		if expiresAt.Before(now) {
+			delete(s.tokens, tokenID)
+		}
+	}
+	for sessionID, expiresAt := range s.sessions {
+		if expiresAt.Before(now) {
+			delete(s.sessions, sessionID)
+		}
+	}
+	for userID, revocation := range s.users {
+		if revocation.expiresAt.Before(now) {
+			delete(s.users, userID)
+		}
+	}
+}
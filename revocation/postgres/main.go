@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"backend/db"
+	"backend/revocation"
+	"backend/token"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps the revocations in the database, so every replica and
+// the command line share them
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(pool *pgxpool.Pool) revocation.Store {
+	return &PostgresStore{pool: pool}
+}
+
+func (s *PostgresStore) RevokeToken(ctx context.Context, tokenID uuid.UUID, expiresAt time.Time) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	repo := db.New(conn)
+	now := time.Now()
+
+	// revocations of expired tokens are not needed anymore
+	err = repo.DeleteExpiredRevokedAccessTokens(ctx, pgtype.Timestamptz{Time: now, Valid: true})
+	if err != nil {
+		slog.WarnContext(ctx, "could not delete expired token revocations", slog.Any("error", err))
+	}
+
+	return repo.RevokeAccessToken(ctx, db.RevokeAccessTokenParams{
+		TokenID:   pgtype.UUID{Bytes: tokenID, Valid: true},
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		CreatedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+}
+
+func (s *PostgresStore) RevokeSessionTokens(ctx context.Context, sessionID uuid.UUID, expiresAt time.Time) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	repo := db.New(conn)
+	now := time.Now()
+
+	err = repo.DeleteExpiredRevokedSessionAccessTokens(ctx, pgtype.Timestamptz{Time: now, Valid: true})
+	if err != nil {
+		slog.WarnContext(ctx, "could not delete expired session token revocations", slog.Any("error", err))
+	}
+
+	return repo.RevokeSessionAccessTokens(ctx, db.RevokeSessionAccessTokensParams{
+		SessionID: pgtype.UUID{Bytes: sessionID, Valid: true},
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		CreatedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+}
+
+func (s *PostgresStore) RevokeUserTokens(ctx context.Context, userID int64, issuedBefore time.Time, expiresAt time.Time) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	repo := db.New(conn)
+	now := time.Now()
+
+	err = repo.DeleteExpiredRevokedUserAccessTokens(ctx, pgtype.Timestamptz{Time: now, Valid: true})
+	if err != nil {
+		slog.WarnContext(ctx, "could not delete expired user token revocations", slog.Any("error", err))
+	}
+
+	return repo.RevokeUserAccessTokens(ctx, db.RevokeUserAccessTokensParams{
+		UserID:       userID,
+		IssuedBefore: pgtype.Timestamptz{Time: issuedBefore, Valid: true},
+		ExpiresAt:    pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		CreatedAt:    pgtype.Timestamptz{Time: now, Valid: true},
+	})
+}
+
+func (s *PostgresStore) IsRevoked(ctx context.Context, payload *token.Payload) (bool, error) {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+
+	// tokens without a session have a null sid, which matches no revocation
+	return db.New(conn).IsAccessTokenRevoked(ctx, db.IsAccessTokenRevokedParams{
+		TokenID:   pgtype.UUID{Bytes: payload.ID, Valid: true},
+		SessionID: pgtype.UUID{Bytes: payload.SessionID, Valid: payload.SessionID != uuid.Nil},
+		UserID:    payload.UserID,
+		IssuedAt:  pgtype.Timestamptz{Time: payload.IssuedAt, Valid: true},
+	})
+}
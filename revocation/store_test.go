@@ -0,0 +1,198 @@
+package revocation_test
+
+import (
+	"backend/db"
+	"backend/db/dbtest"
+	"backend/revocation"
+	"backend/revocation/memory"
+	"backend/revocation/postgres"
+	"backend/token"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// testStore is a store with a way to create the users its revocations may
+// refer to
+type testStore struct {
+	revocation.Store
+	newUser func(t *testing.T) int64
+}
+
+var stores = []struct {
+	name string
+	open func(t *testing.T) testStore
+}{
+	{
+		name: "memory",
+		open: func(t *testing.T) testStore {
+			var lastUserID int64
+			return testStore{
+				Store: memory.NewMemoryStore(),
+				newUser: func(t *testing.T) int64 {
+					lastUserID++
+					return lastUserID
+				},
+			}
+		},
+	},
+	{
+		name: "postgres",
+		open: func(t *testing.T) testStore {
+			pool := dbtest.NewPool(t)
+			return testStore{
+				Store: postgres.NewPostgresStore(pool),
+				newUser: func(t *testing.T) int64 {
+					// the user revocations reference the users table
+					now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+					userID, err := db.New(pool).CreateUser(context.Background(), db.CreateUserParams{
+						Name:      "Test User",
+						Email:     fmt.Sprintf("%s@example.com", uuid.NewString()),
+						TokenHash: "token-hash",
+						CreatedAt: now,
+						UpdatedAt: now,
+					})
+					if err != nil {
+						t.Fatalf("could not create user: %v", err)
+					}
+					return userID
+				},
+			}
+		},
+	},
+}
+
+// forEachStore runs the test against every store implementation
+func forEachStore(t *testing.T, test func(t *testing.T, store testStore)) {
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			test(t, tt.open(t))
+		})
+	}
+}
+
+func newPayload(userID int64, sessionID uuid.UUID, issuedAt time.Time) *token.Payload {
+	return &token.Payload{
+		ID:        uuid.New(),
+		UserID:    userID,
+		SessionID: sessionID,
+		IssuedAt:  issuedAt,
+		ExpiredAt: issuedAt.Add(15 * time.Minute),
+	}
+}
+
+func assertRevoked(t *testing.T, store revocation.Store, payload *token.Payload, want bool) {
+	t.Helper()
+
+	revoked, err := store.IsRevoked(context.Background(), payload)
+	if err != nil {
+		t.Fatalf("could not check revocation: %v", err)
+	}
+	if revoked != want {
+		t.Errorf("revoked = %t, want %t", revoked, want)
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store testStore) {
+		ctx := context.Background()
+		userID := store.newUser(t)
+		revoked := newPayload(userID, uuid.New(), time.Now())
+		other := newPayload(userID, revoked.SessionID, time.Now())
+
+		if err := store.RevokeToken(ctx, revoked.ID, revoked.ExpiredAt); err != nil {
+			t.Fatalf("could not revoke token: %v", err)
+		}
+
+		assertRevoked(t, store, revoked, true)
+		assertRevoked(t, store, other, false)
+	})
+}
+
+func TestRevokeSessionTokens(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store testStore) {
+		ctx := context.Background()
+		userID := store.newUser(t)
+		sessionID := uuid.New()
+
+		if err := store.RevokeSessionTokens(ctx, sessionID, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("could not revoke session tokens: %v", err)
+		}
+
+		assertRevoked(t, store, newPayload(userID, sessionID, time.Now().Add(-time.Minute)), true)
+		assertRevoked(t, store, newPayload(userID, sessionID, time.Now()), true)
+		assertRevoked(t, store, newPayload(userID, uuid.New(), time.Now()), false)
+
+		// tokens without a session are not matched by a session revocation
+		assertRevoked(t, store, newPayload(userID, uuid.Nil, time.Now()), false)
+	})
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store testStore) {
+		ctx := context.Background()
+		userID := store.newUser(t)
+		otherUserID := store.newUser(t)
+		issuedBefore := time.Now()
+
+		err := store.RevokeUserTokens(ctx, userID, issuedBefore, issuedBefore.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("could not revoke user tokens: %v", err)
+		}
+
+		assertRevoked(t, store, newPayload(userID, uuid.New(), issuedBefore.Add(-time.Minute)), true)
+		assertRevoked(t, store, newPayload(userID, uuid.New(), issuedBefore.Add(time.Minute)), false)
+		assertRevoked(t, store, newPayload(otherUserID, uuid.New(), issuedBefore.Add(-time.Minute)), false)
+
+		// an earlier issuedBefore does not take back the later one
+		err = store.RevokeUserTokens(ctx, userID, issuedBefore.Add(-time.Hour), issuedBefore.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("could not revoke user tokens: %v", err)
+		}
+		assertRevoked(t, store, newPayload(userID, uuid.New(), issuedBefore.Add(-time.Minute)), true)
+	})
+}
+
+func TestExpiredRevocationsArePruned(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store testStore) {
+		ctx := context.Background()
+		userID := store.newUser(t)
+		expired := time.Now().Add(-time.Minute)
+		later := time.Now().Add(time.Hour)
+
+		// an expired entry is kept until the next revocation of its kind
+		revoked := newPayload(userID, uuid.New(), time.Now().Add(-time.Hour))
+		if err := store.RevokeToken(ctx, revoked.ID, expired); err != nil {
+			t.Fatalf("could not revoke token: %v", err)
+		}
+		assertRevoked(t, store, revoked, true)
+		if err := store.RevokeToken(ctx, uuid.New(), later); err != nil {
+			t.Fatalf("could not revoke token: %v", err)
+		}
+		assertRevoked(t, store, revoked, false)
+
+		revoked = newPayload(userID, uuid.New(), time.Now().Add(-time.Hour))
+		if err := store.RevokeSessionTokens(ctx, revoked.SessionID, expired); err != nil {
+			t.Fatalf("could not revoke session tokens: %v", err)
+		}
+		assertRevoked(t, store, revoked, true)
+		if err := store.RevokeSessionTokens(ctx, uuid.New(), later); err != nil {
+			t.Fatalf("could not revoke session tokens: %v", err)
+		}
+		assertRevoked(t, store, revoked, false)
+
+		revoked = newPayload(userID, uuid.New(), time.Now().Add(-time.Hour))
+		if err := store.RevokeUserTokens(ctx, userID, time.Now().Add(-30*time.Minute), expired); err != nil {
+			t.Fatalf("could not revoke user tokens: %v", err)
+		}
+		assertRevoked(t, store, revoked, true)
+		if err := store.RevokeUserTokens(ctx, store.newUser(t), time.Now(), later); err != nil {
+			t.Fatalf("could not revoke user tokens: %v", err)
+		}
+		assertRevoked(t, store, revoked, false)
+	})
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"backend/api/middleware"
+	"backend/db"
+	"backend/dto"
+	"backend/token"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RevokeAccessToken revokes the access token the request was made with, it is
+// rejected from now on even though it has not expired
+func (s *userService) RevokeAccessToken(ctx context.Context) error {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	err := s.revocations.RevokeToken(ctx, currentUser.ID, currentUser.ExpiredAt.Add(s.config.TOKEN.LEEWAY*time.Second))
+	if err != nil {
+		slog.ErrorContext(ctx, "could not revoke access token", slog.Any("error", err))
+		return dto.NewError("could not revoke access token")
+	}
+
+	slog.InfoContext(ctx, "access token revoked", slog.String("token_id", currentUser.ID.String()))
+	return nil
+}
+
+// RevokeAccessTokens revokes every access token of the user issued before
+// issuedBefore, a zero or future time revokes all tokens issued so far
+func (s *userService) RevokeAccessTokens(ctx context.Context, userID int64, issuedBefore time.Time) error {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	if currentUser.UserID != userID {
+		return dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeUserNotFound, "user not found")
+	}
+
+	if err := s.revokeAccessTokens(ctx, userID, issuedBefore); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "access tokens revoked", slog.Int64("userID", userID))
+	return nil
+}
+
+// AdminRevokeAccessToken revokes the access token with the id, the expiry of
+// the token is unknown so the revocation is kept for a full token lifetime
+func (s *userService) AdminRevokeAccessToken(ctx context.Context, tokenID string) (*dto.AdminTokenResponse, error) {
+	id, err := uuid.Parse(tokenID)
+	if err != nil {
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidPayload, "token id must be a uuid")
+	}
+
+	err = s.revocations.RevokeToken(ctx, id, time.Now().Add(s.accessTokenLifetime()))
+	if err != nil {
+		slog.ErrorContext(ctx, "could not revoke access token", slog.Any("error", err))
+		return nil, dto.NewError("could not revoke access token")
+	}
+
+	slog.InfoContext(ctx, "access token revoked by admin", slog.String("token_id", tokenID))
+	return &dto.AdminTokenResponse{ID: id.String(), Action: "revoked"}, nil
+}
+
+// AdminRevokeAccessTokens revokes every access token of the user issued before
+// issuedBefore, a zero or future time revokes all tokens issued so far
+func (s *userService) AdminRevokeAccessTokens(ctx context.Context, userID int64, issuedBefore time.Time) (*dto.AdminUserResponse, error) {
+	var email string
+	err := s.adminUpdateUser(ctx, userID, func(repo *db.Queries, user db.GetUserSecretsRow, now pgtype.Timestamptz) error {
+		email = user.Email
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.revokeAccessTokens(ctx, userID, issuedBefore); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "access tokens revoked by admin", slog.Int64("userID", userID))
+	return &dto.AdminUserResponse{ID: userID, Email: email, Action: "tokens_revoked"}, nil
+}
+
+// revokeAccessTokens revokes the access tokens of the user issued before
+// issuedBefore, the revocation is kept until the last of them has expired.
+// Tokens cannot be issued in the future, so later times are capped at now
+func (s *userService) revokeAccessTokens(ctx context.Context, userID int64, issuedBefore time.Time) error {
+	now := time.Now()
+	if issuedBefore.IsZero() || issuedBefore.After(now) {
+		issuedBefore = now
+	}
+
+	err := s.revocations.RevokeUserTokens(ctx, userID, issuedBefore, issuedBefore.Add(s.accessTokenLifetime()))
+	if err != nil {
+		slog.ErrorContext(ctx, "could not revoke access tokens", slog.Int64("userID", userID), slog.Any("error", err))
+		return dto.NewError("could not revoke access tokens")
+	}
+	return nil
+}
+
+// revokeSessionAccessTokens revokes the access tokens issued for the session,
+// the revocation is kept until the last of them has expired
+func (s *userService) revokeSessionAccessTokens(ctx context.Context, sessionID uuid.UUID) error {
+	err := s.revocations.RevokeSessionTokens(ctx, sessionID, time.Now().Add(s.accessTokenLifetime()))
+	if err != nil {
+		slog.ErrorContext(ctx, "could not revoke session access tokens", slog.String("session_id", sessionID.String()), slog.Any("error", err))
+		return dto.NewError("could not revoke access tokens")
+	}
+	return nil
+}
+
+// accessTokenLifetime is how long an access token is accepted after it was
+// issued, the leeway included
+func (s *userService) accessTokenLifetime() time.Duration {
+	return (s.config.TOKEN.ACCESS_TOKEN_DURATION + s.config.TOKEN.LEEWAY) * time.Second
+}
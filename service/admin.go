@@ -0,0 +1,231 @@
+package service
+
+import (
+	"backend/api/apiUtils"
+	"backend/db"
+	"backend/dto"
+	platformService "backend/service/platform"
+	"backend/utils"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// the admin methods are used by the command line, they skip the checks on the
+// logged in user so they must not be exposed through the api
+
+// AdminFindUser returns the id of the user with the email
+func (s *userService) AdminFindUser(ctx context.Context, email string) (int64, error) {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return 0, err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	userID, err := repo.GetUserIDByEmail(ctx, email)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, dto.NewErrorWithCode(http.StatusNotFound, dto.ErrCodeUserNotFound, "user not found")
+		}
+		slog.ErrorContext(ctx, "could not get user", slog.Any("error", err))
+		return 0, dto.NewError("could not get user")
+	}
+
+	return userID, nil
+}
+
+// AdminCreateUser creates a user that logs in with a password
+func (s *userService) AdminCreateUser(ctx context.Context, request *dto.AdminCreateUserRequest) (*dto.AdminUserResponse, error) {
+	err := apiUtils.ValidateStruct(request)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := utils.HashPassword(request.Password)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not hash password", slog.Any("error", err))
+		return nil, dto.NewError("could not hash password")
+	}
+
+	userID, err := s.CreateDbUser(ctx, &db.CreateUserParams{
+		Name:          request.Name,
+		Email:         request.Email,
+		Password:      hashedPassword,
+		Picture:       request.Picture,
+		EmailVerified: request.EmailVerified,
+	}, &platformService.Identity{Subject: request.Email, Email: request.Email}, s.AuthKey())
+	if err != nil {
+		return nil, err
+	}
+
+	if !request.EmailVerified {
+		if err = s.sendVerificationEmail(ctx, userID, request.Email); err != nil {
+			slog.ErrorContext(ctx, "could not send verification email", slog.Int64("userID", userID), slog.Any("error", err))
+		}
+	}
+
+	slog.InfoContext(ctx, "user created by admin", slog.Int64("userID", userID))
+	return &dto.AdminUserResponse{ID: userID, Email: request.Email, Action: "created"}, nil
+}
+
+// AdminDisableUser blocks the login of the user and ends all their sessions
+// and access tokens
+func (s *userService) AdminDisableUser(ctx context.Context, userID int64) (*dto.AdminUserResponse, error) {
+	var email string
+	err := s.adminUpdateUser(ctx, userID, func(repo *db.Queries, user db.GetUserSecretsRow, now pgtype.Timestamptz) error {
+		email = user.Email
+		if user.DisabledAt.Valid {
+			return nil
+		}
+
+		_, err := repo.DisableUser(ctx, db.DisableUserParams{
+			ID:         userID,
+			DisabledAt: now,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "could not disable user", slog.Int64("userID", userID), slog.Any("error", err))
+			return err
+		}
+
+		return revokeAllSessions(ctx, repo, userID, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.revokeAccessTokens(ctx, userID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "user disabled by admin", slog.Int64("userID", userID))
+	return &dto.AdminUserResponse{ID: userID, Email: email, Action: "disabled"}, nil
+}
+
+// AdminSetPassword replaces the password and ends all sessions and access
+// tokens, users without a password get the password login linked
+func (s *userService) AdminSetPassword(ctx context.Context, userID int64, password string) (*dto.AdminUserResponse, error) {
+	if len(password) < 8 || len(password) > 255 {
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeWeakPassword, "password must be between 8 and 255 characters")
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not hash password", slog.Any("error", err))
+		return nil, dto.NewError("could not hash password")
+	}
+
+	var email string
+	err = s.adminUpdateUser(ctx, userID, func(repo *db.Queries, user db.GetUserSecretsRow, now pgtype.Timestamptz) error {
+		email = user.Email
+		err := repo.UpdatePassword(ctx, db.UpdatePasswordParams{
+			ID:        userID,
+			Password:  hashedPassword,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "could not update password", slog.Int64("userID", userID), slog.Any("error", err))
+			return err
+		}
+
+		_, err = repo.GetUserIdentity(ctx, db.GetUserIdentityParams{
+			Provider: s.AuthKey(),
+			Subject:  user.Email,
+		})
+		if err == pgx.ErrNoRows {
+			err = s.createUserIdentity(ctx, repo, userID, s.AuthKey(), &platformService.Identity{Subject: user.Email, Email: user.Email})
+		}
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return dto.NewErrorWithCode(http.StatusConflict, dto.ErrCodeProviderAlreadyLinked, "another password login is linked to the user")
+			}
+			slog.ErrorContext(ctx, "could not link password login", slog.Int64("userID", userID), slog.Any("error", err))
+			return err
+		}
+
+		return revokeAllSessions(ctx, repo, userID, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.revokeAccessTokens(ctx, userID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "password set by admin", slog.Int64("userID", userID))
+	return &dto.AdminUserResponse{ID: userID, Email: email, Action: "password_set"}, nil
+}
+
+// AdminRevokeSessions ends every session and access token of the user
+func (s *userService) AdminRevokeSessions(ctx context.Context, userID int64) (*dto.AdminUserResponse, error) {
+	var email string
+	err := s.adminUpdateUser(ctx, userID, func(repo *db.Queries, user db.GetUserSecretsRow, now pgtype.Timestamptz) error {
+		email = user.Email
+		return revokeAllSessions(ctx, repo, userID, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.revokeAccessTokens(ctx, userID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "sessions revoked by admin", slog.Int64("userID", userID))
+	return &dto.AdminUserResponse{ID: userID, Email: email, Action: "sessions_revoked"}, nil
+}
+
+// adminUpdateUser runs update in a transaction for an existing user, errors
+// that are not a dto error become a generic one
+func (s *userService) adminUpdateUser(ctx context.Context, userID int64, update func(repo *db.Queries, user db.GetUserSecretsRow, now pgtype.Timestamptz) error) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not start transaction", slog.Any("error", err))
+		return dto.NewError("could not update user")
+	}
+	defer tx.Rollback(ctx)
+
+	repo := db.New(conn).WithTx(tx)
+	user, err := repo.GetUserSecrets(ctx, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return dto.NewErrorWithCode(http.StatusNotFound, dto.ErrCodeUserNotFound, "user not found")
+		}
+		slog.ErrorContext(ctx, "could not get user", slog.Any("error", err))
+		return dto.NewError("could not get user")
+	}
+
+	err = update(repo, user, pgtype.Timestamptz{Time: time.Now(), Valid: true})
+	if err != nil {
+		var dtoErr *dto.Error
+		if errors.As(err, &dtoErr) {
+			return err
+		}
+		return dto.NewError("could not update user")
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		slog.ErrorContext(ctx, "could not commit user update", slog.Any("error", err))
+		return dto.NewError("could not update user")
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"backend/api/middleware"
+	"backend/dto"
+	"backend/token"
+	"backend/utils"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// emailLinkToken requests a login link and returns its token
+func (s *testService) emailLinkToken(t *testing.T, email string) string {
+	t.Helper()
+
+	if err := s.emailLink.RequestLogin(context.Background(), &dto.EmailLinkRequest{Email: email}); err != nil {
+		t.Fatalf("could not request login link: %v", err)
+	}
+	sent := s.mails.SentTo(email)
+	if len(sent) == 0 {
+		t.Fatalf("no login link was sent to %s", email)
+	}
+	return linkToken(t, sent[len(sent)-1])
+}
+
+func (s *testService) emailLinkLogin(t *testing.T, loginToken string) (*dto.LoginResponse, error) {
+	t.Helper()
+
+	payload, err := json.Marshal(dto.EmailLinkPayload{Token: loginToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, _, err := s.Login(context.Background(), &dto.LoginRequest{
+		Provider:   s.emailLink.AuthKey(),
+		Payload:    string(payload),
+		DeviceName: "test",
+	})
+	return response, err
+}
+
+func TestEmailLinkLoginSignsUpUnknownEmail(t *testing.T) {
+	s := newTestService(t)
+	loginToken := s.emailLinkToken(t, "new@example.com")
+
+	tokens, err := s.emailLinkLogin(t, loginToken)
+	if err != nil {
+		t.Fatalf("could not login with email link: %v", err)
+	}
+
+	ctx := s.accessContext(t, tokens.AccessToken)
+	userID := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload).UserID
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		t.Fatalf("could not get user: %v", err)
+	}
+	if user.Email != "new@example.com" || user.Name != "new" || !user.EmailVerified {
+		t.Errorf("user = %+v, want a verified user named after the email", user)
+	}
+
+	// the link is used up, the next login needs a new one
+	_, err = s.emailLinkLogin(t, loginToken)
+	assertErrorCode(t, err, dto.ErrCodeLoginLinkInvalid)
+
+	if _, err = s.emailLinkLogin(t, s.emailLinkToken(t, "new@example.com")); err != nil {
+		t.Fatalf("could not login to the created account: %v", err)
+	}
+}
+
+func TestEmailLinkLoginWithoutSignUp(t *testing.T) {
+	s := newTestServiceWithConfig(t, func(config *utils.Config) {
+		config.EMAIL_LINK.ALLOW_SIGN_UP = false
+	})
+
+	// no link is mailed to an unknown email, so a made up one is tried
+	if err := s.emailLink.RequestLogin(context.Background(), &dto.EmailLinkRequest{Email: "closed@example.com"}); err != nil {
+		t.Fatalf("could not request login link: %v", err)
+	}
+	if sent := s.mails.SentTo("closed@example.com"); len(sent) != 0 {
+		t.Fatalf("sent %d login mails to an unknown email, want none", len(sent))
+	}
+
+	_, err := s.emailLinkLogin(t, "not-a-real-token")
+	if err == nil {
+		t.Fatal("logged in without a valid link")
+	}
+	if _, err = s.AdminFindUser(context.Background(), "closed@example.com"); err == nil {
+		t.Error("an account was created while sign up is disabled")
+	}
+}
+
+func TestEmailLinkLoginDoesNotTakeOverExistingAccount(t *testing.T) {
+	s := newTestService(t)
+	s.signUp(t, "taken@example.com", "password123")
+	loginToken := s.emailLinkToken(t, "taken@example.com")
+
+	_, err := s.emailLinkLogin(t, loginToken)
+	assertErrorCode(t, err, dto.ErrCodeAccountNotFound)
+}
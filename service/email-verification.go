@@ -0,0 +1,124 @@
+package service
+
+import (
+	"backend/api/middleware"
+	"backend/db"
+	"backend/dto"
+	"backend/mailer"
+	"backend/token"
+	"backend/utils"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	tokenPurposeEmailVerification = "email_verification"
+
+	defaultVerificationTokenDuration = 24 * time.Hour
+)
+
+func (s *userService) VerifyEmail(ctx context.Context, request *dto.VerifyEmailRequest) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	now := time.Now()
+	userToken, err := repo.ConsumeUserToken(ctx, db.ConsumeUserTokenParams{
+		Purpose:   tokenPurposeEmailVerification,
+		TokenHash: utils.HashToken(request.Token),
+		UsedAt:    pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			slog.InfoContext(ctx, "verification token is invalid, expired or already used")
+			return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeVerificationTokenInvalid, "verification token is invalid or expired")
+		}
+		slog.ErrorContext(ctx, "could not consume verification token", slog.Any("error", err))
+		return dto.NewError("could not verify email")
+	}
+
+	err = repo.VerifyUserEmail(ctx, db.VerifyUserEmailParams{
+		ID:        userToken.UserID,
+		Email:     userToken.Email,
+		UpdatedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not mark email as verified", slog.Int64("userID", userToken.UserID), slog.Any("error", err))
+		return dto.NewError("could not verify email")
+	}
+
+	slog.InfoContext(ctx, "email verified", slog.Int64("userID", userToken.UserID))
+	return nil
+}
+
+func (s *userService) ResendVerificationEmail(ctx context.Context, userID int64) error {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	if currentUser.UserID != userID {
+		return dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeUserNotFound, "user not found")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	user, err := repo.GetUser(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get user", slog.Any("error", err))
+		return dto.NewError("could not get user")
+	}
+
+	if user.EmailVerified {
+		return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeEmailVerified, "email is already verified")
+	}
+
+	err = s.checkUserTokenSendLimit(ctx, userID, tokenPurposeEmailVerification, s.config.EMAIL_VERIFICATION)
+	if err != nil {
+		return err
+	}
+
+	return s.sendVerificationEmail(ctx, userID, user.Email)
+}
+
+func (s *userService) sendVerificationEmail(ctx context.Context, userID int64, email string) error {
+	duration := userTokenDuration(s.config.EMAIL_VERIFICATION, defaultVerificationTokenDuration)
+	verificationToken, err := s.createUserToken(ctx, userID, email, tokenPurposeEmailVerification, duration)
+	if err != nil {
+		return err
+	}
+
+	link, err := utils.LinkWithToken(s.config.EMAIL_VERIFICATION.URL, verificationToken)
+	if err != nil {
+		slog.ErrorContext(ctx, "email verification url is invalid", slog.Any("error", err))
+		return dto.NewError("could not send verification email")
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Open the link below to verify your email address:\n\n%s\n\nThe link expires in %s. If you did not create an account you can ignore this email.\n",
+			link, duration),
+	})
+	if err != nil {
+		return dto.NewError("could not send verification email")
+	}
+
+	slog.InfoContext(ctx, "verification email sent", slog.Int64("userID", userID))
+	return nil
+}
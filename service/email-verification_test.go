@@ -0,0 +1,117 @@
+package service
+
+import (
+	"backend/dto"
+	"context"
+	"testing"
+)
+
+func (s *testService) emailVerified(t *testing.T, userID int64) bool {
+	t.Helper()
+
+	user, err := s.GetUser(authContext(userID), userID)
+	if err != nil {
+		t.Fatalf("could not get user: %v", err)
+	}
+	return user.EmailVerified
+}
+
+// backdateUserTokens moves the tokens of the user out of the resend interval
+func (s *testService) backdateUserTokens(t *testing.T, userID int64) {
+	t.Helper()
+
+	_, err := s.pool.Exec(context.Background(), "UPDATE user_tokens SET created_at = created_at - interval '1 hour' WHERE user_id = $1", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignUpSendsVerificationEmail(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "signup@example.com", "password123")
+
+	sent := s.mails.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d mails, want 1", len(sent))
+	}
+	if sent[0].To != "signup@example.com" || sent[0].Subject != "Verify your email" {
+		t.Errorf("sent %q to %s, want the verification mail to signup@example.com", sent[0].Subject, sent[0].To)
+	}
+	linkToken(t, sent[0])
+
+	if s.emailVerified(t, userID) {
+		t.Error("email is verified before the link was used")
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "verify@example.com", "password123")
+	verificationToken := linkToken(t, s.mails.SentTo("verify@example.com")[0])
+
+	err := s.VerifyEmail(context.Background(), &dto.VerifyEmailRequest{Token: verificationToken})
+	if err != nil {
+		t.Fatalf("could not verify email: %v", err)
+	}
+	if !s.emailVerified(t, userID) {
+		t.Error("email is not verified")
+	}
+
+	err = s.VerifyEmail(context.Background(), &dto.VerifyEmailRequest{Token: verificationToken})
+	assertErrorCode(t, err, dto.ErrCodeVerificationTokenInvalid)
+
+	err = s.ResendVerificationEmail(authContext(userID), userID)
+	assertErrorCode(t, err, dto.ErrCodeEmailVerified)
+	if sent := s.mails.SentTo("verify@example.com"); len(sent) != 1 {
+		t.Errorf("sent %d mails to a verified email, want only the first one", len(sent))
+	}
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "resend@example.com", "password123")
+	firstToken := linkToken(t, s.mails.SentTo("resend@example.com")[0])
+
+	err := s.ResendVerificationEmail(authContext(userID), userID)
+	assertErrorCode(t, err, dto.ErrCodeEmailRateLimited)
+	if sent := s.mails.SentTo("resend@example.com"); len(sent) != 1 {
+		t.Fatalf("sent %d mails within the resend interval, want 1", len(sent))
+	}
+
+	s.backdateUserTokens(t, userID)
+	if err = s.ResendVerificationEmail(authContext(userID), userID); err != nil {
+		t.Fatalf("could not resend verification email: %v", err)
+	}
+
+	sent := s.mails.SentTo("resend@example.com")
+	if len(sent) != 2 {
+		t.Fatalf("sent %d mails, want 2", len(sent))
+	}
+	secondToken := linkToken(t, sent[1])
+	if secondToken == firstToken {
+		t.Fatal("the resent mail has the same token")
+	}
+
+	// only the newest link stays usable
+	err = s.VerifyEmail(context.Background(), &dto.VerifyEmailRequest{Token: firstToken})
+	assertErrorCode(t, err, dto.ErrCodeVerificationTokenInvalid)
+
+	if err = s.VerifyEmail(context.Background(), &dto.VerifyEmailRequest{Token: secondToken}); err != nil {
+		t.Fatalf("could not verify email with the resent link: %v", err)
+	}
+	if !s.emailVerified(t, userID) {
+		t.Error("email is not verified")
+	}
+}
+
+func TestResendVerificationEmailOfAnotherUser(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "owner@example.com", "password123")
+	s.backdateUserTokens(t, userID)
+
+	err := s.ResendVerificationEmail(authContext(userID+1), userID)
+	assertErrorCode(t, err, dto.ErrCodeUserNotFound)
+	if sent := s.mails.Sent(); len(sent) != 1 {
+		t.Errorf("sent %d mails, want only the sign up mail", len(sent))
+	}
+}
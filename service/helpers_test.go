@@ -0,0 +1,205 @@
+package service
+
+import (
+	"backend/api/middleware"
+	"backend/db/dbtest"
+	"backend/dto"
+	"backend/mailer"
+	"backend/mailer/memory"
+	revocationMemory "backend/revocation/memory"
+	platformService "backend/service/platform"
+	"backend/token"
+	"backend/utils"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+// testService is a user service on a test database, mails are kept in memory
+type testService struct {
+	*userService
+	emailLink   platformService.EmailLinkService
+	mails       *memory.MemoryMailer
+	revocations *revocationMemory.MemoryStore
+}
+
+func newTestService(t *testing.T) *testService {
+	t.Helper()
+	return newTestServiceWithConfig(t, func(*utils.Config) {})
+}
+
+// newTestServiceWithConfig lets the test change the config before the
+// services are created
+func newTestServiceWithConfig(t *testing.T, configure func(*utils.Config)) *testService {
+	t.Helper()
+
+	pool := dbtest.NewPool(t)
+	mails := memory.NewMemoryMailer()
+	revocations := revocationMemory.NewMemoryStore()
+
+	config := utils.Config{
+		TOKEN: utils.TokenConfig{
+			ACCESS_TOKEN_DURATION:  900,
+			REFRESH_TOKEN_DURATION: 3600,
+		},
+		EMAIL_VERIFICATION: utils.UserTokenConfig{URL: "https://example.com/verify-email"},
+		PASSWORD_RESET:     utils.UserTokenConfig{URL: "https://example.com/reset-password"},
+		EMAIL_LINK: utils.EmailLinkConfig{
+			UserTokenConfig: utils.UserTokenConfig{URL: "https://example.com/email-login"},
+			ALLOW_SIGN_UP:   true,
+		},
+	}
+	configure(&config)
+
+	emailLink := platformService.NewEmailLinkService(pool, mails, config.EMAIL_LINK)
+	service := NewUserService(pool, newTestTokenMaker(t), revocations, mails, config, []platformService.AuthPlatform{emailLink})
+	return &testService{
+		userService: service.(*userService),
+		emailLink:   emailLink,
+		mails:       mails,
+		revocations: revocations,
+	}
+}
+
+func newTestTokenMaker(t *testing.T) token.Maker {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessKeys, err := token.NewKeyring(token.AlgorithmEdDSA, []token.Key{{ID: "access", Private: private, Public: public}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+	refreshKeys, err := token.NewKeyring(token.AlgorithmHS256, []token.Key{{ID: "refresh", Private: secret, Public: secret}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	maker, err := token.NewJWTMaker("backend.user", accessKeys, refreshKeys, 900, 3600, token.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return maker
+}
+
+// signUp creates a user with a password and returns its id
+func (s *testService) signUp(t *testing.T, email string, password string) int64 {
+	t.Helper()
+
+	ctx := context.Background()
+	err := s.CreateUser(ctx, &dto.CreateUserRequest{
+		Provider: s.AuthKey(),
+		Payload:  map[string]any{"name": "Test User", "email": email, "password": password},
+	})
+	if err != nil {
+		t.Fatalf("could not sign up: %v", err)
+	}
+
+	userID, err := s.AdminFindUser(ctx, email)
+	if err != nil {
+		t.Fatalf("could not find user: %v", err)
+	}
+	return userID
+}
+
+func (s *testService) login(t *testing.T, email string, password string) *dto.LoginResponse {
+	t.Helper()
+
+	response, challenge, err := s.Login(context.Background(), &dto.LoginRequest{
+		Provider:   s.AuthKey(),
+		Payload:    email + "|" + password,
+		DeviceName: "test",
+	})
+	if err != nil {
+		t.Fatalf("could not login: %v", err)
+	}
+	if challenge != nil {
+		t.Fatal("login returned a two factor challenge")
+	}
+	return response
+}
+
+// authContext is the context AuthMiddleware gives the handlers
+func authContext(userID int64) context.Context {
+	return context.WithValue(context.Background(), middleware.AuthenticationPayloadKey, &token.Payload{UserID: userID})
+}
+
+// accessContext is authContext with the payload of a real access token
+func (s *testService) accessContext(t *testing.T, accessToken string) context.Context {
+	t.Helper()
+
+	payload, err := s.tokenMaker.ValidateAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("access token is invalid: %v", err)
+	}
+	return context.WithValue(context.Background(), middleware.AuthenticationPayloadKey, payload)
+}
+
+// accessTokenRevoked reports whether AuthMiddleware would reject the access
+// token as revoked
+func (s *testService) accessTokenRevoked(t *testing.T, accessToken string) bool {
+	t.Helper()
+
+	payload, err := s.tokenMaker.ValidateAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("access token is invalid: %v", err)
+	}
+	revoked, err := s.revocations.IsRevoked(context.Background(), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return revoked
+}
+
+// refreshContext is the context RefreshTokenValidateMiddleware gives the
+// handlers
+func (s *testService) refreshContext(t *testing.T, refreshToken string) context.Context {
+	t.Helper()
+
+	payload, err := s.tokenMaker.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		t.Fatalf("refresh token is invalid: %v", err)
+	}
+	return context.WithValue(context.Background(), middleware.RefreshTokenPayloadKey, payload)
+}
+
+var linkTokenPattern = regexp.MustCompile(`[?&]token=([^&\s]+)`)
+
+// linkToken returns the token of the link in the mail
+func linkToken(t *testing.T, message mailer.Message) string {
+	t.Helper()
+
+	match := linkTokenPattern.FindStringSubmatch(message.Body)
+	if match == nil {
+		t.Fatalf("mail %q has no link with a token", message.Subject)
+	}
+	value, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+// assertErrorCode fails unless err is a dto error with the code
+func assertErrorCode(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var dtoErr *dto.Error
+	if !errors.As(err, &dtoErr) {
+		t.Fatalf("error = %v, want code %s", err, code)
+	}
+	if dtoErr.GetErrorCode() != code {
+		t.Fatalf("error code = %s, want %s", dtoErr.GetErrorCode(), code)
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"backend/api/middleware"
+	"backend/db"
+	"backend/dto"
+	"backend/i18n"
+	"backend/token"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// UpdateLocale stores the language of the user's messages. It is read when
+// tokens are issued, so the current access token keeps the old one until the
+// next refresh
+func (s *userService) UpdateLocale(ctx context.Context, userID int64, request *dto.UpdateLocaleRequest) error {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	if currentUser.UserID != userID {
+		return dto.NewErrorWithCode(http.StatusNotFound, dto.ErrCodeUserNotFound, "user not found")
+	}
+
+	var locale *string
+	if request.Locale != "" {
+		tag, ok := i18n.Lookup(request.Locale)
+		if !ok {
+			return dto.NewErrorWithParams(http.StatusBadRequest, dto.ErrCodeLocaleUnsupported, "locale is not supported", map[string]string{"locale": request.Locale})
+		}
+		supported := tag.String()
+		locale = &supported
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	updated, err := repo.UpdateUserLocale(ctx, db.UpdateUserLocaleParams{
+		ID:        userID,
+		Locale:    locale,
+		UpdatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not update locale", slog.Any("error", err))
+		return dto.NewError("could not update locale")
+	}
+
+	if updated == 0 {
+		return dto.NewErrorWithCode(http.StatusNotFound, dto.ErrCodeUserNotFound, "user not found")
+	}
+
+	slog.InfoContext(ctx, "locale updated", slog.Int64("userID", userID), slog.Any("locale", locale))
+	return nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"backend/dto"
+	"testing"
+)
+
+// accessTokenLocale returns the locale claim of the access token
+func (s *testService) accessTokenLocale(t *testing.T, accessToken string) string {
+	t.Helper()
+
+	payload, err := s.tokenMaker.ValidateAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("access token is invalid: %v", err)
+	}
+	return payload.Locale
+}
+
+func TestUpdateLocaleIsInTheNextAccessToken(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "locale@example.com", "password123")
+	tokens := s.login(t, "locale@example.com", "password123")
+	if locale := s.accessTokenLocale(t, tokens.AccessToken); locale != "" {
+		t.Fatalf("locale = %q before one was stored", locale)
+	}
+
+	// region subtags are stored as the supported language
+	err := s.UpdateLocale(authContext(userID), userID, &dto.UpdateLocaleRequest{Locale: "de-AT"})
+	if err != nil {
+		t.Fatalf("could not update locale: %v", err)
+	}
+
+	user, err := s.GetUser(authContext(userID), userID)
+	if err != nil {
+		t.Fatalf("could not get user: %v", err)
+	}
+	if user.Locale == nil || *user.Locale != "de" {
+		t.Errorf("user locale = %v, want de", user.Locale)
+	}
+
+	refreshed, err := s.GenerateAccessToken(s.refreshContext(t, tokens.RefreshToken), dto.ClientInfo{})
+	if err != nil {
+		t.Fatalf("could not refresh: %v", err)
+	}
+	if locale := s.accessTokenLocale(t, refreshed.AccessToken); locale != "de" {
+		t.Errorf("refreshed token locale = %q, want de", locale)
+	}
+
+	// an empty locale goes back to the Accept-Language header
+	err = s.UpdateLocale(authContext(userID), userID, &dto.UpdateLocaleRequest{})
+	if err != nil {
+		t.Fatalf("could not clear locale: %v", err)
+	}
+	if locale := s.accessTokenLocale(t, s.login(t, "locale@example.com", "password123").AccessToken); locale != "" {
+		t.Errorf("locale = %q after it was cleared", locale)
+	}
+}
+
+func TestUpdateLocaleRejectsUnsupportedLocales(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "unsupported@example.com", "password123")
+
+	for _, locale := range []string{"fr", "not a locale"} {
+		err := s.UpdateLocale(authContext(userID), userID, &dto.UpdateLocaleRequest{Locale: locale})
+		assertErrorCode(t, err, dto.ErrCodeLocaleUnsupported)
+	}
+}
+
+func TestUpdateLocaleOfAnotherUser(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "owner-locale@example.com", "password123")
+
+	err := s.UpdateLocale(authContext(userID+1), userID, &dto.UpdateLocaleRequest{Locale: "de"})
+	assertErrorCode(t, err, dto.ErrCodeUserNotFound)
+}
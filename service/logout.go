@@ -0,0 +1,119 @@
+package service
+
+import (
+	"backend/api/middleware"
+	"backend/db"
+	"backend/dto"
+	"backend/token"
+	"backend/utils"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Logout revokes the session of the refresh token the request was made with
+// and the access tokens issued for it
+func (s *userService) Logout(c context.Context) error {
+	refreshPayload := c.Value(middleware.RefreshTokenPayloadKey).(*token.RefreshPayload)
+	ctx := utils.AppendCtx(c, slog.Int64("user_id", refreshPayload.UserID))
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	_, err = repo.RevokeSession(ctx, db.RevokeSessionParams{
+		ID:        pgUUID(refreshPayload.SessionID),
+		UserID:    refreshPayload.UserID,
+		RevokedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not revoke session", slog.Any("error", err))
+		return dto.NewError("could not logout")
+	}
+
+	// the access tokens of the session stay valid until they are revoked
+	if err = s.revokeSessionAccessTokens(ctx, refreshPayload.SessionID); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "user logged out", slog.String("session_id", refreshPayload.SessionID.String()))
+	return nil
+}
+
+// LogoutAllSessions revokes every session of the user and rotates the token
+// hash, which invalidates every refresh token issued so far, and revokes the
+// access tokens issued so far
+func (s *userService) LogoutAllSessions(ctx context.Context, userID int64) error {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	if currentUser.UserID != userID {
+		return dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeUserNotFound, "user not found")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not start transaction", slog.Any("error", err))
+		return dto.NewError("could not logout from all sessions")
+	}
+	defer tx.Rollback(ctx)
+
+	repo := db.New(conn).WithTx(tx)
+	err = revokeAllSessions(ctx, repo, userID, pgtype.Timestamptz{Time: time.Now(), Valid: true})
+	if err != nil {
+		return dto.NewError("could not logout from all sessions")
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		slog.ErrorContext(ctx, "could not commit logout", slog.Any("error", err))
+		return dto.NewError("could not logout from all sessions")
+	}
+
+	// the access tokens of the sessions stay valid until they are revoked
+	if err = s.revokeAccessTokens(ctx, userID, time.Now()); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "user logged out from all sessions", slog.Int64("userID", userID))
+	return nil
+}
+
+// revokeAllSessions rotates the token hash and revokes every session of the
+// user, it runs inside the transaction of the caller
+func revokeAllSessions(ctx context.Context, repo *db.Queries, userID int64, now pgtype.Timestamptz) error {
+	err := repo.UpdateTokenHash(ctx, db.UpdateTokenHashParams{
+		ID:        userID,
+		TokenHash: utils.GenerateRandomString(15),
+		UpdatedAt: now,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not rotate token hash", slog.Int64("userID", userID), slog.Any("error", err))
+		return err
+	}
+
+	err = repo.RevokeUserSessions(ctx, db.RevokeUserSessionsParams{
+		UserID:    userID,
+		RevokedAt: now,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not revoke sessions", slog.Int64("userID", userID), slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
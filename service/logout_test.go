@@ -0,0 +1,108 @@
+package service
+
+import (
+	"backend/dto"
+	"testing"
+)
+
+func TestRefreshTokenRejectedAfterLogout(t *testing.T) {
+	s := newTestService(t)
+	s.signUp(t, "logout@example.com", "password123")
+	tokens := s.login(t, "logout@example.com", "password123")
+
+	// the refresh token works until the session is logged out
+	refreshed, err := s.GenerateAccessToken(s.refreshContext(t, tokens.RefreshToken), dto.ClientInfo{})
+	if err != nil {
+		t.Fatalf("could not refresh before logout: %v", err)
+	}
+
+	if err = s.Logout(s.refreshContext(t, refreshed.RefreshToken)); err != nil {
+		t.Fatalf("could not logout: %v", err)
+	}
+
+	_, err = s.GenerateAccessToken(s.refreshContext(t, refreshed.RefreshToken), dto.ClientInfo{})
+	assertErrorCode(t, err, dto.ErrCodeTokenInvalid)
+}
+
+func TestLogoutKeepsOtherSessions(t *testing.T) {
+	s := newTestService(t)
+	s.signUp(t, "devices@example.com", "password123")
+	phone := s.login(t, "devices@example.com", "password123")
+	laptop := s.login(t, "devices@example.com", "password123")
+
+	if err := s.Logout(s.refreshContext(t, phone.RefreshToken)); err != nil {
+		t.Fatalf("could not logout: %v", err)
+	}
+
+	_, err := s.GenerateAccessToken(s.refreshContext(t, phone.RefreshToken), dto.ClientInfo{})
+	assertErrorCode(t, err, dto.ErrCodeTokenInvalid)
+
+	if _, err = s.GenerateAccessToken(s.refreshContext(t, laptop.RefreshToken), dto.ClientInfo{}); err != nil {
+		t.Fatalf("logout ended another session: %v", err)
+	}
+}
+
+func TestAccessTokensRevokedAfterLogout(t *testing.T) {
+	s := newTestService(t)
+	s.signUp(t, "logout-access@example.com", "password123")
+	phone := s.login(t, "logout-access@example.com", "password123")
+	laptop := s.login(t, "logout-access@example.com", "password123")
+
+	// the refreshed access token belongs to the same session
+	refreshed, err := s.GenerateAccessToken(s.refreshContext(t, phone.RefreshToken), dto.ClientInfo{})
+	if err != nil {
+		t.Fatalf("could not refresh: %v", err)
+	}
+
+	if err = s.Logout(s.refreshContext(t, refreshed.RefreshToken)); err != nil {
+		t.Fatalf("could not logout: %v", err)
+	}
+
+	for name, accessToken := range map[string]string{"login": phone.AccessToken, "refresh": refreshed.AccessToken} {
+		if !s.accessTokenRevoked(t, accessToken) {
+			t.Errorf("access token of the %s still works after logout", name)
+		}
+	}
+	if s.accessTokenRevoked(t, laptop.AccessToken) {
+		t.Error("logout revoked the access token of another session")
+	}
+}
+
+func TestRefreshTokenRejectedAfterLogoutAllSessions(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "logout-all@example.com", "password123")
+	phone := s.login(t, "logout-all@example.com", "password123")
+	laptop := s.login(t, "logout-all@example.com", "password123")
+
+	if err := s.LogoutAllSessions(s.accessContext(t, phone.AccessToken), userID); err != nil {
+		t.Fatalf("could not logout from all sessions: %v", err)
+	}
+
+	for name, tokens := range map[string]*dto.LoginResponse{"phone": phone, "laptop": laptop} {
+		_, err := s.GenerateAccessToken(s.refreshContext(t, tokens.RefreshToken), dto.ClientInfo{})
+		if err == nil {
+			t.Errorf("refresh token of the %s still works", name)
+			continue
+		}
+		assertErrorCode(t, err, dto.ErrCodeTokenInvalid)
+	}
+
+	// a new login is not affected
+	tokens := s.login(t, "logout-all@example.com", "password123")
+	if _, err := s.GenerateAccessToken(s.refreshContext(t, tokens.RefreshToken), dto.ClientInfo{}); err != nil {
+		t.Fatalf("could not refresh after logging in again: %v", err)
+	}
+}
+
+func TestLogoutAllSessionsOfAnotherUser(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "victim@example.com", "password123")
+	tokens := s.login(t, "victim@example.com", "password123")
+
+	err := s.LogoutAllSessions(authContext(userID+1), userID)
+	assertErrorCode(t, err, dto.ErrCodeUserNotFound)
+
+	if _, err = s.GenerateAccessToken(s.refreshContext(t, tokens.RefreshToken), dto.ClientInfo{}); err != nil {
+		t.Fatalf("session was ended by another user: %v", err)
+	}
+}
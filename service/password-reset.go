@@ -0,0 +1,163 @@
+package service
+
+import (
+	"backend/db"
+	"backend/dto"
+	"backend/mailer"
+	"backend/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	tokenPurposePasswordReset         = "password_reset"
+	defaultPasswordResetTokenDuration = time.Hour
+)
+
+// RequestPasswordReset mails a reset link to the user, it does not tell the
+// caller whether the email belongs to an account
+func (s *userService) RequestPasswordReset(ctx context.Context, request *dto.PasswordResetRequest) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	userIdentity, err := repo.GetUserIdentity(ctx, db.GetUserIdentityParams{
+		Provider: s.AuthKey(),
+		Subject:  request.Email,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			slog.InfoContext(ctx, "password reset requested for email without password")
+			return nil
+		}
+		slog.ErrorContext(ctx, "could not get user identity", slog.Any("error", err))
+		return dto.NewError("could not reset password")
+	}
+
+	err = s.checkUserTokenSendLimit(ctx, userIdentity.UserID, tokenPurposePasswordReset, s.config.PASSWORD_RESET)
+	if err != nil {
+		// rate limited requests look the same as successful ones to the caller
+		var httpErr *dto.Error
+		if errors.As(err, &httpErr) && httpErr.Code == http.StatusTooManyRequests {
+			return nil
+		}
+		return err
+	}
+
+	duration := userTokenDuration(s.config.PASSWORD_RESET, defaultPasswordResetTokenDuration)
+	resetToken, err := s.createUserToken(ctx, userIdentity.UserID, request.Email, tokenPurposePasswordReset, duration)
+	if err != nil {
+		return err
+	}
+
+	link, err := utils.LinkWithToken(s.config.PASSWORD_RESET.URL, resetToken)
+	if err != nil {
+		slog.ErrorContext(ctx, "password reset url is invalid", slog.Any("error", err))
+		return dto.NewError("could not reset password")
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      request.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Open the link below to choose a new password:\n\n%s\n\nThe link expires in %s. If you did not ask for a password reset you can ignore this email.\n",
+			link, duration),
+	})
+	if err != nil {
+		return dto.NewError("could not send password reset email")
+	}
+
+	slog.InfoContext(ctx, "password reset email sent", slog.Int64("userID", userIdentity.UserID))
+	return nil
+}
+
+// ConfirmPasswordReset sets the new password, revokes all sessions and rotates
+// the token hash so every refresh token issued before the reset stops working
+func (s *userService) ConfirmPasswordReset(ctx context.Context, request *dto.ConfirmPasswordResetRequest) error {
+	hashedPassword, err := utils.HashPassword(request.Password)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not hash password", slog.Any("error", err))
+		return dto.NewError("could not hash password")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not start transaction", slog.Any("error", err))
+		return dto.NewError("could not reset password")
+	}
+	defer tx.Rollback(ctx)
+
+	repo := db.New(conn).WithTx(tx)
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+
+	userToken, err := repo.ConsumeUserToken(ctx, db.ConsumeUserTokenParams{
+		Purpose:   tokenPurposePasswordReset,
+		TokenHash: utils.HashToken(request.Token),
+		UsedAt:    now,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			slog.InfoContext(ctx, "password reset token is invalid, expired or already used")
+			return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeResetTokenInvalid, "password reset token is invalid or expired")
+		}
+		slog.ErrorContext(ctx, "could not consume password reset token", slog.Any("error", err))
+		return dto.NewError("could not reset password")
+	}
+
+	err = repo.UpdatePassword(ctx, db.UpdatePasswordParams{
+		ID:        userToken.UserID,
+		Password:  hashedPassword,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not update password", slog.Int64("userID", userToken.UserID), slog.Any("error", err))
+		return dto.NewError("could not update password")
+	}
+
+	err = revokeAllSessions(ctx, repo, userToken.UserID, now)
+	if err != nil {
+		return dto.NewError("could not reset password")
+	}
+
+	// the reset link was delivered to the mailbox, so the email is verified as well
+	err = repo.VerifyUserEmail(ctx, db.VerifyUserEmailParams{
+		ID:        userToken.UserID,
+		Email:     userToken.Email,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not mark email as verified", slog.Int64("userID", userToken.UserID), slog.Any("error", err))
+		return dto.NewError("could not reset password")
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		slog.ErrorContext(ctx, "could not commit password reset", slog.Any("error", err))
+		return dto.NewError("could not reset password")
+	}
+
+	if err = s.revokeAccessTokens(ctx, userToken.UserID, now.Time); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "password was reset", slog.Int64("userID", userToken.UserID))
+	return nil
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"backend/dto"
+	"backend/mailer"
+	"backend/utils"
+	"context"
+	"testing"
+)
+
+// resetToken requests a password reset and returns the token of the mailed
+// link
+func (s *testService) resetToken(t *testing.T, email string) string {
+	t.Helper()
+
+	if err := s.RequestPasswordReset(context.Background(), &dto.PasswordResetRequest{Email: email}); err != nil {
+		t.Fatalf("could not request password reset: %v", err)
+	}
+	sent := s.resetMails(email)
+	if len(sent) == 0 {
+		t.Fatalf("no password reset mail was sent to %s", email)
+	}
+	return linkToken(t, sent[len(sent)-1])
+}
+
+// resetMails returns the password reset mails sent to the email, the sign up
+// verification mail is left out
+func (s *testService) resetMails(email string) []mailer.Message {
+	var sent []mailer.Message
+	for _, message := range s.mails.SentTo(email) {
+		if message.Subject == "Reset your password" {
+			sent = append(sent, message)
+		}
+	}
+	return sent
+}
+
+func (s *testService) loginError(email string, password string) error {
+	_, _, err := s.Login(context.Background(), &dto.LoginRequest{
+		Provider:   s.AuthKey(),
+		Payload:    email + "|" + password,
+		DeviceName: "test",
+	})
+	return err
+}
+
+func TestPasswordReset(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "reset@example.com", "password123")
+	resetToken := s.resetToken(t, "reset@example.com")
+
+	err := s.ConfirmPasswordReset(context.Background(), &dto.ConfirmPasswordResetRequest{Token: resetToken, Password: "new-password123"})
+	if err != nil {
+		t.Fatalf("could not reset password: %v", err)
+	}
+
+	assertErrorCode(t, s.loginError("reset@example.com", "password123"), dto.ErrCodeInvalidCredentials)
+	s.login(t, "reset@example.com", "new-password123")
+
+	// the link was delivered, so the email is verified as well
+	if !s.emailVerified(t, userID) {
+		t.Error("email is not verified after the reset")
+	}
+}
+
+func TestPasswordResetTokenIsSingleUse(t *testing.T) {
+	s := newTestService(t)
+	s.signUp(t, "reset-once@example.com", "password123")
+	resetToken := s.resetToken(t, "reset-once@example.com")
+
+	err := s.ConfirmPasswordReset(context.Background(), &dto.ConfirmPasswordResetRequest{Token: resetToken, Password: "new-password123"})
+	if err != nil {
+		t.Fatalf("could not reset password: %v", err)
+	}
+
+	err = s.ConfirmPasswordReset(context.Background(), &dto.ConfirmPasswordResetRequest{Token: resetToken, Password: "other-password123"})
+	assertErrorCode(t, err, dto.ErrCodeResetTokenInvalid)
+	s.login(t, "reset-once@example.com", "new-password123")
+}
+
+func TestPasswordResetTokenExpires(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "reset-expired@example.com", "password123")
+	resetToken := s.resetToken(t, "reset-expired@example.com")
+
+	_, err := s.pool.Exec(context.Background(), "UPDATE user_tokens SET expires_at = now() - interval '1 minute' WHERE user_id = $1", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.ConfirmPasswordReset(context.Background(), &dto.ConfirmPasswordResetRequest{Token: resetToken, Password: "new-password123"})
+	assertErrorCode(t, err, dto.ErrCodeResetTokenInvalid)
+	s.login(t, "reset-expired@example.com", "password123")
+}
+
+func TestPasswordResetOfUnknownEmail(t *testing.T) {
+	s := newTestService(t)
+
+	err := s.RequestPasswordReset(context.Background(), &dto.PasswordResetRequest{Email: "nobody@example.com"})
+	if err != nil {
+		t.Fatalf("unknown email was reported: %v", err)
+	}
+	if sent := s.mails.Sent(); len(sent) != 0 {
+		t.Errorf("sent %d mails for an unknown email, want none", len(sent))
+	}
+}
+
+func TestPasswordResetSendLimit(t *testing.T) {
+	s := newTestServiceWithConfig(t, func(config *utils.Config) {
+		config.PASSWORD_RESET.MAX_SENDS_PER_DAY = 2
+	})
+	userID := s.signUp(t, "reset-limit@example.com", "password123")
+	firstToken := s.resetToken(t, "reset-limit@example.com")
+
+	// rate limited requests succeed silently without a mail
+	if err := s.RequestPasswordReset(context.Background(), &dto.PasswordResetRequest{Email: "reset-limit@example.com"}); err != nil {
+		t.Fatalf("rate limited request was reported: %v", err)
+	}
+	if sent := s.resetMails("reset-limit@example.com"); len(sent) != 1 {
+		t.Fatalf("sent %d reset mails within the resend interval, want 1", len(sent))
+	}
+
+	s.backdateUserTokens(t, userID)
+	secondToken := s.resetToken(t, "reset-limit@example.com")
+	if sent := s.resetMails("reset-limit@example.com"); len(sent) != 2 {
+		t.Fatalf("sent %d reset mails after the resend interval, want 2", len(sent))
+	}
+
+	s.backdateUserTokens(t, userID)
+	if err := s.RequestPasswordReset(context.Background(), &dto.PasswordResetRequest{Email: "reset-limit@example.com"}); err != nil {
+		t.Fatalf("rate limited request was reported: %v", err)
+	}
+	if sent := s.resetMails("reset-limit@example.com"); len(sent) != 2 {
+		t.Fatalf("sent %d reset mails over the daily limit, want 2", len(sent))
+	}
+
+	// only the newest link stays usable
+	err := s.ConfirmPasswordReset(context.Background(), &dto.ConfirmPasswordResetRequest{Token: firstToken, Password: "new-password123"})
+	assertErrorCode(t, err, dto.ErrCodeResetTokenInvalid)
+
+	err = s.ConfirmPasswordReset(context.Background(), &dto.ConfirmPasswordResetRequest{Token: secondToken, Password: "new-password123"})
+	if err != nil {
+		t.Fatalf("could not reset password with the newest link: %v", err)
+	}
+}
+
+func TestPasswordResetEndsSessions(t *testing.T) {
+	s := newTestService(t)
+	s.signUp(t, "reset-sessions@example.com", "password123")
+	phone := s.login(t, "reset-sessions@example.com", "password123")
+	laptop := s.login(t, "reset-sessions@example.com", "password123")
+	resetToken := s.resetToken(t, "reset-sessions@example.com")
+
+	err := s.ConfirmPasswordReset(context.Background(), &dto.ConfirmPasswordResetRequest{Token: resetToken, Password: "new-password123"})
+	if err != nil {
+		t.Fatalf("could not reset password: %v", err)
+	}
+
+	for _, tokens := range []*dto.LoginResponse{phone, laptop} {
+		_, err = s.GenerateAccessToken(s.refreshContext(t, tokens.RefreshToken), dto.ClientInfo{})
+		assertErrorCode(t, err, dto.ErrCodeTokenInvalid)
+
+		if !s.accessTokenRevoked(t, tokens.AccessToken) {
+			t.Error("access token issued before the reset still works")
+		}
+	}
+}
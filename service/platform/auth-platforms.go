@@ -5,11 +5,27 @@ import (
 	"context"
 )
 
+// Identity is the account of a user at a provider, users are found by the
+// provider and subject as the email at the provider can change
+type Identity struct {
+	Subject string
+	Email   string
+	Profile map[string]any
+}
+
 type AuthPlatform interface {
 	AuthKey() string
-	LoginGetEmail(ctx context.Context, payload string) (string, error)
+	LoginGetIdentity(ctx context.Context, payload string) (*Identity, error)
 	LoginExtraVerify(ctx context.Context, payload string, user db.GetUserSecretsRow) error
-	LinkGetEmail(ctx context.Context, payload string) (string, error)
+	LinkGetIdentity(ctx context.Context, payload string) (*Identity, error)
 	LinkExtraInformation(ctx context.Context, userID int64, payload string) error
-	GenerateDbUser(ctx context.Context, payload any) (*db.CreateUserParams, error)
+	GenerateDbUser(ctx context.Context, payload any) (*db.CreateUserParams, *Identity, error)
+}
+
+// LoginSignUp is implemented by auth platforms that create the account on the
+// first login instead of through CreateUser. GenerateLoginDbUser verifies the
+// payload like LoginExtraVerify does for existing users
+type LoginSignUp interface {
+	LoginSignUpEnabled() bool
+	GenerateLoginDbUser(ctx context.Context, payload string, identity *Identity) (*db.CreateUserParams, error)
 }
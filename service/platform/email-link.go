@@ -0,0 +1,432 @@
+package platformService
+
+import (
+	"backend/api/apiUtils"
+	"backend/api/middleware"
+	"backend/db"
+	"backend/dto"
+	"backend/mailer"
+	"backend/token"
+	"backend/utils"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	defaultEmailLinkTokenDuration  = 15 * time.Minute
+	defaultEmailLinkResendInterval = time.Minute
+	defaultEmailLinkMaxSendsPerDay = 10
+	emailLinkCodeLength            = 6
+	emailLinkCodeMaxAttempts       = 5
+)
+
+// EmailLinkService is the passwordless auth platform, the link or code used
+// as the login payload is mailed through RequestLogin
+type EmailLinkService interface {
+	AuthPlatform
+	RequestLogin(ctx context.Context, request *dto.EmailLinkRequest) error
+}
+
+type emailLinkService struct {
+	pool   *pgxpool.Pool
+	mailer mailer.Mailer
+	config utils.EmailLinkConfig
+}
+
+func NewEmailLinkService(pool *pgxpool.Pool, mailer mailer.Mailer, config utils.EmailLinkConfig) EmailLinkService {
+	return &emailLinkService{
+		pool:   pool,
+		mailer: mailer,
+		config: config,
+	}
+}
+
+func (s *emailLinkService) AuthKey() string {
+	return "email-link"
+}
+
+// RequestLogin mails a login link and code, it does not tell the caller
+// whether the email belongs to an account
+func (s *emailLinkService) RequestLogin(ctx context.Context, request *dto.EmailLinkRequest) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	_, err = repo.GetUserIdentity(ctx, db.GetUserIdentityParams{
+		Provider: s.AuthKey(),
+		Subject:  request.Email,
+	})
+	if err != nil && err != pgx.ErrNoRows {
+		slog.ErrorContext(ctx, "could not get user identity", slog.Any("error", err))
+		return dto.NewError("could not send login email")
+	}
+
+	// without a linked account the code can only be used to sign up
+	if err == pgx.ErrNoRows && !s.config.ALLOW_SIGN_UP {
+		slog.InfoContext(ctx, "login link requested for email without email link")
+		return nil
+	}
+
+	now := time.Now()
+	stats, err := repo.GetEmailLoginTokenSendStats(ctx, db.GetEmailLoginTokenSendStatsParams{
+		Email:     request.Email,
+		CreatedAt: pgtype.Timestamptz{Time: now.Add(-24 * time.Hour), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get sent login email stats", slog.Any("error", err))
+		return dto.NewError("could not send login email")
+	}
+
+	resendInterval := s.config.RESEND_INTERVAL * time.Second
+	if resendInterval == 0 {
+		resendInterval = defaultEmailLinkResendInterval
+	}
+
+	maxSends := s.config.MAX_SENDS_PER_DAY
+	if maxSends == 0 {
+		maxSends = defaultEmailLinkMaxSendsPerDay
+	}
+
+	// rate limited requests look the same as successful ones to the caller
+	if stats.SentCount >= maxSends || (stats.LastSentAt.Valid && now.Sub(stats.LastSentAt.Time) < resendInterval) {
+		slog.InfoContext(ctx, "login email limit reached")
+		return nil
+	}
+
+	loginToken, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not generate login token", slog.Any("error", err))
+		return dto.NewError("could not send login email")
+	}
+
+	code, err := utils.GenerateSecureString(emailLinkCodeLength, "0123456789")
+	if err != nil {
+		slog.ErrorContext(ctx, "could not generate login code", slog.Any("error", err))
+		return dto.NewError("could not send login email")
+	}
+
+	duration := s.config.TOKEN_DURATION * time.Second
+	if duration == 0 {
+		duration = defaultEmailLinkTokenDuration
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not start transaction", slog.Any("error", err))
+		return dto.NewError("could not send login email")
+	}
+	defer tx.Rollback(ctx)
+
+	txRepo := repo.WithTx(tx)
+	// only the newest link and code stay usable
+	err = txRepo.RevokeEmailLoginTokens(ctx, db.RevokeEmailLoginTokensParams{
+		Email:  request.Email,
+		UsedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not revoke old login tokens", slog.Any("error", err))
+		return dto.NewError("could not send login email")
+	}
+
+	err = txRepo.CreateEmailLoginToken(ctx, db.CreateEmailLoginTokenParams{
+		Email:     request.Email,
+		TokenHash: utils.HashToken(loginToken),
+		CodeHash:  utils.HashToken(code),
+		ExpiresAt: pgtype.Timestamptz{Time: now.Add(duration), Valid: true},
+		CreatedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not store login token", slog.Any("error", err))
+		return dto.NewError("could not send login email")
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		slog.ErrorContext(ctx, "could not commit transaction", slog.Any("error", err))
+		return dto.NewError("could not send login email")
+	}
+
+	link, err := utils.LinkWithToken(s.config.URL, loginToken)
+	if err != nil {
+		slog.ErrorContext(ctx, "email link url is invalid", slog.Any("error", err))
+		return dto.NewError("could not send login email")
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      request.Email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf("Open the link below to log in:\n\n%s\n\nOr enter this code in the app: %s\n\nThe link and code expire in %s and can be used once. If you did not try to log in you can ignore this email.\n",
+			link, code, duration),
+	})
+	if err != nil {
+		return dto.NewError("could not send login email")
+	}
+
+	slog.InfoContext(ctx, "login email sent")
+	return nil
+}
+
+func (s *emailLinkService) LoginGetIdentity(ctx context.Context, p string) (*Identity, error) {
+	payload, err := parseEmailLinkPayload(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	email, err := s.payloadEmail(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return emailLinkIdentity(email), nil
+}
+
+func (s *emailLinkService) LoginExtraVerify(ctx context.Context, p string, user db.GetUserSecretsRow) error {
+	payload, err := parseEmailLinkPayload(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	return s.consume(ctx, payload, user.Email)
+}
+
+func (s *emailLinkService) LinkGetIdentity(ctx context.Context, payload string) (*Identity, error) {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+	defer conn.Release()
+
+	repo := db.New(conn)
+	user, err := repo.GetUser(ctx, currentUser.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "error while getting user", slog.Any("error", err))
+		return nil, dto.NewError("could not get user")
+	}
+
+	return emailLinkIdentity(user.Email), nil
+}
+
+// LinkExtraInformation has nothing to store, the links are always mailed to
+// the email of the account
+func (s *emailLinkService) LinkExtraInformation(ctx context.Context, userID int64, payload string) error {
+	return nil
+}
+
+func (s *emailLinkService) GenerateDbUser(ctx context.Context, p any) (*db.CreateUserParams, *Identity, error) {
+	if !s.config.ALLOW_SIGN_UP {
+		return nil, nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeSignupDisabled, "sign up with email link is disabled")
+	}
+
+	var payload dto.CreateUserPayloadEmailLink
+	err := apiUtils.AssignAndValidateCreateUserPayload(ctx, p, &payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = checkEmailLinkPayload(&payload.EmailLinkPayload); err != nil {
+		return nil, nil, err
+	}
+
+	email, err := s.payloadEmail(ctx, &payload.EmailLinkPayload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.consume(ctx, &payload.EmailLinkPayload, email)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &db.CreateUserParams{
+		Name:          payload.Name,
+		Email:         email,
+		Password:      "NA",
+		Picture:       nil,
+		EmailVerified: true, // the link or code was mailed to the email
+		TokenHash:     utils.GenerateRandomString(15),
+	}, emailLinkIdentity(email), nil
+}
+
+func (s *emailLinkService) LoginSignUpEnabled() bool {
+	return s.config.ALLOW_SIGN_UP
+}
+
+// GenerateLoginDbUser creates the account of an unknown email on its first
+// login, the name is taken from the email as the login payload has none
+func (s *emailLinkService) GenerateLoginDbUser(ctx context.Context, p string, identity *Identity) (*db.CreateUserParams, error) {
+	payload, err := parseEmailLinkPayload(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.consume(ctx, payload, identity.Email); err != nil {
+		return nil, err
+	}
+
+	name, _, _ := strings.Cut(identity.Email, "@")
+	return &db.CreateUserParams{
+		Name:          name,
+		Email:         identity.Email,
+		Password:      "NA",
+		Picture:       nil,
+		EmailVerified: true, // the link or code was mailed to the email
+		TokenHash:     utils.GenerateRandomString(15),
+	}, nil
+}
+
+// emailLinkIdentity uses the email as subject as the links are always mailed
+// to the email of the account
+func emailLinkIdentity(email string) *Identity {
+	return &Identity{
+		Subject: email,
+		Email:   email,
+		Profile: map[string]any{},
+	}
+}
+
+// payloadEmail returns the email the link or code was sent to, without using it
+func (s *emailLinkService) payloadEmail(ctx context.Context, payload *dto.EmailLinkPayload) (string, error) {
+	if payload.Token == "" {
+		return payload.Email, nil
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return "", err
+	}
+	defer conn.Release()
+
+	repo := db.New(conn)
+	email, err := repo.GetEmailLoginTokenEmail(ctx, db.GetEmailLoginTokenEmailParams{
+		TokenHash: utils.HashToken(payload.Token),
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeLoginLinkInvalid, "login link is invalid or expired")
+		}
+
+		slog.ErrorContext(ctx, "could not get login token", slog.Any("error", err))
+		return "", dto.NewError("could not get login token")
+	}
+
+	return email, nil
+}
+
+// consume marks the link or code as used, a code is locked after too many
+// wrong attempts as it is short enough to be guessed
+func (s *emailLinkService) consume(ctx context.Context, payload *dto.EmailLinkPayload, email string) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+	defer conn.Release()
+
+	repo := db.New(conn)
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+
+	if payload.Token != "" {
+		rows, err := repo.ConsumeEmailLoginToken(ctx, db.ConsumeEmailLoginTokenParams{
+			TokenHash: utils.HashToken(payload.Token),
+			Email:     email,
+			UsedAt:    now,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "could not consume login token", slog.Any("error", err))
+			return dto.NewError("could not verify login link")
+		}
+		if rows == 0 {
+			return dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeLoginLinkInvalid, "login link is invalid or expired")
+		}
+
+		return nil
+	}
+
+	loginCode, err := repo.GetEmailLoginCode(ctx, db.GetEmailLoginCodeParams{
+		Email:     email,
+		ExpiresAt: now,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeLoginLinkInvalid, "login code is invalid or expired")
+		}
+
+		slog.ErrorContext(ctx, "could not get login code", slog.Any("error", err))
+		return dto.NewError("could not verify login code")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(payload.Code)), []byte(loginCode.CodeHash)) != 1 {
+		err = repo.IncrementEmailLoginTokenAttempts(ctx, db.IncrementEmailLoginTokenAttemptsParams{
+			MaxAttempts: emailLinkCodeMaxAttempts,
+			Now:         now,
+			ID:          loginCode.ID,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "could not count failed login code attempt", slog.Any("error", err))
+		}
+
+		return dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeLoginLinkInvalid, "login code is invalid or expired")
+	}
+
+	rows, err := repo.UseEmailLoginToken(ctx, db.UseEmailLoginTokenParams{
+		ID:     loginCode.ID,
+		UsedAt: now,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not use login code", slog.Any("error", err))
+		return dto.NewError("could not verify login code")
+	}
+	if rows == 0 {
+		return dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeLoginLinkInvalid, "login code is invalid or expired")
+	}
+
+	return nil
+}
+
+func parseEmailLinkPayload(ctx context.Context, p string) (*dto.EmailLinkPayload, error) {
+	var payload dto.EmailLinkPayload
+	err := json.Unmarshal([]byte(p), &payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "email link payload is not valid json", slog.Any("error", err))
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidPayload, "invalid payload")
+	}
+
+	err = apiUtils.ValidateStruct(payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "validation error", slog.Any("errors", err))
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidPayload, "invalid payload")
+	}
+
+	if err = checkEmailLinkPayload(&payload); err != nil {
+		return nil, err
+	}
+
+	return &payload, nil
+}
+
+func checkEmailLinkPayload(payload *dto.EmailLinkPayload) error {
+	if payload.Token == "" && (payload.Email == "" || payload.Code == "") {
+		return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidPayload, "either token or email and code are required")
+	}
+
+	return nil
+}
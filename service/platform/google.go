@@ -7,19 +7,32 @@ import (
 	"backend/external-api/platform/google"
 	"backend/utils"
 	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// GoogleService is the google auth platform, the authorization is started
+// through it so the state, nonce and PKCE verifier stay on the server
+type GoogleService interface {
+	AuthPlatform
+	StartAuthorization(ctx context.Context) (*dto.AuthorizationStartResponse, error)
+}
+
 type googleService struct {
 	pool   *pgxpool.Pool
 	config utils.GoogleConfig
+	client *google.Client
 }
 
-func NewGoogleService(pool *pgxpool.Pool, config utils.GoogleConfig) *googleService {
+func NewGoogleService(pool *pgxpool.Pool, config utils.GoogleConfig) GoogleService {
 	return &googleService{
 		pool:   pool,
 		config: config,
+		client: google.NewClient(config),
 	}
 }
 
@@ -27,18 +40,39 @@ func (s *googleService) AuthKey() string {
 	return "google"
 }
 
-func (s *googleService) LoginGetEmail(ctx context.Context, payload string) (string, error) {
-	tokenResponse, err := google.GetAccessToken(payload, s.config)
+func (s *googleService) StartAuthorization(ctx context.Context) (*dto.AuthorizationStartResponse, error) {
+	authorization, err := startOauthAuthorization(ctx, s.pool, s.AuthKey(), s.config.STATE_DURATION*time.Second)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	userInfo, err := google.UserInformation(tokenResponse.AccessToken, s.config)
+	return &dto.AuthorizationStartResponse{
+		AuthorizationURL: s.client.AuthCodeURL(authorization.State, authorization.Nonce, authorization.CodeVerifier),
+		State:            authorization.State,
+		ExpiresAt:        authorization.ExpiresAt,
+	}, nil
+}
+
+func (s *googleService) LoginGetIdentity(ctx context.Context, p string) (*Identity, error) {
+	var payload dto.GooglePayload
+	err := json.Unmarshal([]byte(p), &payload)
 	if err != nil {
-		return "", err
+		slog.ErrorContext(ctx, "google payload is not valid json", slog.Any("error", err))
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidPayload, "invalid payload")
+	}
+
+	err = apiUtils.ValidateStruct(payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "validation error", slog.Any("errors", err))
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidPayload, "invalid payload")
+	}
+
+	claims, err := s.claims(ctx, &payload)
+	if err != nil {
+		return nil, err
 	}
 
-	return userInfo.Email, nil
+	return googleIdentity(claims), nil
 }
 
 func (s *googleService) LoginExtraVerify(ctx context.Context, payload string, user db.GetUserSecretsRow) error {
@@ -49,32 +83,56 @@ func (s *googleService) LinkExtraInformation(ctx context.Context, userID int64,
 	return nil
 }
 
-func (s *googleService) LinkGetEmail(ctx context.Context, payload string) (string, error) {
-	return s.LoginGetEmail(ctx, payload)
+func (s *googleService) LinkGetIdentity(ctx context.Context, payload string) (*Identity, error) {
+	return s.LoginGetIdentity(ctx, payload)
 }
 
-func (s *googleService) GenerateDbUser(ctx context.Context, p any) (*db.CreateUserParams, error) {
-	var payload dto.CreateUserPayloadGoogle
+func (s *googleService) GenerateDbUser(ctx context.Context, p any) (*db.CreateUserParams, *Identity, error) {
+	var payload dto.GooglePayload
 	err := apiUtils.AssignAndValidateCreateUserPayload(ctx, p, &payload)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	tokenResponse, err := google.GetAccessToken(payload.AuthorizationCode, s.config)
+	claims, err := s.claims(ctx, &payload)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	userInfo, err := google.UserInformation(tokenResponse.AccessToken, s.config)
+	return &db.CreateUserParams{
+		Name:          claims.Name,
+		Email:         claims.Email,
+		Password:      "NA",
+		Picture:       claims.Picture,
+		EmailVerified: true, // google only returns verified emails, see google.ExchangeCode
+		TokenHash:     utils.GenerateRandomString(15),
+	}, googleIdentity(claims), nil
+}
+
+func googleIdentity(claims *google.Claims) *Identity {
+	return &Identity{
+		Subject: claims.Subject,
+		Email:   claims.Email,
+		Profile: map[string]any{
+			"name":    claims.Name,
+			"picture": claims.Picture,
+		},
+	}
+}
+
+// claims uses up the state issued by StartAuthorization and exchanges the code
+// with its PKCE verifier and nonce
+func (s *googleService) claims(ctx context.Context, payload *dto.GooglePayload) (*google.Claims, error) {
+	oauthState, err := consumeOauthState(ctx, s.pool, s.AuthKey(), payload.State)
 	if err != nil {
 		return nil, err
 	}
 
-	return &db.CreateUserParams{
-		Name:      userInfo.Name,
-		Email:     userInfo.Email,
-		Password:  "NA",
-		Picture:   nil,
-		TokenHash: utils.GenerateRandomString(15),
-	}, nil
+	claims, err := s.client.ExchangeCode(ctx, payload.AuthorizationCode, oauthState.CodeVerifier, oauthState.Nonce)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not verify google login", slog.Any("error", err))
+		return nil, dto.NewErrorWithParams(http.StatusForbidden, dto.ErrCodeExternalLoginFailed, "could not verify google login", map[string]string{"provider": s.AuthKey()})
+	}
+
+	return claims, nil
 }
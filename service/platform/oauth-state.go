@@ -0,0 +1,115 @@
+package platformService
+
+import (
+	"backend/db"
+	"backend/dto"
+	"backend/utils"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/oauth2"
+)
+
+const defaultOauthStateDuration = 10 * time.Minute
+
+// oauthAuthorization is a started authorization code flow, only the state is
+// given to the client, the nonce and PKCE verifier stay on the server
+type oauthAuthorization struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// startOauthAuthorization stores a new state for the provider, the state can
+// be used once before it expires
+func startOauthAuthorization(ctx context.Context, pool *pgxpool.Pool, provider string, duration time.Duration) (*oauthAuthorization, error) {
+	state, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not generate oauth state", slog.Any("error", err))
+		return nil, dto.NewError("could not start authorization")
+	}
+
+	nonce, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not generate oauth nonce", slog.Any("error", err))
+		return nil, dto.NewError("could not start authorization")
+	}
+
+	codeVerifier := oauth2.GenerateVerifier()
+
+	if duration <= 0 {
+		duration = defaultOauthStateDuration
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+	defer conn.Release()
+
+	repo := db.New(conn)
+	now := time.Now()
+	expiresAt := now.Add(duration)
+
+	// clean up authorizations that were never finished
+	err = repo.DeleteExpiredOauthStates(ctx, pgtype.Timestamptz{Time: now, Valid: true})
+	if err != nil {
+		slog.WarnContext(ctx, "could not delete expired oauth states", slog.Any("error", err))
+	}
+
+	err = repo.CreateOauthState(ctx, db.CreateOauthStateParams{
+		StateHash:    utils.HashToken(state),
+		Provider:     provider,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		ExpiresAt:    pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		CreatedAt:    pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not store oauth state", slog.Any("error", err))
+		return nil, dto.NewError("could not start authorization")
+	}
+
+	return &oauthAuthorization{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// consumeOauthState uses up a state issued by startOauthAuthorization for the
+// provider and returns its PKCE verifier and nonce
+func consumeOauthState(ctx context.Context, pool *pgxpool.Pool, provider string, state string) (*db.ConsumeOauthStateRow, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+	defer conn.Release()
+
+	repo := db.New(conn)
+	oauthState, err := repo.ConsumeOauthState(ctx, db.ConsumeOauthStateParams{
+		StateHash: utils.HashToken(state),
+		Provider:  provider,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			slog.InfoContext(ctx, "oauth state is invalid, expired or already used", slog.String("provider", provider))
+			return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeOAuthStateInvalid, "authorization state is invalid or expired")
+		}
+
+		slog.ErrorContext(ctx, "could not get oauth state", slog.Any("error", err))
+		return nil, dto.NewError("could not verify " + provider + " login")
+	}
+
+	return &oauthState, nil
+}
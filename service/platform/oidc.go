@@ -0,0 +1,155 @@
+package platformService
+
+import (
+	"backend/api/apiUtils"
+	"backend/db"
+	"backend/dto"
+	"backend/external-api/platform/oidc"
+	"backend/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OIDCService is an openid connect auth platform, the authorization is started
+// through it so the state, nonce and PKCE verifier stay on the server
+type OIDCService interface {
+	AuthPlatform
+	StartAuthorization(ctx context.Context) (*dto.AuthorizationStartResponse, error)
+}
+
+type oidcService struct {
+	pool   *pgxpool.Pool
+	config utils.OIDCProviderConfig
+	client *oidc.Client
+}
+
+func NewOIDCService(pool *pgxpool.Pool, config utils.OIDCProviderConfig) (OIDCService, error) {
+	if config.KEY == "" || config.ISSUER_URL == "" || config.CLIENT_ID == "" {
+		return nil, errors.New("oidc provider needs a key, issuer url and client id")
+	}
+
+	return &oidcService{
+		pool:   pool,
+		config: config,
+		client: oidc.NewClient(config),
+	}, nil
+}
+
+func (s *oidcService) AuthKey() string {
+	return s.config.KEY
+}
+
+func (s *oidcService) StartAuthorization(ctx context.Context) (*dto.AuthorizationStartResponse, error) {
+	authorization, err := startOauthAuthorization(ctx, s.pool, s.AuthKey(), s.config.STATE_DURATION*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	authorizationURL, err := s.client.AuthCodeURL(ctx, authorization.State, authorization.Nonce, authorization.CodeVerifier)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get oidc authorization url", slog.String("provider", s.config.KEY), slog.Any("error", err))
+		return nil, dto.NewError("could not start authorization")
+	}
+
+	return &dto.AuthorizationStartResponse{
+		AuthorizationURL: authorizationURL,
+		State:            authorization.State,
+		ExpiresAt:        authorization.ExpiresAt,
+	}, nil
+}
+
+func (s *oidcService) LoginGetIdentity(ctx context.Context, p string) (*Identity, error) {
+	var payload dto.OIDCPayload
+	err := json.Unmarshal([]byte(p), &payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "oidc payload is not valid json", slog.Any("error", err))
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidPayload, "invalid payload")
+	}
+
+	err = apiUtils.ValidateStruct(payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "validation error", slog.Any("errors", err))
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidPayload, "invalid payload")
+	}
+
+	claims, err := s.claims(ctx, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return oidcIdentity(claims), nil
+}
+
+func (s *oidcService) LoginExtraVerify(ctx context.Context, payload string, user db.GetUserSecretsRow) error {
+	return nil
+}
+
+func (s *oidcService) LinkExtraInformation(ctx context.Context, userID int64, payload string) error {
+	return nil
+}
+
+func (s *oidcService) LinkGetIdentity(ctx context.Context, payload string) (*Identity, error) {
+	return s.LoginGetIdentity(ctx, payload)
+}
+
+func (s *oidcService) GenerateDbUser(ctx context.Context, p any) (*db.CreateUserParams, *Identity, error) {
+	var payload dto.OIDCPayload
+	err := apiUtils.AssignAndValidateCreateUserPayload(ctx, p, &payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claims, err := s.claims(ctx, &payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.Email
+	}
+
+	return &db.CreateUserParams{
+		Name:          name,
+		Email:         claims.Email,
+		Password:      "NA",
+		Picture:       claims.Picture,
+		EmailVerified: true, // unverified emails are refused, see oidc.ExchangeCode
+		TokenHash:     utils.GenerateRandomString(15),
+	}, oidcIdentity(claims), nil
+}
+
+func oidcIdentity(claims *oidc.Claims) *Identity {
+	return &Identity{
+		Subject: claims.Subject,
+		Email:   claims.Email,
+		Profile: map[string]any{
+			"name":           claims.Name,
+			"picture":        claims.Picture,
+			"email_verified": claims.EmailVerified,
+		},
+	}
+}
+
+// claims uses up the state issued by StartAuthorization and exchanges the code
+// with its PKCE verifier and nonce
+func (s *oidcService) claims(ctx context.Context, payload *dto.OIDCPayload) (*oidc.Claims, error) {
+	oauthState, err := consumeOauthState(ctx, s.pool, s.AuthKey(), payload.State)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.client.ExchangeCode(ctx, payload.AuthorizationCode, oauthState.CodeVerifier, oauthState.Nonce)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get oidc claims", slog.String("provider", s.config.KEY), slog.Any("error", err))
+		return nil, dto.NewErrorWithParams(http.StatusForbidden, dto.ErrCodeExternalLoginFailed, "could not verify login with "+s.config.KEY, map[string]string{"provider": s.config.KEY})
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,460 @@
+package platformService
+
+import (
+	"backend/api/apiUtils"
+	"backend/api/middleware"
+	"backend/db"
+	"backend/dto"
+	"backend/token"
+	"backend/utils"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	passkeyCeremonyRegistration     = "registration"
+	passkeyCeremonyLogin            = "login"
+	defaultPasskeyChallengeDuration = 5 * time.Minute
+)
+
+// PasskeyService is the passkey auth platform, the ceremonies are started
+// through it before the credential is sent to login or link
+type PasskeyService interface {
+	AuthPlatform
+	BeginRegistration(ctx context.Context, userID int64) (*dto.PasskeyOptionsResponse, error)
+	BeginLogin(ctx context.Context) (*dto.PasskeyOptionsResponse, error)
+}
+
+type passkeyService struct {
+	pool     *pgxpool.Pool
+	config   utils.PasskeyConfig
+	webauthn *webauthn.WebAuthn
+}
+
+func NewPasskeyService(pool *pgxpool.Pool, config utils.PasskeyConfig) (PasskeyService, error) {
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          config.RP_ID,
+		RPDisplayName: config.RP_DISPLAY_NAME,
+		RPOrigins:     config.RP_ORIGINS,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &passkeyService{
+		pool:     pool,
+		config:   config,
+		webauthn: w,
+	}, nil
+}
+
+// passkeyUser is the webauthn view of a user, the user handle is the user id
+type passkeyUser struct {
+	id          int64
+	name        string
+	email       string
+	credentials []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return passkeyUserHandle(u.id)
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.email
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	return u.name
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func passkeyUserHandle(userID int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
+
+func (s *passkeyService) AuthKey() string {
+	return "passkey"
+}
+
+func (s *passkeyService) BeginRegistration(ctx context.Context, userID int64) (*dto.PasskeyOptionsResponse, error) {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	if currentUser.UserID != userID {
+		return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeUserNotFound, "user not found")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+	defer conn.Release()
+
+	repo := db.New(conn)
+	user, err := s.getPasskeyUser(ctx, repo, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := s.webauthn.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+	)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not begin passkey registration", slog.Any("error", err))
+		return nil, dto.NewError("could not begin passkey registration")
+	}
+
+	return s.storeChallenge(ctx, repo, &userID, passkeyCeremonyRegistration, session, creation)
+}
+
+func (s *passkeyService) BeginLogin(ctx context.Context) (*dto.PasskeyOptionsResponse, error) {
+	assertion, session, err := s.webauthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationPreferred),
+	)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not begin passkey login", slog.Any("error", err))
+		return nil, dto.NewError("could not begin passkey login")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+	defer conn.Release()
+
+	return s.storeChallenge(ctx, db.New(conn), nil, passkeyCeremonyLogin, session, assertion)
+}
+
+func (s *passkeyService) LoginGetIdentity(ctx context.Context, p string) (*Identity, error) {
+	payload, err := parsePasskeyPayload(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(payload.Credential)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not parse passkey assertion", slog.Any("error", err))
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodePasskeyCredentialInvalid, "invalid passkey credential")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+	defer conn.Release()
+
+	repo := db.New(conn)
+	_, session, err := s.consumeChallenge(ctx, repo, payload.ChallengeID, passkeyCeremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *passkeyUser
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		if len(userHandle) != 8 {
+			return nil, errors.New("invalid user handle")
+		}
+
+		user, err = s.getPasskeyUser(ctx, repo, int64(binary.BigEndian.Uint64(userHandle)))
+		return user, err
+	}
+
+	_, credential, err := s.webauthn.ValidatePasskeyLogin(handler, *session, parsed)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not validate passkey login", slog.Any("error", err))
+		return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeInvalidCredentials, "invalid credentials")
+	}
+
+	if credential.Authenticator.CloneWarning {
+		slog.WarnContext(ctx, "passkey sign count went backwards, authenticator may be cloned", slog.Int64("userID", user.id))
+		return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeInvalidCredentials, "invalid credentials")
+	}
+
+	err = repo.UpdatePasskeyCredentialUsage(ctx, db.UpdatePasskeyCredentialUsageParams{
+		UserID:       user.id,
+		CredentialID: credential.ID,
+		SignCount:    int64(credential.Authenticator.SignCount),
+		Flags:        int16(credential.Flags.ProtocolValue()),
+		LastUsedAt:   pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not update passkey usage", slog.Any("error", err))
+		return nil, dto.NewError("could not update passkey")
+	}
+
+	return passkeyIdentity(user.id, user.email), nil
+}
+
+// LoginExtraVerify has nothing left to check, the assertion is verified while
+// getting the email as the user is only known from the credential
+func (s *passkeyService) LoginExtraVerify(ctx context.Context, payload string, user db.GetUserSecretsRow) error {
+	return nil
+}
+
+func (s *passkeyService) LinkGetIdentity(ctx context.Context, payload string) (*Identity, error) {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+	defer conn.Release()
+
+	repo := db.New(conn)
+	user, err := repo.GetUser(ctx, currentUser.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "error while getting user", slog.Any("error", err))
+		return nil, dto.NewError("could not get user")
+	}
+
+	return passkeyIdentity(user.ID, user.Email), nil
+}
+
+func (s *passkeyService) LinkExtraInformation(ctx context.Context, userID int64, p string) error {
+	payload, err := parsePasskeyPayload(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(payload.Credential)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not parse passkey attestation", slog.Any("error", err))
+		return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodePasskeyCredentialInvalid, "invalid passkey credential")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+	defer conn.Release()
+
+	repo := db.New(conn)
+	challengeUserID, session, err := s.consumeChallenge(ctx, repo, payload.ChallengeID, passkeyCeremonyRegistration)
+	if err != nil {
+		return err
+	}
+
+	if challengeUserID == nil || *challengeUserID != userID {
+		slog.ErrorContext(ctx, "passkey challenge belongs to another user", slog.Int64("userID", userID))
+		return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodePasskeyChallengeInvalid, "invalid passkey challenge")
+	}
+
+	user, err := s.getPasskeyUser(ctx, repo, userID)
+	if err != nil {
+		return err
+	}
+
+	credential, err := s.webauthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not validate passkey registration", slog.Any("error", err))
+		return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodePasskeyCredentialInvalid, "invalid passkey credential")
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	err = repo.CreatePasskeyCredential(ctx, db.CreatePasskeyCredentialParams{
+		UserID:          userID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Aaguid:          credential.Authenticator.AAGUID,
+		SignCount:       int64(credential.Authenticator.SignCount),
+		Transports:      transports,
+		Flags:           int16(credential.Flags.ProtocolValue()),
+		CreatedAt:       pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "unique_passkey_credential_id" {
+			return dto.NewErrorWithCode(http.StatusConflict, dto.ErrCodePasskeyAlreadyRegistered, "passkey is already registered")
+		}
+
+		slog.ErrorContext(ctx, "could not store passkey", slog.Int64("userID", userID), slog.Any("error", err))
+		return dto.NewError("could not store passkey")
+	}
+
+	return nil
+}
+
+// GenerateDbUser is not supported, a passkey can only be added to an existing
+// account as there is no verified email to create the account with
+func (s *passkeyService) GenerateDbUser(ctx context.Context, p any) (*db.CreateUserParams, *Identity, error) {
+	return nil, nil, dto.NewErrorWithParams(http.StatusBadRequest, dto.ErrCodeAccountNotFound, "create the account with another provider, then link a passkey", map[string]string{"provider": s.AuthKey()})
+}
+
+// passkeyIdentity is the same for all passkeys of a user, the user handle of
+// every credential is the user id
+func passkeyIdentity(userID int64, email string) *Identity {
+	return &Identity{
+		Subject: strconv.FormatInt(userID, 10),
+		Email:   email,
+		Profile: map[string]any{},
+	}
+}
+
+func (s *passkeyService) getPasskeyUser(ctx context.Context, repo *db.Queries, userID int64) (*passkeyUser, error) {
+	user, err := repo.GetUser(ctx, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, dto.NewErrorWithCode(http.StatusNotFound, dto.ErrCodeUserNotFound, "user not found")
+		}
+
+		slog.ErrorContext(ctx, "could not get user", slog.Any("error", err))
+		return nil, dto.NewError("could not get user")
+	}
+
+	dbCredentials, err := repo.ListPasskeyCredentials(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get passkeys", slog.Int64("userID", userID), slog.Any("error", err))
+		return nil, dto.NewError("could not get passkeys")
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(dbCredentials))
+	for _, c := range dbCredentials {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(c.Transports))
+		for _, transport := range c.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              c.CredentialID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(c.Flags)),
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.Aaguid,
+				SignCount: uint32(c.SignCount),
+			},
+		})
+	}
+
+	return &passkeyUser{
+		id:          user.ID,
+		name:        user.Name,
+		email:       user.Email,
+		credentials: credentials,
+	}, nil
+}
+
+func (s *passkeyService) storeChallenge(ctx context.Context, repo *db.Queries, userID *int64, ceremony string, session *webauthn.SessionData, options any) (*dto.PasskeyOptionsResponse, error) {
+	challengeID, err := uuid.NewRandom()
+	if err != nil {
+		slog.ErrorContext(ctx, "could not generate challenge id", slog.Any("error", err))
+		return nil, dto.NewError("could not create passkey challenge")
+	}
+
+	sessionData, err := json.Marshal(session)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not encode passkey session", slog.Any("error", err))
+		return nil, dto.NewError("could not create passkey challenge")
+	}
+
+	duration := s.config.CHALLENGE_DURATION * time.Second
+	if duration <= 0 {
+		duration = defaultPasskeyChallengeDuration
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(duration)
+
+	// clean up ceremonies that were never finished
+	err = repo.DeleteExpiredWebauthnChallenges(ctx, pgtype.Timestamptz{Time: now, Valid: true})
+	if err != nil {
+		slog.WarnContext(ctx, "could not delete expired passkey challenges", slog.Any("error", err))
+	}
+
+	err = repo.CreateWebauthnChallenge(ctx, db.CreateWebauthnChallengeParams{
+		ID:          pgtype.UUID{Bytes: challengeID, Valid: true},
+		UserID:      userID,
+		Ceremony:    ceremony,
+		SessionData: sessionData,
+		ExpiresAt:   pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		CreatedAt:   pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not store passkey challenge", slog.Any("error", err))
+		return nil, dto.NewError("could not create passkey challenge")
+	}
+
+	return &dto.PasskeyOptionsResponse{
+		ChallengeID: challengeID.String(),
+		Options:     options,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// consumeChallenge deletes the challenge so every ceremony can be finished
+// only once, and returns the user it was started for with its session data
+func (s *passkeyService) consumeChallenge(ctx context.Context, repo *db.Queries, id string, ceremony string) (*int64, *webauthn.SessionData, error) {
+	challengeID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodePasskeyChallengeInvalid, "invalid passkey challenge")
+	}
+
+	challenge, err := repo.ConsumeWebauthnChallenge(ctx, db.ConsumeWebauthnChallengeParams{
+		ID:        pgtype.UUID{Bytes: challengeID, Valid: true},
+		Ceremony:  ceremony,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodePasskeyChallengeInvalid, "passkey challenge is invalid or expired")
+		}
+
+		slog.ErrorContext(ctx, "could not get passkey challenge", slog.Any("error", err))
+		return nil, nil, dto.NewError("could not get passkey challenge")
+	}
+
+	var session webauthn.SessionData
+	err = json.Unmarshal(challenge.SessionData, &session)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not decode passkey session", slog.Any("error", err))
+		return nil, nil, dto.NewError("could not get passkey challenge")
+	}
+
+	return challenge.UserID, &session, nil
+}
+
+func parsePasskeyPayload(ctx context.Context, p string) (*dto.PasskeyPayload, error) {
+	var payload dto.PasskeyPayload
+	err := json.Unmarshal([]byte(p), &payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "passkey payload is not valid json", slog.Any("error", err))
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidPayload, "invalid payload")
+	}
+
+	err = apiUtils.ValidateStruct(payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "validation error", slog.Any("errors", err))
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidPayload, "invalid payload")
+	}
+
+	return &payload, nil
+}
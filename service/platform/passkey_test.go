@@ -0,0 +1,321 @@
+package platformService
+
+import (
+	"backend/api/middleware"
+	"backend/db"
+	"backend/db/dbtest"
+	"backend/dto"
+	"backend/token"
+	"backend/utils"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "https://localhost:8080"
+)
+
+// softAuthenticator is a passkey authenticator in memory, it holds one P-256
+// credential and attests with "none" like most platform authenticators
+type softAuthenticator struct {
+	credentialID []byte
+	key          *ecdsa.PrivateKey
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	if _, err = rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{credentialID: credentialID, key: key}
+}
+
+// register answers navigator.credentials.create with the options and returns
+// the payload the client sends to link the passkey
+func (a *softAuthenticator) register(t *testing.T, options *dto.PasskeyOptionsResponse) string {
+	t.Helper()
+
+	var creation protocol.CredentialCreation
+	decodeOptions(t, options, &creation)
+
+	publicKey, err := a.key.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := publicKey.Bytes()
+	coseKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: point[1:33],
+		YCoord: point[33:],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// user present, user verified and attested credential data
+	authData := a.authenticatorData(creation.Response.RelyingParty.ID, 0x45)
+	authData = append(authData, make([]byte, 16)...) // aaguid
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, coseKey...)
+
+	attestationObject, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.payload(t, options, map[string]any{
+		"clientDataJSON":    encode(clientData(t, "webauthn.create", creation.Response.Challenge)),
+		"attestationObject": encode(attestationObject),
+		"transports":        []string{"internal"},
+	})
+}
+
+// login answers navigator.credentials.get with the options and returns the
+// payload the client sends to login
+func (a *softAuthenticator) login(t *testing.T, options *dto.PasskeyOptionsResponse, userID int64) string {
+	t.Helper()
+
+	var assertion protocol.CredentialAssertion
+	decodeOptions(t, options, &assertion)
+
+	a.signCount++
+	// user present and user verified
+	authData := a.authenticatorData(assertion.Response.RelyingPartyID, 0x05)
+	clientDataJSON := clientData(t, "webauthn.get", assertion.Response.Challenge)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.payload(t, options, map[string]any{
+		"clientDataJSON":    encode(clientDataJSON),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+		"userHandle":        encode(passkeyUserHandle(userID)),
+	})
+}
+
+func (a *softAuthenticator) authenticatorData(rpID string, flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	authData := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(authData, a.signCount)
+}
+
+func (a *softAuthenticator) payload(t *testing.T, options *dto.PasskeyOptionsResponse, response map[string]any) string {
+	t.Helper()
+
+	credential, err := json.Marshal(map[string]any{
+		"id":       encode(a.credentialID),
+		"rawId":    encode(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(dto.PasskeyPayload{ChallengeID: options.ChallengeID, Credential: credential})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(payload)
+}
+
+// decodeOptions reads the options the way the browser gets them, as json
+func decodeOptions(t *testing.T, options *dto.PasskeyOptionsResponse, v any) {
+	t.Helper()
+
+	encoded, err := json.Marshal(options.Options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(encoded, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func clientData(t *testing.T, ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{
+		"type":      ceremony,
+		"challenge": encode(challenge),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newTestPasskeyService(t *testing.T) (*passkeyService, *db.Queries) {
+	t.Helper()
+
+	pool := dbtest.NewPool(t)
+	service, err := NewPasskeyService(pool, utils.PasskeyConfig{
+		RP_ID:           testRPID,
+		RP_DISPLAY_NAME: "Backend Test",
+		RP_ORIGINS:      []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service.(*passkeyService), db.New(pool)
+}
+
+func createTestUser(t *testing.T, repo *db.Queries, email string) int64 {
+	t.Helper()
+
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	userID, err := repo.CreateUser(context.Background(), db.CreateUserParams{
+		Name:          "Passkey User",
+		Email:         email,
+		Password:      "NA",
+		EmailVerified: true,
+		TokenHash:     utils.GenerateRandomString(15),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	return userID
+}
+
+func userContext(userID int64) context.Context {
+	return context.WithValue(context.Background(), middleware.AuthenticationPayloadKey, &token.Payload{UserID: userID})
+}
+
+func assertErrorCode(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var dtoErr *dto.Error
+	if !errors.As(err, &dtoErr) {
+		t.Fatalf("error = %v, want code %s", err, code)
+	}
+	if dtoErr.GetErrorCode() != code {
+		t.Fatalf("error code = %s, want %s", dtoErr.GetErrorCode(), code)
+	}
+}
+
+// registerPasskey runs the registration ceremony for the user
+func registerPasskey(t *testing.T, s *passkeyService, userID int64, authenticator *softAuthenticator) {
+	t.Helper()
+
+	options, err := s.BeginRegistration(userContext(userID), userID)
+	if err != nil {
+		t.Fatalf("could not begin registration: %v", err)
+	}
+	if err = s.LinkExtraInformation(userContext(userID), userID, authenticator.register(t, options)); err != nil {
+		t.Fatalf("could not finish registration: %v", err)
+	}
+}
+
+func TestPasskeyRegisterAndLogin(t *testing.T) {
+	s, repo := newTestPasskeyService(t)
+	userID := createTestUser(t, repo, "passkey@example.com")
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, s, userID, authenticator)
+
+	// the sign count goes up with every login
+	for i := 0; i < 2; i++ {
+		options, err := s.BeginLogin(context.Background())
+		if err != nil {
+			t.Fatalf("could not begin login: %v", err)
+		}
+
+		identity, err := s.LoginGetIdentity(context.Background(), authenticator.login(t, options, userID))
+		if err != nil {
+			t.Fatalf("could not finish login %d: %v", i+1, err)
+		}
+		if identity.Subject != strconv.FormatInt(userID, 10) || identity.Email != "passkey@example.com" {
+			t.Errorf("identity = %+v, want the one of user %d", identity, userID)
+		}
+	}
+}
+
+func TestPasskeyLoginChallengeCanOnlyBeUsedOnce(t *testing.T) {
+	s, repo := newTestPasskeyService(t)
+	userID := createTestUser(t, repo, "replay@example.com")
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, s, userID, authenticator)
+
+	options, err := s.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := authenticator.login(t, options, userID)
+
+	if _, err = s.LoginGetIdentity(context.Background(), payload); err != nil {
+		t.Fatalf("could not login: %v", err)
+	}
+	_, err = s.LoginGetIdentity(context.Background(), payload)
+	assertErrorCode(t, err, dto.ErrCodePasskeyChallengeInvalid)
+}
+
+func TestPasskeyLoginWithUnknownCredential(t *testing.T) {
+	s, repo := newTestPasskeyService(t)
+	userID := createTestUser(t, repo, "unknown@example.com")
+	registerPasskey(t, s, userID, newSoftAuthenticator(t))
+
+	options, err := s.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.LoginGetIdentity(context.Background(), newSoftAuthenticator(t).login(t, options, userID))
+	assertErrorCode(t, err, dto.ErrCodeInvalidCredentials)
+}
+
+func TestPasskeyRegistrationChallengeOfAnotherUser(t *testing.T) {
+	s, repo := newTestPasskeyService(t)
+	userID := createTestUser(t, repo, "first@example.com")
+	otherUserID := createTestUser(t, repo, "second@example.com")
+
+	options, err := s.BeginRegistration(userContext(userID), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.LinkExtraInformation(userContext(otherUserID), otherUserID, newSoftAuthenticator(t).register(t, options))
+	assertErrorCode(t, err, dto.ErrCodePasskeyChallengeInvalid)
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"backend/api/middleware"
+	"backend/db"
+	"backend/dto"
+	"backend/token"
+	"backend/utils"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// createSession stores a new device session for the user and issues the first
+// pair of tokens for it
+func (s *userService) createSession(ctx context.Context, userID int64, tokenHash string, deviceName string, client dto.ClientInfo) (*dto.LoginResponse, error) {
+	sessionID, err := uuid.NewRandom()
+	if err != nil {
+		slog.ErrorContext(ctx, "could not generate session id", slog.Any("error", err))
+		return nil, dto.NewError("could not create session")
+	}
+
+	secret, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not generate session secret", slog.Any("error", err))
+		return nil, dto.NewError("could not create session")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	response, refreshPayload, err := s.generateTokens(ctx, repo, userID, sessionID, tokenHash+secret)
+	if err != nil {
+		return nil, err
+	}
+
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	err = repo.CreateSession(ctx, db.CreateSessionParams{
+		ID:             pgUUID(sessionID),
+		UserID:         userID,
+		DeviceName:     deviceName,
+		IpAddress:      client.IPAddress,
+		UserAgent:      client.UserAgent,
+		Secret:         secret,
+		RefreshTokenID: pgUUID(refreshPayload.ID),
+		CreatedAt:      now,
+		LastUsedAt:     now,
+		ExpiresAt:      pgtype.Timestamptz{Time: refreshPayload.ExpiredAt, Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not create session", slog.Int64("userID", userID), slog.Any("error", err))
+		return nil, dto.NewError("could not create session")
+	}
+
+	slog.InfoContext(ctx, "session created", slog.Int64("userID", userID), slog.String("session_id", sessionID.String()))
+	return response, nil
+}
+
+// GenerateAccessToken rotates the refresh token of the session, presenting a
+// refresh token that was already rotated revokes the whole session
+func (s *userService) GenerateAccessToken(c context.Context, client dto.ClientInfo) (*dto.LoginResponse, error) {
+	refreshPayload := c.Value(middleware.RefreshTokenPayloadKey).(*token.RefreshPayload)
+	ctx := utils.AppendCtx(c, slog.Int64("user_id", refreshPayload.UserID))
+	ctx = utils.AppendCtx(ctx, slog.String("session_id", refreshPayload.SessionID.String()))
+
+	slog.InfoContext(ctx, "generating access token from refresh token")
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	session, err := repo.GetSession(ctx, db.GetSessionParams{
+		ID:     pgUUID(refreshPayload.SessionID),
+		UserID: refreshPayload.UserID,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			slog.InfoContext(ctx, "session not found")
+			return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeTokenInvalid, token.ErrInvalidToken.Error())
+		}
+		slog.ErrorContext(ctx, "error while getting session", slog.Any("error", err))
+		return nil, dto.NewError("could not get user details to generate token")
+	}
+
+	if session.RevokedAt.Valid || time.Now().After(session.ExpiresAt.Time) {
+		slog.InfoContext(ctx, "session is revoked or expired")
+		return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeTokenInvalid, token.ErrInvalidToken.Error())
+	}
+
+	tokenHash, err := repo.GetUserTokenHash(ctx, refreshPayload.UserID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			slog.InfoContext(ctx, "user is disabled or deleted")
+			return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeTokenInvalid, token.ErrInvalidToken.Error())
+		}
+		slog.ErrorContext(ctx, "error while getting user", slog.Any("error", err))
+		return nil, dto.NewError("could not get user details to generate token")
+	}
+
+	// check token hash
+	err = s.tokenMaker.ValidateRefreshHash(refreshPayload.Hash, refreshPayload.UserID, tokenHash+session.Secret)
+	if err != nil {
+		slog.ErrorContext(ctx, "refresh token hash mismatch", slog.Any("error", err))
+		return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeTokenInvalid, err.Error())
+	}
+
+	if session.RefreshTokenID.Bytes != refreshPayload.ID {
+		return nil, s.revokeReusedSession(ctx, repo, refreshPayload)
+	}
+
+	response, newRefreshPayload, err := s.generateTokens(ctx, repo, refreshPayload.UserID, refreshPayload.SessionID, tokenHash+session.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := repo.RotateSessionRefreshToken(ctx, db.RotateSessionRefreshTokenParams{
+		NewRefreshTokenID: pgUUID(newRefreshPayload.ID),
+		IpAddress:         client.IPAddress,
+		UserAgent:         client.UserAgent,
+		LastUsedAt:        pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		ExpiresAt:         pgtype.Timestamptz{Time: newRefreshPayload.ExpiredAt, Valid: true},
+		ID:                pgUUID(refreshPayload.SessionID),
+		RefreshTokenID:    pgUUID(refreshPayload.ID),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not rotate refresh token", slog.Any("error", err))
+		return nil, dto.NewError("could not refresh token")
+	}
+
+	// another request rotated the same token in the meantime
+	if rotated == 0 {
+		return nil, s.revokeReusedSession(ctx, repo, refreshPayload)
+	}
+
+	return response, nil
+}
+
+// revokeReusedSession ends the session a retired refresh token was presented
+// for, the token may have been stolen so its access tokens are revoked as well
+func (s *userService) revokeReusedSession(ctx context.Context, repo *db.Queries, refreshPayload *token.RefreshPayload) error {
+	slog.WarnContext(ctx, "retired refresh token reused, revoking session", slog.String("token_id", refreshPayload.ID.String()))
+
+	_, err := repo.RevokeSession(ctx, db.RevokeSessionParams{
+		ID:        pgUUID(refreshPayload.SessionID),
+		UserID:    refreshPayload.UserID,
+		RevokedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not revoke session", slog.Any("error", err))
+		return dto.NewError("could not refresh token")
+	}
+
+	if err = s.revokeSessionAccessTokens(ctx, refreshPayload.SessionID); err != nil {
+		return err
+	}
+
+	return dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeTokenInvalid, token.ErrInvalidToken.Error())
+}
+
+func (s *userService) ListSessions(ctx context.Context, userID int64) ([]*dto.SessionResponse, error) {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	if currentUser.UserID != userID {
+		return nil, dto.NewErrorWithCode(http.StatusNotFound, dto.ErrCodeUserNotFound, "user not found")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	sessions, err := repo.ListUserSessions(ctx, db.ListUserSessionsParams{
+		UserID:    userID,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not list sessions", slog.Any("error", err))
+		return nil, dto.NewError("could not get sessions")
+	}
+
+	response := make([]*dto.SessionResponse, 0, len(sessions))
+	for i := range sessions {
+		response = append(response, dto.SessionResponseFromDB(&sessions[i]))
+	}
+
+	return response, nil
+}
+
+// RevokeSession ends a session of the user and revokes the access tokens
+// issued for it
+func (s *userService) RevokeSession(ctx context.Context, userID int64, sessionID string) error {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	if currentUser.UserID != userID {
+		return dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeUserNotFound, "user not found")
+	}
+
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return dto.NewErrorWithCode(http.StatusNotFound, dto.ErrCodeSessionNotFound, "session not found")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	revoked, err := repo.RevokeSession(ctx, db.RevokeSessionParams{
+		ID:        pgUUID(id),
+		UserID:    userID,
+		RevokedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not revoke session", slog.Any("error", err))
+		return dto.NewError("could not revoke session")
+	}
+
+	if revoked == 0 {
+		return dto.NewErrorWithCode(http.StatusNotFound, dto.ErrCodeSessionNotFound, "session not found")
+	}
+
+	if err = s.revokeSessionAccessTokens(ctx, id); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "session revoked", slog.Int64("userID", userID), slog.String("session_id", sessionID))
+	return nil
+}
+
+// generateTokens issues the tokens of the session, the access token carries
+// the locale of the user so a change shows up with the next refresh
+func (s *userService) generateTokens(ctx context.Context, repo *db.Queries, userID int64, sessionID uuid.UUID, tokenHash string) (*dto.LoginResponse, *token.RefreshPayload, error) {
+	var response dto.LoginResponse
+	var refreshPayload *token.RefreshPayload
+
+	locale, err := repo.GetUserLocale(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get user locale", slog.Any("error", err))
+		return nil, nil, dto.NewError("could not access create token")
+	}
+
+	claims := token.AccessClaims{
+		Scopes:    s.config.TOKEN.SCOPES,
+		SessionID: sessionID,
+	}
+	if locale != nil {
+		claims.Locale = *locale
+	}
+	if s.config.TOKEN.AUDIENCE != "" {
+		claims.Audience = []string{s.config.TOKEN.AUDIENCE}
+	}
+
+	response.AccessToken, err = s.tokenMaker.CreateAccessToken(userID, claims)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not access create token", slog.Any("error", err))
+		return nil, nil, dto.NewError("could not access create token")
+	}
+
+	response.RefreshToken, refreshPayload, err = s.tokenMaker.CreateRefreshToken(userID, sessionID, tokenHash)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not refresh create token", slog.Any("error", err))
+		return nil, nil, dto.NewError("could not refresh create token")
+	}
+
+	return &response, refreshPayload, nil
+}
+
+func pgUUID(id uuid.UUID) pgtype.UUID {
+	return pgtype.UUID{Bytes: id, Valid: true}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"backend/api/apiUtils"
+	"backend/dto"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionID returns the sid of the access token
+func (s *testService) sessionID(t *testing.T, accessToken string) string {
+	t.Helper()
+
+	payload, err := s.tokenMaker.ValidateAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("access token is invalid: %v", err)
+	}
+	return payload.SessionID.String()
+}
+
+func TestRevokeSessionRevokesItsAccessTokens(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "revoke-session@example.com", "password123")
+	phone := s.login(t, "revoke-session@example.com", "password123")
+	laptop := s.login(t, "revoke-session@example.com", "password123")
+
+	phoneSession := s.refreshContext(t, phone.RefreshToken)
+	sessionID := s.sessionID(t, phone.AccessToken)
+
+	if err := s.RevokeSession(s.accessContext(t, laptop.AccessToken), userID, sessionID); err != nil {
+		t.Fatalf("could not revoke session: %v", err)
+	}
+
+	if !s.accessTokenRevoked(t, phone.AccessToken) {
+		t.Error("access token of the revoked session still works")
+	}
+	_, err := s.GenerateAccessToken(phoneSession, dto.ClientInfo{})
+	assertErrorCode(t, err, dto.ErrCodeTokenInvalid)
+
+	if s.accessTokenRevoked(t, laptop.AccessToken) {
+		t.Error("revoking a session revoked the access token of another session")
+	}
+}
+
+func TestRevokeSessionOfAnotherUser(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "session-owner@example.com", "password123")
+	tokens := s.login(t, "session-owner@example.com", "password123")
+	sessionID := s.sessionID(t, tokens.AccessToken)
+
+	err := s.RevokeSession(authContext(userID+1), userID, sessionID)
+	assertErrorCode(t, err, dto.ErrCodeUserNotFound)
+
+	if s.accessTokenRevoked(t, tokens.AccessToken) {
+		t.Error("another user revoked the access tokens of the session")
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	s := newTestService(t)
+	s.signUp(t, "reuse@example.com", "password123")
+	tokens := s.login(t, "reuse@example.com", "password123")
+
+	refreshed, err := s.GenerateAccessToken(s.refreshContext(t, tokens.RefreshToken), dto.ClientInfo{})
+	if err != nil {
+		t.Fatalf("could not refresh: %v", err)
+	}
+	if refreshed.RefreshToken == tokens.RefreshToken {
+		t.Fatal("refresh did not rotate the refresh token")
+	}
+
+	// the retired refresh token is presented again, as a thief would
+	_, err = s.GenerateAccessToken(s.refreshContext(t, tokens.RefreshToken), dto.ClientInfo{})
+	assertErrorCode(t, err, dto.ErrCodeTokenInvalid)
+
+	_, err = s.GenerateAccessToken(s.refreshContext(t, refreshed.RefreshToken), dto.ClientInfo{})
+	assertErrorCode(t, err, dto.ErrCodeTokenInvalid)
+
+	for _, accessToken := range []string{tokens.AccessToken, refreshed.AccessToken} {
+		if !s.accessTokenRevoked(t, accessToken) {
+			t.Error("access token of the reused session still works")
+		}
+	}
+}
+
+func TestLongUserAgent(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "long-user-agent@example.com", "password123")
+
+	// the client info as the handlers build it from the request
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest("POST", "/api/v1/login", nil)
+	ginCtx.Request.Header.Set("User-Agent", strings.Repeat("a", 5000))
+	client := apiUtils.GetClientInfo(ginCtx)
+
+	response, _, err := s.Login(context.Background(), &dto.LoginRequest{
+		Provider:   s.AuthKey(),
+		Payload:    "long-user-agent@example.com|password123",
+		DeviceName: "test",
+		Client:     client,
+	})
+	if err != nil {
+		t.Fatalf("could not login with a long user agent: %v", err)
+	}
+	if _, err = s.GenerateAccessToken(s.refreshContext(t, response.RefreshToken), client); err != nil {
+		t.Fatalf("could not refresh with a long user agent: %v", err)
+	}
+
+	sessions, err := s.ListSessions(authContext(userID), userID)
+	if err != nil {
+		t.Fatalf("could not list sessions: %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1", len(sessions))
+	}
+	if len(sessions[0].UserAgent) != 1024 {
+		t.Errorf("user agent of %d characters, want it cut to 1024", len(sessions[0].UserAgent))
+	}
+}
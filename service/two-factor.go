@@ -0,0 +1,529 @@
+package service
+
+import (
+	"backend/api/middleware"
+	"backend/db"
+	"backend/dto"
+	"backend/metrics"
+	"backend/token"
+	"backend/utils"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	tokenPurposeLoginChallenge    = "login_challenge"
+	defaultLoginChallengeDuration = 5 * time.Minute
+	defaultTwoFactorIssuer        = "backend"
+	defaultTotpMaxFailedAttempts  = 5
+	defaultTotpLockoutDuration    = 15 * time.Minute
+	recoveryCodeCount             = 10
+	recoveryCodeLength            = 10
+	recoveryCodeAlphabet          = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+func (s *userService) EnrollTotp(ctx context.Context, userID int64) (*dto.TotpEnrollResponse, error) {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	if currentUser.UserID != userID {
+		return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeUserNotFound, "user not found")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	user, err := repo.GetUserTwoFactor(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get user two factor details", slog.Any("error", err))
+		return nil, dto.NewError("could not get user")
+	}
+
+	if user.TotpEnabled {
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeTwoFactorAlreadyEnabled, "two factor authentication is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		slog.ErrorContext(ctx, "could not generate totp secret", slog.Any("error", err))
+		return nil, dto.NewError("could not enroll two factor authentication")
+	}
+
+	err = repo.SetTotpSecret(ctx, db.SetTotpSecretParams{
+		ID:         userID,
+		TotpSecret: &secret,
+		UpdatedAt:  pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not store totp secret", slog.Any("error", err))
+		return nil, dto.NewError("could not enroll two factor authentication")
+	}
+
+	slog.InfoContext(ctx, "totp enrollment started", slog.Int64("userID", userID))
+	return &dto.TotpEnrollResponse{
+		Secret: secret,
+		URI:    utils.TOTPURI(s.twoFactorIssuer(), user.Email, secret),
+	}, nil
+}
+
+// ConfirmTotp enables two factor authentication once the user proves their
+// authenticator app generates valid codes, it returns the recovery codes
+func (s *userService) ConfirmTotp(ctx context.Context, userID int64, request *dto.TotpCodeRequest) (*dto.RecoveryCodesResponse, error) {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	if currentUser.UserID != userID {
+		return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeUserNotFound, "user not found")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	user, err := repo.GetUserTwoFactor(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get user two factor details", slog.Any("error", err))
+		return nil, dto.NewError("could not get user")
+	}
+
+	if user.TotpEnabled {
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeTwoFactorAlreadyEnabled, "two factor authentication is already enabled")
+	}
+	if user.TotpSecret == nil {
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeTwoFactorNotStarted, "two factor authentication enrollment was not started")
+	}
+
+	ok, err := s.limitCodeAttempts(ctx, repo, userID, func() (bool, error) {
+		return s.verifyTotpCode(ctx, repo, userID, user, request.Code)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidCode, "invalid code")
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not start transaction", slog.Any("error", err))
+		return nil, dto.NewError("could not enable two factor authentication")
+	}
+	defer tx.Rollback(ctx)
+
+	err = repo.WithTx(tx).EnableTotp(ctx, db.EnableTotpParams{
+		ID:        userID,
+		UpdatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not enable totp", slog.Any("error", err))
+		return nil, dto.NewError("could not enable two factor authentication")
+	}
+
+	codes, err := s.replaceRecoveryCodes(ctx, repo.WithTx(tx), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		slog.ErrorContext(ctx, "could not commit totp enrollment", slog.Any("error", err))
+		return nil, dto.NewError("could not enable two factor authentication")
+	}
+
+	slog.InfoContext(ctx, "two factor authentication enabled", slog.Int64("userID", userID))
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the user with new ones
+func (s *userService) RegenerateRecoveryCodes(ctx context.Context, userID int64, request *dto.TotpCodeRequest) (*dto.RecoveryCodesResponse, error) {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	if currentUser.UserID != userID {
+		return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeUserNotFound, "user not found")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	user, err := repo.GetUserTwoFactor(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get user two factor details", slog.Any("error", err))
+		return nil, dto.NewError("could not get user")
+	}
+
+	if !user.TotpEnabled {
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeTwoFactorNotEnabled, "two factor authentication is not enabled")
+	}
+
+	ok, err := s.limitCodeAttempts(ctx, repo, userID, func() (bool, error) {
+		return s.verifyTotpCode(ctx, repo, userID, user, request.Code)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidCode, "invalid code")
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not start transaction", slog.Any("error", err))
+		return nil, dto.NewError("could not generate recovery codes")
+	}
+	defer tx.Rollback(ctx)
+
+	codes, err := s.replaceRecoveryCodes(ctx, repo.WithTx(tx), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		slog.ErrorContext(ctx, "could not commit recovery codes", slog.Any("error", err))
+		return nil, dto.NewError("could not generate recovery codes")
+	}
+
+	slog.InfoContext(ctx, "recovery codes regenerated", slog.Int64("userID", userID))
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTotp turns two factor authentication off, it needs a totp or recovery
+// code so a stolen access token alone cannot do it, wrong codes count towards
+// the lockout of the user
+func (s *userService) DisableTotp(ctx context.Context, userID int64, request *dto.TotpCodeRequest) error {
+	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
+
+	if currentUser.UserID != userID {
+		return dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeUserNotFound, "user not found")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	user, err := repo.GetUserTwoFactor(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get user two factor details", slog.Any("error", err))
+		return dto.NewError("could not get user")
+	}
+
+	if !user.TotpEnabled {
+		return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeTwoFactorNotEnabled, "two factor authentication is not enabled")
+	}
+
+	ok, err := s.limitCodeAttempts(ctx, repo, userID, func() (bool, error) {
+		return s.verifySecondFactor(ctx, repo, userID, user, request.Code)
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidCode, "invalid code")
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not start transaction", slog.Any("error", err))
+		return dto.NewError("could not disable two factor authentication")
+	}
+	defer tx.Rollback(ctx)
+
+	err = repo.WithTx(tx).DisableTotp(ctx, db.DisableTotpParams{
+		ID:        userID,
+		UpdatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not disable totp", slog.Any("error", err))
+		return dto.NewError("could not disable two factor authentication")
+	}
+
+	err = repo.WithTx(tx).DeleteRecoveryCodes(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not delete recovery codes", slog.Any("error", err))
+		return dto.NewError("could not disable two factor authentication")
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		slog.ErrorContext(ctx, "could not commit disabling totp", slog.Any("error", err))
+		return dto.NewError("could not disable two factor authentication")
+	}
+
+	slog.InfoContext(ctx, "two factor authentication disabled", slog.Int64("userID", userID))
+	return nil
+}
+
+// LoginTwoFactor exchanges the challenge token from the first login step and a
+// totp or recovery code for a new session, it is counted as the "two_factor"
+// provider in the login metrics
+func (s *userService) LoginTwoFactor(ctx context.Context, request *dto.TwoFactorLoginRequest) (*dto.LoginResponse, error) {
+	response, err := s.loginTwoFactor(ctx, request)
+	metrics.ObserveLogin("two_factor", loginResult(nil, err))
+	return response, err
+}
+
+func (s *userService) loginTwoFactor(ctx context.Context, request *dto.TwoFactorLoginRequest) (*dto.LoginResponse, error) {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return nil, err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	challengeHash := utils.HashToken(request.ChallengeToken)
+
+	challenge, err := repo.GetActiveUserToken(ctx, db.GetActiveUserTokenParams{
+		Purpose:   tokenPurposeLoginChallenge,
+		TokenHash: challengeHash,
+		ExpiresAt: now,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			slog.InfoContext(ctx, "login challenge is invalid, expired or already used")
+			return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeTwoFactorChallengeInvalid, "login challenge is invalid or expired")
+		}
+		slog.ErrorContext(ctx, "could not get login challenge", slog.Any("error", err))
+		return nil, dto.NewError("could not verify code")
+	}
+
+	user, err := repo.GetUserTwoFactor(ctx, challenge.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get user two factor details", slog.Any("error", err))
+		return nil, dto.NewError("could not get user")
+	}
+
+	if !user.TotpEnabled {
+		slog.InfoContext(ctx, "two factor authentication was disabled after the challenge was issued")
+		return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeTwoFactorChallengeInvalid, "login challenge is invalid or expired")
+	}
+
+	// wrong codes are counted per user, a new challenge does not give more
+	// guesses
+	ok, err := s.limitCodeAttempts(ctx, repo, challenge.UserID, func() (bool, error) {
+		return s.verifySecondFactor(ctx, repo, challenge.UserID, user, request.Code)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		slog.InfoContext(ctx, "invalid second factor", slog.Int64("userID", challenge.UserID))
+		return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeInvalidCredentials, "invalid credentials")
+	}
+
+	// consuming fails if the challenge was used by a parallel request
+	_, err = repo.ConsumeUserToken(ctx, db.ConsumeUserTokenParams{
+		Purpose:   tokenPurposeLoginChallenge,
+		TokenHash: challengeHash,
+		UsedAt:    now,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeTwoFactorChallengeInvalid, "login challenge is invalid or expired")
+		}
+		slog.ErrorContext(ctx, "could not consume login challenge", slog.Any("error", err))
+		return nil, dto.NewError("could not verify code")
+	}
+
+	tokenHash, err := repo.GetUserTokenHash(ctx, challenge.UserID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			slog.InfoContext(ctx, "user is disabled or deleted")
+			return nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeAccountDisabled, "account is disabled")
+		}
+		slog.ErrorContext(ctx, "error while getting user", slog.Any("error", err))
+		return nil, dto.NewError("could not get user details to generate token")
+	}
+
+	slog.InfoContext(ctx, "generating tokens for user after second factor", slog.Int64("userID", challenge.UserID))
+	return s.createSession(ctx, challenge.UserID, tokenHash, request.DeviceName, request.Client)
+}
+
+func (s *userService) createLoginChallenge(ctx context.Context, userID int64, email string) (*dto.LoginChallengeResponse, error) {
+	duration := s.config.TWO_FACTOR.CHALLENGE_DURATION * time.Second
+	if duration == 0 {
+		duration = defaultLoginChallengeDuration
+	}
+
+	challengeToken, err := s.createUserToken(ctx, userID, email, tokenPurposeLoginChallenge, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "second factor required for login", slog.Int64("userID", userID))
+	return &dto.LoginChallengeResponse{
+		ChallengeToken: challengeToken,
+		ExpiresAt:      time.Now().Add(duration),
+	}, nil
+}
+
+// limitCodeAttempts runs verify unless the second factor of the user is locked,
+// the attempt is counted before the code is checked so parallel requests cannot
+// get past the limit, a valid code resets the count
+func (s *userService) limitCodeAttempts(ctx context.Context, repo *db.Queries, userID int64, verify func() (bool, error)) (bool, error) {
+	maxAttempts := s.config.TWO_FACTOR.MAX_FAILED_ATTEMPTS
+	if maxAttempts == 0 {
+		maxAttempts = defaultTotpMaxFailedAttempts
+	}
+
+	lockoutDuration := s.config.TWO_FACTOR.LOCKOUT_DURATION * time.Second
+	if lockoutDuration == 0 {
+		lockoutDuration = defaultTotpLockoutDuration
+	}
+
+	now := time.Now()
+	counted, err := repo.IncrementTotpFailedAttempts(ctx, db.IncrementTotpFailedAttemptsParams{
+		MaxAttempts: maxAttempts,
+		LockedUntil: pgtype.Timestamptz{Time: now.Add(lockoutDuration), Valid: true},
+		ID:          userID,
+		Now:         pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not count second factor attempt", slog.Any("error", err))
+		return false, dto.NewError("could not verify code")
+	}
+	if counted == 0 {
+		slog.InfoContext(ctx, "second factor is locked", slog.Int64("userID", userID))
+		return false, dto.NewErrorWithCode(http.StatusTooManyRequests, dto.ErrCodeTwoFactorLocked, "too many wrong codes, try again later")
+	}
+
+	ok, err := verify()
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if err = repo.ResetTotpFailedAttempts(ctx, userID); err != nil {
+		slog.ErrorContext(ctx, "could not reset second factor attempts", slog.Any("error", err))
+		return false, dto.NewError("could not verify code")
+	}
+	return true, nil
+}
+
+// verifySecondFactor accepts either a totp code or an unused recovery code
+func (s *userService) verifySecondFactor(ctx context.Context, repo *db.Queries, userID int64, user db.GetUserTwoFactorRow, code string) (bool, error) {
+	ok, err := s.verifyTotpCode(ctx, repo, userID, user, code)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	used, err := repo.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: utils.HashToken(normalizeRecoveryCode(code)),
+		UsedAt:   pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not use recovery code", slog.Any("error", err))
+		return false, dto.NewError("could not verify code")
+	}
+
+	if used > 0 {
+		slog.InfoContext(ctx, "recovery code used", slog.Int64("userID", userID))
+	}
+	return used > 0, nil
+}
+
+// verifyTotpCode validates the code and remembers its time step, so the same
+// code cannot be used twice
+func (s *userService) verifyTotpCode(ctx context.Context, repo *db.Queries, userID int64, user db.GetUserTwoFactorRow, code string) (bool, error) {
+	if user.TotpSecret == nil {
+		return false, nil
+	}
+
+	step, ok := utils.ValidateTOTPCode(*user.TotpSecret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	updated, err := repo.UpdateTotpLastCounter(ctx, db.UpdateTotpLastCounterParams{
+		ID:              userID,
+		TotpLastCounter: step,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not store totp counter", slog.Any("error", err))
+		return false, dto.NewError("could not verify code")
+	}
+
+	if updated == 0 {
+		slog.InfoContext(ctx, "totp code replayed", slog.Int64("userID", userID))
+		return false, nil
+	}
+	return true, nil
+}
+
+func (s *userService) replaceRecoveryCodes(ctx context.Context, repo *db.Queries, userID int64) ([]string, error) {
+	err := repo.DeleteRecoveryCodes(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not delete recovery codes", slog.Any("error", err))
+		return nil, dto.NewError("could not generate recovery codes")
+	}
+
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	codes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			slog.ErrorContext(ctx, "could not generate recovery code", slog.Any("error", err))
+			return nil, dto.NewError("could not generate recovery codes")
+		}
+
+		err = repo.CreateRecoveryCode(ctx, db.CreateRecoveryCodeParams{
+			UserID:    userID,
+			CodeHash:  utils.HashToken(normalizeRecoveryCode(code)),
+			CreatedAt: now,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "could not store recovery code", slog.Any("error", err))
+			return nil, dto.NewError("could not generate recovery codes")
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+func (s *userService) twoFactorIssuer() string {
+	if s.config.TWO_FACTOR.ISSUER == "" {
+		return defaultTwoFactorIssuer
+	}
+	return s.config.TWO_FACTOR.ISSUER
+}
+
+// generateRecoveryCode returns a code like "k3p9x-7hmqa"
+func generateRecoveryCode() (string, error) {
+	raw, err := utils.GenerateSecureString(recoveryCodeLength, recoveryCodeAlphabet)
+	if err != nil {
+		return "", err
+	}
+	return raw[:recoveryCodeLength/2] + "-" + raw[recoveryCodeLength/2:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
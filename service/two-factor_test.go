@@ -0,0 +1,280 @@
+package service
+
+import (
+	"backend/dto"
+	"backend/utils"
+	"context"
+	"testing"
+	"time"
+)
+
+// twoFactorUser is a user with totp enabled, the codes of the previous time
+// step were used to confirm it
+type twoFactorUser struct {
+	userID        int64
+	secret        string
+	recoveryCodes []string
+	tokens        *dto.LoginResponse
+}
+
+func (s *testService) enableTotp(t *testing.T, email string, password string) *twoFactorUser {
+	t.Helper()
+
+	userID := s.signUp(t, email, password)
+	tokens := s.login(t, email, password)
+	ctx := s.accessContext(t, tokens.AccessToken)
+
+	enrollment, err := s.EnrollTotp(ctx, userID)
+	if err != nil {
+		t.Fatalf("could not enroll totp: %v", err)
+	}
+
+	// the previous step leaves the current and next one for the test
+	response, err := s.ConfirmTotp(ctx, userID, &dto.TotpCodeRequest{Code: totpCode(t, enrollment.Secret, -1)})
+	if err != nil {
+		t.Fatalf("could not confirm totp: %v", err)
+	}
+	if len(response.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(response.RecoveryCodes), recoveryCodeCount)
+	}
+
+	return &twoFactorUser{
+		userID:        userID,
+		secret:        enrollment.Secret,
+		recoveryCodes: response.RecoveryCodes,
+		tokens:        tokens,
+	}
+}
+
+// totpCode returns the code of the time step steps away from now
+func totpCode(t *testing.T, secret string, steps int) string {
+	t.Helper()
+
+	code, err := utils.GenerateTOTPCode(secret, time.Now().Add(time.Duration(steps)*30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// loginChallenge logs in with the password and returns the challenge token
+// of the second step
+func (s *testService) loginChallenge(t *testing.T, email string, password string) string {
+	t.Helper()
+
+	response, challenge, err := s.Login(context.Background(), &dto.LoginRequest{
+		Provider:   s.AuthKey(),
+		Payload:    email + "|" + password,
+		DeviceName: "test",
+	})
+	if err != nil {
+		t.Fatalf("could not login: %v", err)
+	}
+	if response != nil {
+		t.Fatal("login returned tokens without the second factor")
+	}
+	if challenge == nil || challenge.ChallengeToken == "" {
+		t.Fatal("login returned no two factor challenge")
+	}
+	return challenge.ChallengeToken
+}
+
+func (s *testService) loginTwoFactorCode(challengeToken string, code string) (*dto.LoginResponse, error) {
+	return s.LoginTwoFactor(context.Background(), &dto.TwoFactorLoginRequest{
+		ChallengeToken: challengeToken,
+		Code:           code,
+		DeviceName:     "test",
+	})
+}
+
+func TestTotpLogin(t *testing.T) {
+	s := newTestService(t)
+	user := s.enableTotp(t, "totp@example.com", "password123")
+	challengeToken := s.loginChallenge(t, "totp@example.com", "password123")
+
+	tokens, err := s.loginTwoFactorCode(challengeToken, totpCode(t, user.secret, 0))
+	if err != nil {
+		t.Fatalf("could not login with totp code: %v", err)
+	}
+	s.accessContext(t, tokens.AccessToken)
+	s.refreshContext(t, tokens.RefreshToken)
+
+	// the challenge is used up with the login
+	_, err = s.loginTwoFactorCode(challengeToken, totpCode(t, user.secret, 1))
+	assertErrorCode(t, err, dto.ErrCodeTwoFactorChallengeInvalid)
+}
+
+func TestTotpEnrollmentNeedsValidCode(t *testing.T) {
+	s := newTestService(t)
+	userID := s.signUp(t, "totp-confirm@example.com", "password123")
+	ctx := s.accessContext(t, s.login(t, "totp-confirm@example.com", "password123").AccessToken)
+
+	_, err := s.ConfirmTotp(ctx, userID, &dto.TotpCodeRequest{Code: "123456"})
+	assertErrorCode(t, err, dto.ErrCodeTwoFactorNotStarted)
+
+	if _, err = s.EnrollTotp(ctx, userID); err != nil {
+		t.Fatalf("could not enroll totp: %v", err)
+	}
+	_, err = s.ConfirmTotp(ctx, userID, &dto.TotpCodeRequest{Code: "abcdef"})
+	assertErrorCode(t, err, dto.ErrCodeInvalidCode)
+
+	// an unconfirmed enrollment does not change the login
+	s.login(t, "totp-confirm@example.com", "password123")
+}
+
+func TestTotpCodeReplay(t *testing.T) {
+	s := newTestService(t)
+	user := s.enableTotp(t, "totp-replay@example.com", "password123")
+	code := totpCode(t, user.secret, 0)
+
+	if _, err := s.loginTwoFactorCode(s.loginChallenge(t, "totp-replay@example.com", "password123"), code); err != nil {
+		t.Fatalf("could not login with totp code: %v", err)
+	}
+
+	_, err := s.loginTwoFactorCode(s.loginChallenge(t, "totp-replay@example.com", "password123"), code)
+	assertErrorCode(t, err, dto.ErrCodeInvalidCredentials)
+
+	// the code of the step used to confirm is older, so it is rejected too
+	_, err = s.loginTwoFactorCode(s.loginChallenge(t, "totp-replay@example.com", "password123"), totpCode(t, user.secret, -1))
+	assertErrorCode(t, err, dto.ErrCodeInvalidCredentials)
+}
+
+func TestRecoveryCodeIsSingleUse(t *testing.T) {
+	s := newTestService(t)
+	user := s.enableTotp(t, "totp-recovery@example.com", "password123")
+	recoveryCode := user.recoveryCodes[0]
+
+	if _, err := s.loginTwoFactorCode(s.loginChallenge(t, "totp-recovery@example.com", "password123"), recoveryCode); err != nil {
+		t.Fatalf("could not login with recovery code: %v", err)
+	}
+
+	_, err := s.loginTwoFactorCode(s.loginChallenge(t, "totp-recovery@example.com", "password123"), recoveryCode)
+	assertErrorCode(t, err, dto.ErrCodeInvalidCredentials)
+
+	// the other codes still work
+	if _, err = s.loginTwoFactorCode(s.loginChallenge(t, "totp-recovery@example.com", "password123"), user.recoveryCodes[1]); err != nil {
+		t.Fatalf("could not login with another recovery code: %v", err)
+	}
+}
+
+// expireTotpLockout ends the lockout of the user as if its time had passed
+func (s *testService) expireTotpLockout(t *testing.T, userID int64) {
+	t.Helper()
+
+	_, err := s.pool.Exec(context.Background(), "UPDATE users SET totp_locked_until = now() - interval '1 second' WHERE id = $1", userID)
+	if err != nil {
+		t.Fatalf("could not expire lockout: %v", err)
+	}
+}
+
+func TestLoginTwoFactorLockout(t *testing.T) {
+	s := newTestService(t)
+	user := s.enableTotp(t, "totp-attempts@example.com", "password123")
+
+	// every wrong code gets a new challenge, the count is kept on the user
+	for range defaultTotpMaxFailedAttempts {
+		_, err := s.loginTwoFactorCode(s.loginChallenge(t, "totp-attempts@example.com", "password123"), "abcdef")
+		assertErrorCode(t, err, dto.ErrCodeInvalidCredentials)
+	}
+
+	_, err := s.loginTwoFactorCode(s.loginChallenge(t, "totp-attempts@example.com", "password123"), totpCode(t, user.secret, 0))
+	assertErrorCode(t, err, dto.ErrCodeTwoFactorLocked)
+	_, err = s.loginTwoFactorCode(s.loginChallenge(t, "totp-attempts@example.com", "password123"), user.recoveryCodes[0])
+	assertErrorCode(t, err, dto.ErrCodeTwoFactorLocked)
+
+	s.expireTotpLockout(t, user.userID)
+
+	if _, err = s.loginTwoFactorCode(s.loginChallenge(t, "totp-attempts@example.com", "password123"), totpCode(t, user.secret, 0)); err != nil {
+		t.Fatalf("could not login after the lockout: %v", err)
+	}
+}
+
+func TestTotpFailedAttemptsReset(t *testing.T) {
+	s := newTestService(t)
+	user := s.enableTotp(t, "totp-reset-attempts@example.com", "password123")
+
+	for range defaultTotpMaxFailedAttempts - 1 {
+		_, err := s.loginTwoFactorCode(s.loginChallenge(t, "totp-reset-attempts@example.com", "password123"), "abcdef")
+		assertErrorCode(t, err, dto.ErrCodeInvalidCredentials)
+	}
+	if _, err := s.loginTwoFactorCode(s.loginChallenge(t, "totp-reset-attempts@example.com", "password123"), totpCode(t, user.secret, 0)); err != nil {
+		t.Fatalf("could not login: %v", err)
+	}
+
+	// the valid code started the count again
+	_, err := s.loginTwoFactorCode(s.loginChallenge(t, "totp-reset-attempts@example.com", "password123"), "abcdef")
+	assertErrorCode(t, err, dto.ErrCodeInvalidCredentials)
+	if _, err = s.loginTwoFactorCode(s.loginChallenge(t, "totp-reset-attempts@example.com", "password123"), user.recoveryCodes[0]); err != nil {
+		t.Fatalf("could not login: %v", err)
+	}
+}
+
+func TestTotpSettingsLockout(t *testing.T) {
+	s := newTestService(t)
+	user := s.enableTotp(t, "totp-settings-attempts@example.com", "password123")
+	ctx := s.accessContext(t, user.tokens.AccessToken)
+
+	// guesses with only an access token lock the settings and the login alike
+	for i := range defaultTotpMaxFailedAttempts {
+		var err error
+		if i%2 == 0 {
+			err = s.DisableTotp(ctx, user.userID, &dto.TotpCodeRequest{Code: "abcdef"})
+		} else {
+			_, err = s.RegenerateRecoveryCodes(ctx, user.userID, &dto.TotpCodeRequest{Code: "123456"})
+		}
+		assertErrorCode(t, err, dto.ErrCodeInvalidCode)
+	}
+
+	err := s.DisableTotp(ctx, user.userID, &dto.TotpCodeRequest{Code: user.recoveryCodes[0]})
+	assertErrorCode(t, err, dto.ErrCodeTwoFactorLocked)
+	_, err = s.RegenerateRecoveryCodes(ctx, user.userID, &dto.TotpCodeRequest{Code: totpCode(t, user.secret, 0)})
+	assertErrorCode(t, err, dto.ErrCodeTwoFactorLocked)
+	_, err = s.loginTwoFactorCode(s.loginChallenge(t, "totp-settings-attempts@example.com", "password123"), totpCode(t, user.secret, 0))
+	assertErrorCode(t, err, dto.ErrCodeTwoFactorLocked)
+
+	s.expireTotpLockout(t, user.userID)
+
+	if err = s.DisableTotp(ctx, user.userID, &dto.TotpCodeRequest{Code: user.recoveryCodes[0]}); err != nil {
+		t.Fatalf("could not disable totp after the lockout: %v", err)
+	}
+}
+
+func TestTotpLockoutConfig(t *testing.T) {
+	s := newTestServiceWithConfig(t, func(config *utils.Config) {
+		config.TWO_FACTOR.MAX_FAILED_ATTEMPTS = 2
+	})
+	user := s.enableTotp(t, "totp-config-attempts@example.com", "password123")
+	ctx := s.accessContext(t, user.tokens.AccessToken)
+
+	for range 2 {
+		err := s.DisableTotp(ctx, user.userID, &dto.TotpCodeRequest{Code: "abcdef"})
+		assertErrorCode(t, err, dto.ErrCodeInvalidCode)
+	}
+
+	err := s.DisableTotp(ctx, user.userID, &dto.TotpCodeRequest{Code: totpCode(t, user.secret, 0)})
+	assertErrorCode(t, err, dto.ErrCodeTwoFactorLocked)
+}
+
+func TestDisableTotp(t *testing.T) {
+	s := newTestService(t)
+	user := s.enableTotp(t, "totp-disable@example.com", "password123")
+	ctx := s.accessContext(t, user.tokens.AccessToken)
+
+	err := s.DisableTotp(ctx, user.userID, &dto.TotpCodeRequest{Code: "abcdef"})
+	assertErrorCode(t, err, dto.ErrCodeInvalidCode)
+
+	// a challenge issued while totp was enabled cannot be finished afterwards
+	challengeToken := s.loginChallenge(t, "totp-disable@example.com", "password123")
+
+	if err = s.DisableTotp(ctx, user.userID, &dto.TotpCodeRequest{Code: totpCode(t, user.secret, 0)}); err != nil {
+		t.Fatalf("could not disable totp: %v", err)
+	}
+
+	s.login(t, "totp-disable@example.com", "password123")
+
+	_, err = s.loginTwoFactorCode(challengeToken, user.recoveryCodes[0])
+	assertErrorCode(t, err, dto.ErrCodeTwoFactorChallengeInvalid)
+
+	err = s.DisableTotp(ctx, user.userID, &dto.TotpCodeRequest{Code: totpCode(t, user.secret, 1)})
+	assertErrorCode(t, err, dto.ErrCodeTwoFactorNotEnabled)
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"backend/db"
+	platformService "backend/service/platform"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// findUserIdentity resolves the identity to the linked user, identities moved
+// over from the auth_providers array only know the email and get their
+// subject on the first login
+func (s *userService) findUserIdentity(ctx context.Context, repo *db.Queries, provider string, identity *platformService.Identity) (db.GetUserIdentityRow, error) {
+	userIdentity, err := repo.GetUserIdentity(ctx, db.GetUserIdentityParams{
+		Provider: provider,
+		Subject:  identity.Subject,
+	})
+	if err != pgx.ErrNoRows || identity.Email == "" {
+		return userIdentity, err
+	}
+
+	legacyIdentity, err := repo.ClaimLegacyUserIdentity(ctx, db.ClaimLegacyUserIdentityParams{
+		Subject:   identity.Subject,
+		UpdatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		Provider:  provider,
+		Email:     identity.Email,
+	})
+	if err != nil {
+		return db.GetUserIdentityRow{}, err
+	}
+
+	slog.InfoContext(ctx, "claimed legacy identity", slog.String("provider", provider), slog.Int64("userID", legacyIdentity.UserID))
+	return db.GetUserIdentityRow(legacyIdentity), nil
+}
+
+func (s *userService) createUserIdentity(ctx context.Context, repo *db.Queries, userID int64, provider string, identity *platformService.Identity) error {
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	return repo.CreateUserIdentity(ctx, db.CreateUserIdentityParams{
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		Profile:   identityProfile(ctx, identity),
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+func identityProfile(ctx context.Context, identity *platformService.Identity) []byte {
+	if len(identity.Profile) == 0 {
+		return []byte("{}")
+	}
+
+	profile, err := json.Marshal(identity.Profile)
+	if err != nil {
+		slog.WarnContext(ctx, "could not encode identity profile", slog.Any("error", err))
+		return []byte("{}")
+	}
+	return profile
+}
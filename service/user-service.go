@@ -5,6 +5,9 @@ import (
 	"backend/api/middleware"
 	"backend/db"
 	"backend/dto"
+	"backend/mailer"
+	"backend/metrics"
+	"backend/revocation"
 	platformService "backend/service/platform"
 	"backend/token"
 	"backend/utils"
@@ -25,22 +28,51 @@ import (
 type UserService interface {
 	CreateUser(context.Context, *dto.CreateUserRequest) error
 	GetUser(context.Context, int64) (*dto.GetUserResponse, error)
-	Login(context.Context, *dto.LoginRequest) (*dto.LoginResponse, error)
+	UpdateLocale(context.Context, int64, *dto.UpdateLocaleRequest) error
+	Login(context.Context, *dto.LoginRequest) (*dto.LoginResponse, *dto.LoginChallengeResponse, error)
+	LoginTwoFactor(context.Context, *dto.TwoFactorLoginRequest) (*dto.LoginResponse, error)
 	ConnectAuthPlatform(context.Context, int64, *dto.ConnectAuthPlatformRequest) error
 	UnlinkAuthPlatform(context.Context, int64, string) error
-	GenerateAccessToken(context.Context) (*dto.LoginResponse, error)
+	GenerateAccessToken(context.Context, dto.ClientInfo) (*dto.LoginResponse, error)
+	ListSessions(context.Context, int64) ([]*dto.SessionResponse, error)
+	RevokeSession(context.Context, int64, string) error
+	VerifyEmail(context.Context, *dto.VerifyEmailRequest) error
+	ResendVerificationEmail(context.Context, int64) error
+	RequestPasswordReset(context.Context, *dto.PasswordResetRequest) error
+	ConfirmPasswordReset(context.Context, *dto.ConfirmPasswordResetRequest) error
+	Logout(context.Context) error
+	LogoutAllSessions(context.Context, int64) error
+	RevokeAccessToken(context.Context) error
+	RevokeAccessTokens(context.Context, int64, time.Time) error
+	EnrollTotp(context.Context, int64) (*dto.TotpEnrollResponse, error)
+	ConfirmTotp(context.Context, int64, *dto.TotpCodeRequest) (*dto.RecoveryCodesResponse, error)
+	RegenerateRecoveryCodes(context.Context, int64, *dto.TotpCodeRequest) (*dto.RecoveryCodesResponse, error)
+	DisableTotp(context.Context, int64, *dto.TotpCodeRequest) error
+	AdminFindUser(context.Context, string) (int64, error)
+	AdminCreateUser(context.Context, *dto.AdminCreateUserRequest) (*dto.AdminUserResponse, error)
+	AdminDisableUser(context.Context, int64) (*dto.AdminUserResponse, error)
+	AdminSetPassword(context.Context, int64, string) (*dto.AdminUserResponse, error)
+	AdminRevokeSessions(context.Context, int64) (*dto.AdminUserResponse, error)
+	AdminRevokeAccessToken(context.Context, string) (*dto.AdminTokenResponse, error)
+	AdminRevokeAccessTokens(context.Context, int64, time.Time) (*dto.AdminUserResponse, error)
 }
 
 type userService struct {
 	tokenMaker    token.Maker
+	revocations   revocation.Store
 	pool          *pgxpool.Pool
+	mailer        mailer.Mailer
+	config        utils.Config
 	authPlatforms []platformService.AuthPlatform
 }
 
-func NewUserService(pool *pgxpool.Pool, tokenMaker token.Maker, authPlatforms []platformService.AuthPlatform) UserService {
+func NewUserService(pool *pgxpool.Pool, tokenMaker token.Maker, revocations revocation.Store, mailer mailer.Mailer, config utils.Config, authPlatforms []platformService.AuthPlatform) UserService {
 	service := &userService{
 		pool:          pool,
 		tokenMaker:    tokenMaker,
+		revocations:   revocations,
+		mailer:        mailer,
+		config:        config,
 		authPlatforms: authPlatforms,
 	}
 
@@ -58,23 +90,35 @@ func (s *userService) CreateUser(ctx context.Context, request *dto.CreateUserReq
 
 	for _, provider := range s.authPlatforms {
 		if provider.AuthKey() == request.Provider {
-			dbUser, err := provider.GenerateDbUser(ctx, request.Payload)
+			dbUser, identity, err := provider.GenerateDbUser(ctx, request.Payload)
 			if err != nil {
 				return err
 			}
-			return s.CreateDbUser(ctx, dbUser, provider.AuthKey())
+
+			userID, err := s.CreateDbUser(ctx, dbUser, identity, provider.AuthKey())
+			if err != nil {
+				return err
+			}
+
+			if !dbUser.EmailVerified {
+				// the user is already created, they can ask for a new mail if this one fails
+				if err = s.sendVerificationEmail(ctx, userID, dbUser.Email); err != nil {
+					slog.ErrorContext(ctx, "could not send verification email", slog.Int64("userID", userID), slog.Any("error", err))
+				}
+			}
+			return nil
 		}
 	}
-	return dto.NewErrorWithStatus(http.StatusBadRequest, "invalid provider")
+	return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidProvider, "invalid provider")
 }
 
-func (s *userService) CreateDbUser(ctx context.Context, request *db.CreateUserParams, authProvider string) error {
+func (s *userService) CreateDbUser(ctx context.Context, request *db.CreateUserParams, identity *platformService.Identity, authProvider string) (int64, error) {
 	userCreateParams := db.CreateUserParams{
 		Name:          request.Name,
 		Email:         request.Email,
 		Password:      request.Password,
 		Picture:       request.Picture,
-		AuthProviders: []string{authProvider},
+		EmailVerified: request.EmailVerified,
 		TokenHash:     utils.GenerateRandomString(15),
 		CreatedAt:     pgtype.Timestamptz{Time: time.Now(), Valid: true},
 		UpdatedAt:     pgtype.Timestamptz{Time: time.Now(), Valid: true},
@@ -82,32 +126,52 @@ func (s *userService) CreateDbUser(ctx context.Context, request *db.CreateUserPa
 	conn, err := s.pool.Acquire(ctx)
 	if err != nil {
 		slog.Error("could not establish db connection")
-		return err
+		return 0, err
 	}
 
 	defer conn.Release()
-	repo := db.New(conn)
-	err = repo.CreateUser(ctx, userCreateParams)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not start transaction", slog.Any("error", err))
+		return 0, dto.NewError("could not create user")
+	}
+	defer tx.Rollback(ctx)
+
+	repo := db.New(conn).WithTx(tx)
+	userID, err := repo.CreateUser(ctx, userCreateParams)
+	if err == nil {
+		err = s.createUserIdentity(ctx, repo, userID, authProvider, identity)
+	}
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			if pgErr.Code == "23505" && pgErr.ConstraintName == "unique_email" { // TODO: postgres specific code
 				slog.ErrorContext(ctx, "email is not unique", slog.String("email", request.Email))
-				return dto.NewErrorWithStatus(http.StatusBadRequest, "email already exists")
+				return 0, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeEmailTaken, "email already exists")
+			}
+			if pgErr.Code == "23505" && pgErr.ConstraintName == "unique_user_identity" {
+				slog.ErrorContext(ctx, "identity is already linked", slog.String("provider", authProvider))
+				return 0, dto.NewErrorWithParams(http.StatusBadRequest, dto.ErrCodeProviderLinkedToOther, fmt.Sprintf("%s account is already linked to a user", authProvider), map[string]string{"provider": authProvider})
 			}
 		}
 		slog.ErrorContext(ctx, "could not create user", slog.String("email", request.Email), slog.Any("error", err))
-		return dto.NewError("could not create user")
+		return 0, dto.NewError("could not create user")
 	}
 
-	return nil
+	if err = tx.Commit(ctx); err != nil {
+		slog.ErrorContext(ctx, "could not commit transaction", slog.Any("error", err))
+		return 0, dto.NewError("could not create user")
+	}
+
+	return userID, nil
 }
 
 func (s *userService) ConnectAuthPlatform(ctx context.Context, userID int64, request *dto.ConnectAuthPlatformRequest) error {
 	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
 
 	if currentUser.UserID != userID {
-		return dto.NewErrorWithStatus(http.StatusForbidden, "user not found")
+		return dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeUserNotFound, "user not found")
 	}
 
 	slog.InfoContext(ctx, "adding auth platform to user",
@@ -121,14 +185,14 @@ func (s *userService) ConnectAuthPlatform(ctx context.Context, userID int64, req
 		}
 	}
 
-	return dto.NewErrorWithStatus(http.StatusBadRequest, fmt.Sprintf("provider %s is invalid", request.Provider))
+	return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidProvider, fmt.Sprintf("provider %s is invalid", request.Provider))
 }
 
 func (s *userService) UnlinkAuthPlatform(ctx context.Context, userID int64, provider string) error {
 	currentUser := ctx.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
 
 	if currentUser.UserID != userID {
-		return dto.NewErrorWithStatus(http.StatusForbidden, "user not found")
+		return dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeUserNotFound, "user not found")
 	}
 
 	conn, err := s.pool.Acquire(ctx)
@@ -151,12 +215,12 @@ func (s *userService) UnlinkAuthPlatform(ctx context.Context, userID int64, prov
 	}
 
 	if len(user.AuthProviders) == 1 {
-		return dto.NewErrorWithStatus(http.StatusBadRequest, "cannot unlink the last auth provider")
+		return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeLastProvider, "cannot unlink the last auth provider")
 	}
 
-	err = repo.UnlinkAuthPlatform(ctx, db.UnlinkAuthPlatformParams{
-		ID:          userID,
-		ArrayRemove: provider,
+	_, err = repo.DeleteUserIdentity(ctx, db.DeleteUserIdentityParams{
+		UserID:   userID,
+		Provider: provider,
 	})
 	if err != nil {
 		slog.ErrorContext(ctx, "could not unlink auth platform", slog.Any("error", err))
@@ -167,10 +231,14 @@ func (s *userService) UnlinkAuthPlatform(ctx context.Context, userID int64, prov
 }
 
 func (s *userService) validateAndConnectAuthPlatform(ctx context.Context, userID int64, provider platformService.AuthPlatform, payload string) error {
-	email, err := provider.LinkGetEmail(ctx, payload)
+	identity, err := provider.LinkGetIdentity(ctx, payload)
 	if err != nil {
-		slog.ErrorContext(ctx, "could not get email", slog.Any("error", err))
-		return dto.NewError("could not get email")
+		slog.ErrorContext(ctx, "could not get identity", slog.Any("error", err))
+		var dtoErr *dto.Error
+		if errors.As(err, &dtoErr) && dtoErr.Code < http.StatusInternalServerError {
+			return err
+		}
+		return dto.NewError("could not get identity")
 	}
 
 	conn, err := s.pool.Acquire(ctx)
@@ -180,24 +248,50 @@ func (s *userService) validateAndConnectAuthPlatform(ctx context.Context, userID
 	}
 	defer conn.Release()
 
+	repo := db.New(conn)
+	linkedIdentity, err := repo.GetUserIdentity(ctx, db.GetUserIdentityParams{
+		Provider: provider.AuthKey(),
+		Subject:  identity.Subject,
+	})
+	if err != nil && err != pgx.ErrNoRows {
+		slog.ErrorContext(ctx, "could not get user identity", slog.Any("error", err))
+		return dto.NewError("could not connect auth platform")
+	}
+
+	alreadyLinked := err == nil
+	if alreadyLinked && linkedIdentity.UserID != userID {
+		slog.ErrorContext(ctx, "identity is linked to another user", slog.String("provider", provider.AuthKey()), slog.Int64("userID", userID))
+		return dto.NewErrorWithParams(http.StatusConflict, dto.ErrCodeProviderLinkedToOther, fmt.Sprintf("%s account is already linked to another user", provider.AuthKey()), map[string]string{"provider": provider.AuthKey()})
+	}
+
 	err = provider.LinkExtraInformation(ctx, userID, payload)
 	if err != nil {
 		slog.ErrorContext(ctx, "could not link extra information", slog.Any("error", err))
 		return err
 	}
 
-	repo := db.New(conn)
-	connectedAccount, err := repo.ConnectAuthPlatform(ctx, db.ConnectAuthPlatformParams{
-		ID:          userID,
-		Email:       email,
-		ArrayAppend: provider.AuthKey(),
-	})
-	slog.InfoContext(ctx, "connected account", slog.Any("connectedAccount", connectedAccount))
-	if err != nil && !utils.SliceContains(connectedAccount.AuthProviders, provider.AuthKey()) {
+	// the identity is already linked, e.g. when adding another passkey
+	if alreadyLinked {
+		return nil
+	}
+
+	err = s.createUserIdentity(ctx, repo, userID, provider.AuthKey(), identity)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			if pgErr.ConstraintName == "unique_user_identity_provider" {
+				return dto.NewErrorWithParams(http.StatusBadRequest, dto.ErrCodeProviderAlreadyLinked, fmt.Sprintf("another %s account is linked, unlink it first", provider.AuthKey()), map[string]string{"provider": provider.AuthKey()})
+			}
+			if pgErr.ConstraintName == "unique_user_identity" {
+				return dto.NewErrorWithParams(http.StatusConflict, dto.ErrCodeProviderLinkedToOther, fmt.Sprintf("%s account is already linked to another user", provider.AuthKey()), map[string]string{"provider": provider.AuthKey()})
+			}
+		}
+
 		slog.ErrorContext(ctx, "could not connect auth platform", slog.Any("error", err))
 		return dto.NewError("could not connect auth platform")
 	}
 
+	slog.InfoContext(ctx, "connected auth platform", slog.String("provider", provider.AuthKey()), slog.Int64("userID", userID))
 	return nil
 }
 
@@ -207,7 +301,7 @@ func (s *userService) GetUser(ctx context.Context, userID int64) (*dto.GetUserRe
 	slog.InfoContext(ctx, "get the user details", slog.Int64("loggedInUserID", currentUser.UserID), slog.Int64("searchedUserID", userID))
 
 	if userID != currentUser.UserID {
-		return nil, dto.NewErrorWithStatus(http.StatusNotFound, "user not found")
+		return nil, dto.NewErrorWithCode(http.StatusNotFound, dto.ErrCodeUserNotFound, "user not found")
 	}
 
 	conn, err := s.pool.Acquire(ctx)
@@ -222,7 +316,7 @@ func (s *userService) GetUser(ctx context.Context, userID int64) (*dto.GetUserRe
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			slog.ErrorContext(ctx, "user not found in db", slog.Int64("searchedUserID", userID))
-			return nil, dto.NewErrorWithStatus(http.StatusNotFound, "user not found")
+			return nil, dto.NewErrorWithCode(http.StatusNotFound, dto.ErrCodeUserNotFound, "user not found")
 		}
 
 		slog.ErrorContext(ctx, "could not get user", slog.Any("error", err))
@@ -233,81 +327,171 @@ func (s *userService) GetUser(ctx context.Context, userID int64) (*dto.GetUserRe
 	return dto.GetUserResponseFromDB(&user), nil
 }
 
-func (s *userService) Login(ctx context.Context, request *dto.LoginRequest) (*dto.LoginResponse, error) {
+func (s *userService) Login(ctx context.Context, request *dto.LoginRequest) (*dto.LoginResponse, *dto.LoginChallengeResponse, error) {
 	slog.InfoContext(ctx, "logging in user",
 		slog.String("provider", request.Provider),
 	)
 
 	for _, provider := range s.authPlatforms {
 		if provider.AuthKey() == request.Provider {
-			return s.LoginWithProvider(ctx, provider, request.Payload)
+			response, challenge, err := s.LoginWithProvider(ctx, provider, request)
+			metrics.ObserveLogin(provider.AuthKey(), loginResult(challenge, err))
+			return response, challenge, err
 		}
 	}
 
-	return nil, dto.NewErrorWithStatus(http.StatusBadRequest, "invalid provider")
+	return nil, nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidProvider, "invalid provider")
 }
 
-func (s *userService) LoginWithProvider(ctx context.Context, provider platformService.AuthPlatform, payload string) (*dto.LoginResponse, error) {
-	email, err := provider.LoginGetEmail(ctx, payload)
+func loginResult(challenge *dto.LoginChallengeResponse, err error) string {
 	if err != nil {
-		slog.ErrorContext(ctx, "could not get email", slog.Any("error", err))
-		return nil, dto.NewError("could not get email")
+		return metrics.LoginFailure
+	}
+	if challenge != nil {
+		return metrics.LoginChallenge
+	}
+	return metrics.LoginSuccess
+}
+
+func (s *userService) LoginWithProvider(ctx context.Context, provider platformService.AuthPlatform, request *dto.LoginRequest) (*dto.LoginResponse, *dto.LoginChallengeResponse, error) {
+	payload := request.Payload
+	identity, err := provider.LoginGetIdentity(ctx, payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get identity", slog.Any("error", err))
+		var dtoErr *dto.Error
+		if errors.As(err, &dtoErr) && dtoErr.Code < http.StatusInternalServerError {
+			return nil, nil, err
+		}
+		return nil, nil, dto.NewError("could not get identity")
 	}
-	slog.Info("got email for logging in user", slog.String("email", email))
+	slog.Info("got identity for logging in user", slog.String("provider", provider.AuthKey()), slog.String("email", identity.Email))
 
 	conn, err := s.pool.Acquire(ctx)
 	if err != nil {
 		slog.Error("could not establish db connection")
-		return nil, err
+		return nil, nil, err
 	}
 
 	defer conn.Release()
 	repo := db.New(conn)
-	user, err := repo.GetUserSecrets(ctx, email)
+	userIdentity, err := s.findUserIdentity(ctx, repo, provider.AuthKey(), identity)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			slog.ErrorContext(ctx, "email not in found", slog.String("email", email))
-			return nil, dto.NewErrorWithStatus(http.StatusForbidden, "invalid credendials")
+			if signUp, ok := provider.(platformService.LoginSignUp); ok && signUp.LoginSignUpEnabled() {
+				response, err := s.signUpOnLogin(ctx, repo, provider, signUp, identity, request)
+				return response, nil, err
+			}
+
+			slog.ErrorContext(ctx, "identity not found", slog.String("provider", provider.AuthKey()), slog.String("email", identity.Email))
+			return nil, nil, accountNotFoundError(provider)
 		}
-		slog.ErrorContext(ctx, "could not get user secrets", slog.Any("error", err))
-		return nil, dto.NewError("could not get user")
+		slog.ErrorContext(ctx, "could not get user identity", slog.Any("error", err))
+		return nil, nil, dto.NewError("could not get user")
 	}
 
-	if !utils.SliceContains(user.AuthProviders, provider.AuthKey()) {
-		slog.ErrorContext(ctx, "user not found", slog.String("email", email))
-		return nil, dto.NewErrorWithStatus(http.StatusForbidden, fmt.Sprintf("account not found for %s, login to account then link %s", provider.AuthKey(), provider.AuthKey()))
+	user, err := repo.GetUserSecrets(ctx, userIdentity.UserID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			slog.ErrorContext(ctx, "user not found", slog.Int64("userID", userIdentity.UserID))
+			return nil, nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeInvalidCredentials, "invalid credentials")
+		}
+		slog.ErrorContext(ctx, "could not get user secrets", slog.Any("error", err))
+		return nil, nil, dto.NewError("could not get user")
 	}
 
 	if err = provider.LoginExtraVerify(ctx, payload, user); err != nil {
 		slog.ErrorContext(ctx, "could not verify user", slog.Any("error", err))
-		return nil, dto.NewErrorWithStatus(http.StatusForbidden, "invalid credendials")
+		return nil, nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeInvalidCredentials, "invalid credentials")
+	}
+
+	if user.DisabledAt.Valid {
+		slog.InfoContext(ctx, "login of disabled user", slog.Int64("userID", user.ID))
+		return nil, nil, dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeAccountDisabled, "account is disabled")
+	}
+
+	if len(identity.Profile) > 0 {
+		err = repo.UpdateUserIdentityProfile(ctx, db.UpdateUserIdentityProfileParams{
+			ID:        userIdentity.ID,
+			Profile:   identityProfile(ctx, identity),
+			UpdatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "could not update identity profile", slog.Any("error", err))
+		}
 	}
 
-	slog.InfoContext(ctx, "generating tokens for user", slog.String("email", email), slog.Int64("userID", user.ID))
-	return s.generateTokens(ctx, user.ID, user.TokenHash)
+	if user.TotpEnabled {
+		challenge, err := s.createLoginChallenge(ctx, user.ID, user.Email)
+		return nil, challenge, err
+	}
+
+	slog.InfoContext(ctx, "generating tokens for user", slog.String("email", user.Email), slog.Int64("userID", user.ID))
+	response, err := s.createSession(ctx, user.ID, user.TokenHash, request.DeviceName, request.Client)
+	return response, nil, err
+}
+
+// signUpOnLogin creates the account of an identity that is not linked yet and
+// starts its first session. An account with the email has to link the
+// provider first, otherwise anyone with the email could take it over
+func (s *userService) signUpOnLogin(ctx context.Context, repo *db.Queries, provider platformService.AuthPlatform, signUp platformService.LoginSignUp, identity *platformService.Identity, request *dto.LoginRequest) (*dto.LoginResponse, error) {
+	_, err := repo.GetUserIDByEmail(ctx, identity.Email)
+	if err == nil {
+		slog.InfoContext(ctx, "identity not found for existing email", slog.String("provider", provider.AuthKey()))
+		return nil, accountNotFoundError(provider)
+	}
+	if err != pgx.ErrNoRows {
+		slog.ErrorContext(ctx, "could not get user", slog.Any("error", err))
+		return nil, dto.NewError("could not get user")
+	}
+
+	dbUser, err := signUp.GenerateLoginDbUser(ctx, request.Payload, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := s.CreateDbUser(ctx, dbUser, identity, provider.AuthKey())
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := repo.GetUserSecrets(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get user secrets", slog.Any("error", err))
+		return nil, dto.NewError("could not get user")
+	}
+
+	slog.InfoContext(ctx, "user signed up on first login", slog.String("provider", provider.AuthKey()), slog.Int64("userID", userID))
+	return s.createSession(ctx, user.ID, user.TokenHash, request.DeviceName, request.Client)
+}
+
+func accountNotFoundError(provider platformService.AuthPlatform) error {
+	return dto.NewErrorWithParams(http.StatusForbidden, dto.ErrCodeAccountNotFound, fmt.Sprintf("account not found for %s, login to account then link %s", provider.AuthKey(), provider.AuthKey()), map[string]string{"provider": provider.AuthKey()})
 }
 
 func (s *userService) AuthKey() string {
 	return "normal"
 }
 
-func (s *userService) LoginGetEmail(c context.Context, payload string) (string, error) {
+// LoginGetIdentity uses the email as subject, the password belongs to the
+// account so its identity follows the account email
+func (s *userService) LoginGetIdentity(c context.Context, payload string) (*platformService.Identity, error) {
 	parts := strings.SplitN(payload, "|", 2)
 	if len(parts) != 2 {
-		slog.Error("payload is not valid", slog.String("payload", payload))
-		return "", dto.NewErrorWithStatus(http.StatusBadRequest, "invalid payload")
+		// the payload is "email|password", so it is not logged
+		slog.ErrorContext(c, "payload is not valid")
+		return nil, dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeInvalidPayload, "invalid payload")
 	}
 
-	return parts[0], nil
+	return &platformService.Identity{Subject: parts[0], Email: parts[0]}, nil
 }
 
-func (s *userService) LinkGetEmail(c context.Context, payload string) (string, error) {
+func (s *userService) LinkGetIdentity(c context.Context, payload string) (*platformService.Identity, error) {
 	currentUser := c.Value(middleware.AuthenticationPayloadKey).(*token.Payload)
 
 	conn, err := s.pool.Acquire(c)
 	if err != nil {
 		slog.Error("could not establish db connection")
-		return "", err
+		return nil, err
 	}
 	defer conn.Release()
 
@@ -315,10 +499,10 @@ func (s *userService) LinkGetEmail(c context.Context, payload string) (string, e
 	user, err := repo.GetUser(c, currentUser.UserID)
 	if err != nil {
 		slog.ErrorContext(c, "error while getting user", slog.Any("error", err))
-		return "", dto.NewError("could not get user details to generate token")
+		return nil, dto.NewError("could not get user details to generate token")
 	}
 
-	return user.Email, nil
+	return &platformService.Identity{Subject: user.Email, Email: user.Email}, nil
 }
 
 func (s *userService) LoginExtraVerify(ctx context.Context, payload string, user db.GetUserSecretsRow) error {
@@ -326,7 +510,7 @@ func (s *userService) LoginExtraVerify(ctx context.Context, payload string, user
 	err := utils.CheckPassword(parts[1], user.Password)
 	if err != nil {
 		slog.ErrorContext(ctx, "password is incorrect", slog.String("email", parts[0]), slog.Any("error", err))
-		return dto.NewErrorWithStatus(http.StatusForbidden, "invalid credendials")
+		return dto.NewErrorWithCode(http.StatusForbidden, dto.ErrCodeInvalidCredentials, "invalid credentials")
 	}
 
 	return nil
@@ -334,7 +518,7 @@ func (s *userService) LoginExtraVerify(ctx context.Context, payload string, user
 
 func (s *userService) LinkExtraInformation(ctx context.Context, userID int64, payload string) error {
 	if len(payload) < 8 {
-		return dto.NewErrorWithStatus(http.StatusBadRequest, "password should be at least 8 characters long")
+		return dto.NewErrorWithCode(http.StatusBadRequest, dto.ErrCodeWeakPassword, "password should be at least 8 characters long")
 	}
 
 	hashedPassword, err := utils.HashPassword(payload)
@@ -364,17 +548,17 @@ func (s *userService) LinkExtraInformation(ctx context.Context, userID int64, pa
 	return nil
 }
 
-func (s *userService) GenerateDbUser(ctx context.Context, p any) (*db.CreateUserParams, error) {
+func (s *userService) GenerateDbUser(ctx context.Context, p any) (*db.CreateUserParams, *platformService.Identity, error) {
 	var payload dto.CreateUserPayloadNormal
 	err := apiUtils.AssignAndValidateCreateUserPayload(ctx, p, &payload)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	hashedPassword, err := utils.HashPassword(payload.Password)
 	if err != nil {
 		slog.ErrorContext(ctx, "could not hash password", slog.String("email", payload.Email))
-		return nil, dto.NewError("could not hash password")
+		return nil, nil, dto.NewError("could not hash password")
 	}
 
 	return &db.CreateUserParams{
@@ -383,54 +567,5 @@ func (s *userService) GenerateDbUser(ctx context.Context, p any) (*db.CreateUser
 		Password:  string(hashedPassword),
 		Picture:   payload.Picture,
 		TokenHash: utils.GenerateRandomString(15),
-	}, nil
-}
-
-func (s *userService) GenerateAccessToken(c context.Context) (*dto.LoginResponse, error) {
-	refreshPayload := c.Value(middleware.RefreshTokenPayloadKey).(*token.RefreshPayload)
-	ctx := utils.AppendCtx(c, slog.Int64("user_id", refreshPayload.UserID))
-
-	slog.InfoContext(ctx, "generating access token from refresh token")
-
-	conn, err := s.pool.Acquire(ctx)
-	if err != nil {
-		slog.Error("could not establish db connection")
-		return nil, err
-	}
-
-	defer conn.Release()
-	repo := db.New(conn)
-	tokenHash, err := repo.GetUserTokenHash(ctx, refreshPayload.UserID)
-	if err != nil {
-		slog.ErrorContext(ctx, "error while getting user", slog.Any("error", err))
-		return nil, dto.NewError("could not get user details to generate token")
-	}
-
-	// check token hash
-	err = s.tokenMaker.ValidateRefreshHash(refreshPayload.Hash, refreshPayload.UserID, tokenHash)
-	if err != nil {
-		slog.ErrorContext(ctx, "refresh token hash mismatch", slog.Any("error", err))
-		return nil, dto.NewErrorWithStatus(http.StatusForbidden, err.Error())
-	}
-
-	return s.generateTokens(ctx, refreshPayload.UserID, tokenHash)
-}
-
-func (s *userService) generateTokens(ctx context.Context, userID int64, tokenHash string) (*dto.LoginResponse, error) {
-	var response dto.LoginResponse
-	var err error
-
-	response.AccessToken, err = s.tokenMaker.CreateAccessToken(userID)
-	if err != nil {
-		slog.ErrorContext(ctx, "could not access create token", slog.Any("error", err))
-		return nil, dto.NewError("could not access create token")
-	}
-
-	response.RefreshToken, err = s.tokenMaker.CreateRefreshToken(userID, tokenHash)
-	if err != nil {
-		slog.ErrorContext(ctx, "could not refresh create token", slog.Any("error", err))
-		return nil, dto.NewError("could not refresh create token")
-	}
-
-	return &response, nil
+	}, &platformService.Identity{Subject: payload.Email, Email: payload.Email}, nil
 }
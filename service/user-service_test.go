@@ -0,0 +1,61 @@
+package service
+
+import (
+	"backend/dto"
+	"backend/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestCreateUserDoesNotLogSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.Default()
+	slog.SetDefault(slog.New(utils.NewContextHandler(slog.NewJSONHandler(&buf, nil), nil)))
+	t.Cleanup(func() { slog.SetDefault(logger) })
+
+	// the invalid email fails before the database is needed
+	s := NewUserService(nil, nil, nil, nil, utils.Config{}, nil)
+	err := s.CreateUser(context.Background(), &dto.CreateUserRequest{
+		Provider: "normal",
+		Payload: map[string]any{
+			"name":     "Test User",
+			"email":    "not-an-email",
+			"password": "payload-password",
+			"device":   map[string]any{"refreshToken": "payload-refresh-token"},
+		},
+	})
+	assertErrorCode(t, err, dto.ErrCodeValidationFailed)
+
+	output := buf.String()
+	for _, value := range []string{"payload-password", "payload-refresh-token"} {
+		if strings.Contains(output, value) {
+			t.Errorf("%s was logged: %s", value, output)
+		}
+	}
+
+	var record struct {
+		Msg     string `json:"msg"`
+		Payload struct {
+			Email    string            `json:"email"`
+			Password string            `json:"password"`
+			Device   map[string]string `json:"device"`
+		} `json:"payload"`
+	}
+	line, _, _ := strings.Cut(output, "\n")
+	if err = json.Unmarshal([]byte(line), &record); err != nil {
+		t.Fatalf("could not decode log record %q: %v", line, err)
+	}
+	if record.Msg != "creating user" {
+		t.Fatalf("first record is %q, want the payload log of CreateUser", record.Msg)
+	}
+	if record.Payload.Password != "[REDACTED]" || record.Payload.Device["refreshToken"] != "[REDACTED]" {
+		t.Errorf("payload = %+v, want the password and refresh token redacted", record.Payload)
+	}
+	if record.Payload.Email != "not-an-email" {
+		t.Errorf("payload email = %q, want it kept", record.Payload.Email)
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"backend/db"
+	"backend/dto"
+	"backend/utils"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	defaultUserTokenResendInterval = time.Minute
+	defaultUserTokenMaxSendsPerDay = 5
+	errUserTokenSendLimitReached   = "too many emails sent, try again tomorrow"
+	errUserTokenSentRecently       = "email was sent recently, try again later"
+)
+
+// createUserToken stores the hash of a new one time token and returns the token
+func (s *userService) createUserToken(ctx context.Context, userID int64, email string, purpose string, duration time.Duration) (string, error) {
+	userToken, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not generate token", slog.String("purpose", purpose), slog.Any("error", err))
+		return "", dto.NewError("could not generate token")
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return "", err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	now := time.Now()
+	err = repo.CreateUserToken(ctx, db.CreateUserTokenParams{
+		UserID:    userID,
+		Email:     email,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(userToken),
+		ExpiresAt: pgtype.Timestamptz{Time: now.Add(duration), Valid: true},
+		CreatedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not store token", slog.String("purpose", purpose), slog.Any("error", err))
+		return "", dto.NewError("could not generate token")
+	}
+
+	return userToken, nil
+}
+
+// checkUserTokenSendLimit makes sure a user does not get flooded with mails,
+// it also revokes the tokens sent earlier so only the newest one stays usable
+func (s *userService) checkUserTokenSendLimit(ctx context.Context, userID int64, purpose string, config utils.UserTokenConfig) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		slog.Error("could not establish db connection")
+		return err
+	}
+
+	defer conn.Release()
+	repo := db.New(conn)
+
+	stats, err := repo.GetUserTokenSendStats(ctx, db.GetUserTokenSendStatsParams{
+		UserID:    userID,
+		Purpose:   purpose,
+		CreatedAt: pgtype.Timestamptz{Time: time.Now().Add(-24 * time.Hour), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not get sent token stats", slog.String("purpose", purpose), slog.Any("error", err))
+		return dto.NewError("could not send email")
+	}
+
+	resendInterval := config.RESEND_INTERVAL * time.Second
+	if resendInterval == 0 {
+		resendInterval = defaultUserTokenResendInterval
+	}
+
+	maxSends := config.MAX_SENDS_PER_DAY
+	if maxSends == 0 {
+		maxSends = defaultUserTokenMaxSendsPerDay
+	}
+
+	if stats.SentCount >= maxSends {
+		slog.InfoContext(ctx, "daily token mail limit reached", slog.Int64("userID", userID), slog.String("purpose", purpose))
+		return dto.NewErrorWithCode(http.StatusTooManyRequests, dto.ErrCodeEmailRateLimited, errUserTokenSendLimitReached)
+	}
+	if stats.LastSentAt.Valid && time.Since(stats.LastSentAt.Time) < resendInterval {
+		return dto.NewErrorWithCode(http.StatusTooManyRequests, dto.ErrCodeEmailRateLimited, errUserTokenSentRecently)
+	}
+
+	err = repo.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+		UserID:  userID,
+		Purpose: purpose,
+		UsedAt:  pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "could not revoke old tokens", slog.String("purpose", purpose), slog.Any("error", err))
+		return dto.NewError("could not send email")
+	}
+
+	return nil
+}
+
+func userTokenDuration(config utils.UserTokenConfig, defaultDuration time.Duration) time.Duration {
+	if config.TOKEN_DURATION == 0 {
+		return defaultDuration
+	}
+	return config.TOKEN_DURATION * time.Second
+}
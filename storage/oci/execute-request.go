@@ -67,9 +67,9 @@ func (o *OCIStorage) executeRequest(method string, endpointPath string, data []b
 		err = fmt.Errorf("request failed with status code: %d", resp.StatusCode)
 		slog.Error("request failed", slog.Any("error", err))
 	}
-	body, err = io.ReadAll(resp.Body)
-	if err != nil {
-		return
+	body, readErr := io.ReadAll(resp.Body)
+	if err == nil {
+		err = readErr
 	}
 
 	if resp.StatusCode != http.StatusOK {
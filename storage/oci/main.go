@@ -3,6 +3,7 @@ package oci
 import (
 	"backend/storage"
 	"backend/utils"
+	"context"
 	"crypto/rsa"
 	"encoding/json"
 	"fmt"
@@ -35,6 +36,18 @@ func NewOciStorage(ociConfig utils.OciStorageConfig) (storage.Storage, error) {
 	}, nil
 }
 
+func (o *OCIStorage) Name() string {
+	return "object_storage"
+}
+
+// Check reads the bucket metadata, it fails when the bucket cannot be reached
+// with the configured key
+func (o *OCIStorage) Check(ctx context.Context) error {
+	endpointPath := "/n/" + o.namespace + "/b/" + o.bucketName
+	_, err := o.executeRequest(http.MethodHead, endpointPath, nil)
+	return err
+}
+
 func (o *OCIStorage) UploadObject(data []byte, objectPath string) (string, error) {
 	endpointPath := "/n/" + o.namespace + "/b/" + o.bucketName + "/o/" + objectPath + "?compartmentId=" + o.compartmentID
 	_, err := o.executeRequest(http.MethodPut, endpointPath, data)
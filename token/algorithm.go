@@ -0,0 +1,204 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// The signing algorithms a keyring can use, the names are the jwt alg header
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmHS256 = "HS256"
+)
+
+// The paseto purposes a keyring can use, v4.public signs with Ed25519 keys and
+// v4.local encrypts with a 32 byte key
+const (
+	AlgorithmV4Public = "v4.public"
+	AlgorithmV4Local  = "v4.local"
+)
+
+// v4LocalKeyLen is the key size of v4.local
+const v4LocalKeyLen = 32
+
+// minHMACSecretLen is the size of the sha256 output, shorter secrets weaken
+// the signature
+const minHMACSecretLen = 32
+
+func init() {
+	jwt.RegisterSigningMethod(AlgorithmEdDSA, func() jwt.SigningMethod {
+		return signingMethodEdDSA{}
+	})
+}
+
+// signingMethodEdDSA signs with Ed25519 keys, jwt-go only ships rsa, ecdsa
+// and hmac
+type signingMethodEdDSA struct{}
+
+func (signingMethodEdDSA) Alg() string {
+	return AlgorithmEdDSA
+}
+
+func (signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(private, []byte(signingString))), nil
+}
+
+func (signingMethodEdDSA) Verify(signingString string, signature string, key interface{}) error {
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(public, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+// signingMethod returns the jwt signing method of the algorithm
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgorithmES256:
+		return jwt.SigningMethodES256, nil
+	case AlgorithmEdDSA:
+		return signingMethodEdDSA{}, nil
+	case AlgorithmHS256:
+		return jwt.SigningMethodHS256, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+}
+
+// checkPublicKey makes sure the key fits the algorithm, so a key of the wrong
+// type fails at startup instead of on the first token
+func checkPublicKey(algorithm string, public crypto.PublicKey) error {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if algorithm == AlgorithmRS256 {
+			if key.N.BitLen() < 2048 {
+				return errors.New("rsa keys must have at least 2048 bits")
+			}
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if algorithm == AlgorithmES256 {
+			if key.Curve != elliptic.P256() {
+				return errors.New("ES256 keys must use the P-256 curve")
+			}
+			return nil
+		}
+	case ed25519.PublicKey:
+		if algorithm == AlgorithmEdDSA || algorithm == AlgorithmV4Public {
+			return nil
+		}
+	case []byte:
+		if algorithm == AlgorithmHS256 {
+			if len(key) < minHMACSecretLen {
+				return fmt.Errorf("HS256 secrets must have at least %d bytes", minHMACSecretLen)
+			}
+			return nil
+		}
+		if algorithm == AlgorithmV4Local {
+			if len(key) != v4LocalKeyLen {
+				return fmt.Errorf("v4.local keys must have %d bytes", v4LocalKeyLen)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("key of type %T cannot be used with %s", public, algorithm)
+}
+
+// publicKeyOf returns the public key of a private key, HS256 secrets are their
+// own public key
+func publicKeyOf(private crypto.PrivateKey) (crypto.PublicKey, error) {
+	switch key := private.(type) {
+	case []byte:
+		return key, nil
+	case crypto.Signer:
+		return key.Public(), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key of type %T", private)
+	}
+}
+
+// equalPublicKeys compares public keys of any supported type
+func equalPublicKeys(a crypto.PublicKey, b crypto.PublicKey) bool {
+	if secret, ok := a.([]byte); ok {
+		other, ok := b.([]byte)
+		return ok && string(secret) == string(other)
+	}
+
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
+
+// GetPrivateKey parses a pem private key, rsa keys can be in the PKCS #1
+// format, ecdsa keys in the SEC 1 format and every key in the PKCS #8 format
+func GetPrivateKey(value string) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, errors.New("could not parse private key file")
+	}
+
+	var private crypto.PrivateKey
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("could not parse private key file: unsupported pem block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key file: %w", err)
+	}
+	return private, nil
+}
+
+// GetPublicKey parses a pem public key in the PKIX format, rsa keys can also
+// be in the PKCS #1 format
+func GetPublicKey(value string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, errors.New("could not parse public key file")
+	}
+
+	var public crypto.PublicKey
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("could not parse public key file: unsupported pem block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key file: %w", err)
+	}
+	return public, nil
+}
@@ -0,0 +1,132 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// forgeAccessToken signs a valid access payload with the method and key, the
+// kid header names the key of the test keyring
+func forgeAccessToken(t *testing.T, method jwt.SigningMethod, key interface{}) string {
+	t.Helper()
+
+	payload, err := NewPayload(42, AccessClaims{}, tokenTypeAccess, testIssuer, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(method, payload)
+	token.Header["kid"] = "test"
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("could not sign with %s: %v", method.Alg(), err)
+	}
+	return signed
+}
+
+// publicKeyPEM is the public key as a server would publish it, the bytes an
+// attacker uses as hmac secret
+func publicKeyPEM(t *testing.T, public crypto.PublicKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestAlgorithmIsPinned(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		forge     func(t *testing.T, key *Key) string
+	}{
+		{
+			name:      "RS256 token as HS256 with the public key pem as secret",
+			algorithm: AlgorithmRS256,
+			forge: func(t *testing.T, key *Key) string {
+				return forgeAccessToken(t, jwt.SigningMethodHS256, publicKeyPEM(t, key.Public))
+			},
+		},
+		{
+			name:      "RS256 token as RS512 with the same key",
+			algorithm: AlgorithmRS256,
+			forge: func(t *testing.T, key *Key) string {
+				return forgeAccessToken(t, jwt.SigningMethodRS512, key.Private)
+			},
+		},
+		{
+			name:      "RS256 token as PS256 with the same key",
+			algorithm: AlgorithmRS256,
+			forge: func(t *testing.T, key *Key) string {
+				return forgeAccessToken(t, jwt.SigningMethodPS256, key.Private)
+			},
+		},
+		{
+			name:      "RS256 token without signature",
+			algorithm: AlgorithmRS256,
+			forge: func(t *testing.T, key *Key) string {
+				return forgeAccessToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType)
+			},
+		},
+		{
+			name:      "ES256 token as HS256 with the public key pem as secret",
+			algorithm: AlgorithmES256,
+			forge: func(t *testing.T, key *Key) string {
+				return forgeAccessToken(t, jwt.SigningMethodHS256, publicKeyPEM(t, key.Public))
+			},
+		},
+		{
+			name:      "EdDSA token as HS256 with the public key as secret",
+			algorithm: AlgorithmEdDSA,
+			forge: func(t *testing.T, key *Key) string {
+				return forgeAccessToken(t, jwt.SigningMethodHS256, []byte(key.Public.(ed25519.PublicKey)))
+			},
+		},
+		{
+			name:      "HS256 token as RS256 with another key",
+			algorithm: AlgorithmHS256,
+			forge: func(t *testing.T, key *Key) string {
+				return forgeAccessToken(t, jwt.SigningMethodRS256, newTestKey(t, AlgorithmRS256))
+			},
+		},
+		{
+			name:      "HS256 token without signature",
+			algorithm: AlgorithmHS256,
+			forge: func(t *testing.T, key *Key) string {
+				return forgeAccessToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accessKeys := newTestKeyring(t, tt.algorithm)
+			maker, err := NewJWTMaker(testIssuer, accessKeys, newTestKeyring(t, AlgorithmHS256), 60, 60, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// a token of the keyring algorithm and key is accepted, so the
+			// rejection below comes from the alg header
+			method, err := signingMethod(tt.algorithm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = maker.ValidateAccessToken(forgeAccessToken(t, method, accessKeys.SigningKey().Private)); err != nil {
+				t.Fatalf("token of the keyring algorithm = %v, want it valid", err)
+			}
+
+			if _, err = maker.ValidateAccessToken(tt.forge(t, accessKeys.SigningKey())); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("token with another alg = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
@@ -1,106 +1,103 @@
 package token
 
 import (
-	"crypto/md5"
-	"crypto/rsa"
+	"backend/metrics"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"strconv"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 )
 
 const tokenTypeAccess = "access"
 const tokenTypeRefresh = "refresh"
 
-// JWTMaker is a JSON Web Token maker
+// JWTMaker is a JSON Web Token maker, each token type has its own keyring so
+// keys can be rotated without ending the sessions signed with the old key
 type JWTMaker struct {
 	issuer          string
-	accessSecret    *rsa.PrivateKey
-	accessPublic    *rsa.PublicKey
-	refreshSecret   *rsa.PrivateKey
-	refreshPublic   *rsa.PublicKey
+	accessKeys      *Keyring
+	refreshKeys     *Keyring
 	accessDuration  time.Duration
 	refreshDuration time.Duration
+	options         Options
 }
 
-func NewJWTMaker(issuer string, accessSecretKey string, accessPublicKey string, refreshSecretKey string, refreshPublicKey string, accesssDuration time.Duration, refreshDuration time.Duration) (Maker, error) {
-	accessSecret, err := GetPrivateKey(accessSecretKey)
-	if err != nil {
-		return nil, err
+func NewJWTMaker(issuer string, accessKeys *Keyring, refreshKeys *Keyring, accesssDuration time.Duration, refreshDuration time.Duration, options Options) (Maker, error) {
+	if accessKeys == nil || refreshKeys == nil {
+		return nil, errors.New("access and refresh keyring are required")
 	}
-	accessPublic, err := GetPublicKey(accessPublicKey)
-	if err != nil {
-		return nil, err
-	}
-
-	refreshSecret, err := GetPrivateKey(refreshSecretKey)
-	if err != nil {
-		return nil, err
-	}
-	refreshPublic, err := GetPublicKey(refreshPublicKey)
-	if err != nil {
-		return nil, err
+	if accessKeys.method == nil || refreshKeys.method == nil {
+		return nil, errors.New("access and refresh keyring need a jwt algorithm")
 	}
 
 	return &JWTMaker{
 		issuer:          issuer,
-		accessSecret:    accessSecret,
-		accessPublic:    accessPublic,
-		refreshSecret:   refreshSecret,
-		refreshPublic:   refreshPublic,
+		accessKeys:      accessKeys,
+		refreshKeys:     refreshKeys,
 		accessDuration:  accesssDuration,
 		refreshDuration: refreshDuration,
+		options:         options,
 	}, nil
 }
 
-func (maker *JWTMaker) CreateAccessToken(userID int64) (string, error) {
-	payload, err := NewPayload(userID, tokenTypeAccess, maker.issuer, maker.accessDuration)
+func (maker *JWTMaker) CreateAccessToken(userID int64, claims AccessClaims) (string, error) {
+	payload, err := NewPayload(userID, claims, tokenTypeAccess, maker.issuer, maker.accessDuration)
 	if err != nil {
 		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, payload)
+	signed, err := sign(maker.accessKeys, payload)
+	if err != nil {
+		return "", err
+	}
 
-	return token.SignedString(maker.accessSecret)
+	metrics.ObserveTokenIssued(tokenTypeAccess)
+	return signed, nil
 }
 
-func (maker *JWTMaker) CreateRefreshToken(userID int64, tokenHash string) (string, error) {
+func (maker *JWTMaker) CreateRefreshToken(userID int64, sessionID uuid.UUID, tokenHash string) (string, *RefreshPayload, error) {
 
-	cusKey := maker.GenerateCustomKey(userID, tokenHash)
+	cusKey := generateCustomKey(userID, tokenHash)
 
-	payload, err := NewRefreshPayload(userID, cusKey, tokenTypeRefresh, maker.issuer, maker.refreshDuration)
+	payload, err := NewRefreshPayload(userID, sessionID, cusKey, tokenTypeRefresh, maker.issuer, maker.refreshDuration)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, payload)
+	signed, err := sign(maker.refreshKeys, payload)
+	if err != nil {
+		return "", nil, err
+	}
 
-	return token.SignedString(maker.refreshSecret)
+	metrics.ObserveTokenIssued(tokenTypeRefresh)
+	return signed, payload, nil
 }
 
 func (maker *JWTMaker) ValidateAccessToken(token string) (*Payload, error) {
-	keyFunc := func(token *jwt.Token) (interface{}, error) {
-		_, ok := token.Method.(*jwt.SigningMethodRSA)
-		if !ok {
-			return nil, ErrInvalidToken
-		}
-		return maker.accessPublic, nil
+	payload, err := maker.validateAccessToken(token)
+	if err != nil {
+		observeValidationFailure(tokenTypeAccess, err)
 	}
+	return payload, err
+}
 
-	jwtToken, err := jwt.ParseWithClaims(token, &Payload{}, keyFunc)
+func (maker *JWTMaker) validateAccessToken(token string) (*Payload, error) {
+	jwtToken, err := parse(maker.accessKeys, token, &Payload{})
 	if err != nil {
-		verr, ok := err.(*jwt.ValidationError)
-		if ok && errors.Is(verr.Inner, ErrExpiredToken) {
-			return nil, ErrExpiredToken
-		}
 		return nil, ErrInvalidToken
 	}
 
 	payload, ok := jwtToken.Claims.(*Payload)
-	if !ok || !jwtToken.Valid || payload.UserID == 0 || payload.Issuer != maker.issuer || payload.Type != tokenTypeAccess {
+	if !ok || !jwtToken.Valid {
+		return nil, ErrInvalidToken
+	}
+	if err = checkAccessPayload(payload, maker.options); err != nil {
+		return nil, err
+	}
+	if payload.UserID == 0 || payload.Issuer != maker.issuer || payload.Type != tokenTypeAccess {
 		return nil, ErrInvalidToken
 	}
 
@@ -108,53 +105,133 @@ func (maker *JWTMaker) ValidateAccessToken(token string) (*Payload, error) {
 }
 
 func (maker *JWTMaker) ValidateRefreshToken(token string) (*RefreshPayload, error) {
-	keyFunc := func(token *jwt.Token) (interface{}, error) {
-		_, ok := token.Method.(*jwt.SigningMethodRSA)
-		if !ok {
-			return nil, ErrInvalidToken
-		}
-		return maker.refreshPublic, nil
+	payload, err := maker.validateRefreshToken(token)
+	if err != nil {
+		observeValidationFailure(tokenTypeRefresh, err)
 	}
+	return payload, err
+}
 
-	jwtToken, err := jwt.ParseWithClaims(token, &RefreshPayload{}, keyFunc)
+func (maker *JWTMaker) validateRefreshToken(token string) (*RefreshPayload, error) {
+	jwtToken, err := parse(maker.refreshKeys, token, &RefreshPayload{})
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
 
 	payload, ok := jwtToken.Claims.(*RefreshPayload)
-	if !ok || !jwtToken.Valid || payload.UserID == 0 || payload.Issuer != maker.issuer || payload.Type != tokenTypeRefresh {
+	if !ok || !jwtToken.Valid {
+		return nil, ErrInvalidToken
+	}
+	if err = payload.valid(maker.options.Leeway); err != nil {
+		return nil, err
+	}
+	if payload.UserID == 0 || payload.SessionID == uuid.Nil || payload.Issuer != maker.issuer || payload.Type != tokenTypeRefresh {
 		return nil, ErrInvalidToken
 	}
 
 	return payload, nil
 }
 
-func (maker *JWTMaker) ValidateRefreshHash(hash string, userID int64, originalHash string) error {
-	if hash == maker.GenerateCustomKey(userID, originalHash) {
-		return nil
+// sign signs the claims with the signing key of the keyring and names the key
+// in the kid header
+func sign(keys *Keyring, claims jwt.Claims) (string, error) {
+	key := keys.SigningKey()
+	token := jwt.NewWithClaims(keys.method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// parse verifies the token with the keys of the keyring, the alg header must
+// be the algorithm of the keyring so a token cannot pick a weaker algorithm or
+// have its public key used as hmac secret. The times are checked afterwards
+// with the leeway of the maker
+func parse(keys *Keyring, token string, claims jwt.Claims) (*jwt.Token, error) {
+	parser := &jwt.Parser{ValidMethods: []string{keys.Algorithm()}, SkipClaimsValidation: true}
+	return parser.ParseWithClaims(token, claims, keyFunc(keys))
+}
+
+// keyFunc returns the public key of the kid header, tokens of unknown keys are
+// invalid
+func keyFunc(keys *Keyring) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != keys.Algorithm() {
+			return nil, ErrInvalidToken
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.VerificationKey(kid)
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		return key.Public, nil
 	}
+}
 
-	return ErrInvalidToken
+// JWKS publishes the access token keys, the refresh tokens are only checked
+// by this service so their keys stay unpublished. HS256 keys are secrets and
+// are never published
+func (maker *JWTMaker) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range maker.accessKeys.Keys() {
+		if jwk, ok := NewJWK(key.ID, maker.accessKeys.Algorithm(), key.Public); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+func observeValidationFailure(tokenType string, err error) {
+	reason := "invalid"
+	if errors.Is(err, ErrExpiredToken) {
+		reason = "expired"
+	}
+	metrics.ObserveTokenValidationFailure(tokenType, reason)
+}
+
+func (maker *JWTMaker) ValidateRefreshHash(hash string, userID int64, originalHash string) error {
+	return validateRefreshHash(hash, userID, originalHash)
 }
 
 func (maker *JWTMaker) GenerateCustomKey(userID int64, tokenHash string) string {
-	hash := md5.Sum([]byte(strconv.Itoa(int(userID)) + tokenHash))
-	return hex.EncodeToString(hash[:])
+	return generateCustomKey(userID, tokenHash)
 }
 
-func GetPrivateKey(value string) (*rsa.PrivateKey, error) {
-	private, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(value))
-	if err != nil {
-		fmt.Println(err)
-		return nil, errors.New("could not parse private key file")
+// ParseKey parses a key pair of a keyring, verification only keys have no
+// private key and the public key can be left out when the private key is set.
+// HS256 keys are the secret itself and v4.local keys are hex encoded, both
+// without a public key
+func ParseKey(algorithm string, kid string, privateKey string, publicKey string) (Key, error) {
+	key := Key{ID: kid}
+	switch algorithm {
+	case AlgorithmHS256, AlgorithmV4Local:
+		if publicKey != "" {
+			return key, fmt.Errorf("%s keys have no public key", algorithm)
+		}
+		if algorithm == AlgorithmHS256 {
+			key.Private = []byte(privateKey)
+			return key, nil
+		}
+
+		secret, err := hex.DecodeString(privateKey)
+		if err != nil {
+			return key, errors.New("v4.local keys must be hex encoded")
+		}
+		key.Private = secret
+		return key, nil
 	}
-	return private, nil
-}
 
-func GetPublicKey(value string) (*rsa.PublicKey, error) {
-	public, err := jwt.ParseRSAPublicKeyFromPEM([]byte(value))
-	if err != nil {
-		return nil, errors.New("could not parse public key file")
+	var err error
+	if privateKey != "" {
+		key.Private, err = GetPrivateKey(privateKey)
+		if err != nil {
+			return key, err
+		}
+	}
+	if publicKey != "" {
+		key.Public, err = GetPublicKey(publicKey)
+		if err != nil {
+			return key, err
+		}
 	}
-	return public, nil
+	return key, nil
 }
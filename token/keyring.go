@@ -0,0 +1,214 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Key is one key pair of a keyring, keys without a private key only verify
+// tokens. HS256 keys use the secret as []byte for both
+type Key struct {
+	ID      string
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// Keyring holds the keys of one token type, the signing key signs new tokens
+// and every key verifies the tokens that name it in their kid header. All keys
+// of a keyring use the same algorithm, a jwt alg or a paseto purpose
+type Keyring struct {
+	algorithm string
+	method    jwt.SigningMethod
+	signing   *Key
+	keys      map[string]*Key
+	order     []string
+}
+
+// NewKeyring checks the keys and picks the signing key, an empty signingKID
+// picks the first key with a private key. A key without an id gets the
+// thumbprint of its public key
+func NewKeyring(algorithm string, keys []Key, signingKID string) (*Keyring, error) {
+	var method jwt.SigningMethod
+	var err error
+	switch algorithm {
+	case AlgorithmV4Public, AlgorithmV4Local:
+		// paseto keyrings have no jwt signing method
+	default:
+		method, err = signingMethod(algorithm)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ring := &Keyring{algorithm: algorithm, method: method, keys: make(map[string]*Key, len(keys))}
+	for i := range keys {
+		key := keys[i]
+		if key.Public == nil && key.Private != nil {
+			key.Public, err = publicKeyOf(key.Private)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if key.Public == nil {
+			return nil, errors.New("key has no public key")
+		}
+		if err = checkPublicKey(algorithm, key.Public); err != nil {
+			return nil, err
+		}
+		if key.Private != nil {
+			public, err := publicKeyOf(key.Private)
+			if err != nil {
+				return nil, err
+			}
+			if !equalPublicKeys(public, key.Public) {
+				return nil, fmt.Errorf("private and public key of %s do not match", key.ID)
+			}
+		}
+		if key.ID == "" {
+			key.ID = Thumbprint(key.Public)
+		}
+		if _, ok := ring.keys[key.ID]; ok {
+			return nil, fmt.Errorf("key %s is configured twice", key.ID)
+		}
+
+		ring.keys[key.ID] = &key
+		ring.order = append(ring.order, key.ID)
+
+		if ring.signing == nil && key.Private != nil && (signingKID == "" || signingKID == key.ID) {
+			ring.signing = &key
+		}
+	}
+
+	if ring.signing == nil {
+		if signingKID != "" {
+			return nil, fmt.Errorf("signing key %s is not configured with a private key", signingKID)
+		}
+		return nil, errors.New("no key with a private key to sign tokens")
+	}
+	return ring, nil
+}
+
+// Algorithm is the jwt alg or paseto purpose of all keys of the keyring
+func (ring *Keyring) Algorithm() string {
+	return ring.algorithm
+}
+
+// SigningKey is the key new tokens are signed with
+func (ring *Keyring) SigningKey() *Key {
+	return ring.signing
+}
+
+// VerificationKey returns the key of the kid, tokens without kid were signed
+// before the keyring had ids and are checked against the signing key
+func (ring *Keyring) VerificationKey(kid string) (*Key, bool) {
+	if kid == "" {
+		return ring.signing, true
+	}
+	key, ok := ring.keys[kid]
+	return key, ok
+}
+
+// Keys returns every key in the configured order
+func (ring *Keyring) Keys() []*Key {
+	keys := make([]*Key, len(ring.order))
+	for i, kid := range ring.order {
+		keys[i] = ring.keys[kid]
+	}
+	return keys
+}
+
+// JWK is a public key in the JSON Web Key format, the members of the other
+// key types are left out
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg,omitempty"`
+	KeyID     string `json:"kid"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS is the key set served on /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK returns the public key as JWK, HS256 and v4.local secrets cannot be
+// published and return false. Paseto keys have no jwt alg, an empty algorithm
+// leaves it out
+func NewJWK(kid string, algorithm string, public crypto.PublicKey) (JWK, bool) {
+	members, ok := jwkMembers(public)
+	if !ok {
+		return JWK{}, false
+	}
+
+	return JWK{
+		KeyType:   members["kty"],
+		Use:       "sig",
+		Algorithm: algorithm,
+		KeyID:     kid,
+		Curve:     members["crv"],
+		N:         members["n"],
+		E:         members["e"],
+		X:         members["x"],
+		Y:         members["y"],
+	}, true
+}
+
+// jwkMembers returns the members RFC 7638 requires for the key type
+func jwkMembers(public crypto.PublicKey) (map[string]string, bool) {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"n":   encode(key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(key.E)).Bytes()),
+		}, true
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return map[string]string{
+			"kty": "EC",
+			"crv": key.Curve.Params().Name,
+			"x":   encode(key.X.FillBytes(make([]byte, size))),
+			"y":   encode(key.Y.FillBytes(make([]byte, size))),
+		}, true
+	case ed25519.PublicKey:
+		return map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   encode(key),
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// Thumbprint is the RFC 7638 thumbprint of the key, it is the default kid so
+// the same key always gets the same id
+func Thumbprint(public crypto.PublicKey) string {
+	members, ok := jwkMembers(public)
+	if !ok {
+		// HS256 and v4.local secrets, hashed so the kid does not reveal the secret
+		secret, _ := public.([]byte)
+		members = map[string]string{"kty": "oct", "k": base64.RawURLEncoding.EncodeToString(secret)}
+	}
+
+	// json sorts the map keys, the RFC requires the members in lexicographic order
+	canonical, _ := json.Marshal(members)
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
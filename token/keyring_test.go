@@ -0,0 +1,262 @@
+package token
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// rotationMaker builds a maker around the access keyring, the refresh keyring
+// is a fresh one of the matching algorithm
+type rotationMaker struct {
+	algorithm string
+	newMaker  func(t *testing.T, accessKeys *Keyring) Maker
+}
+
+var rotationMakers = map[string]rotationMaker{
+	"jwt": {
+		algorithm: AlgorithmEdDSA,
+		newMaker: func(t *testing.T, accessKeys *Keyring) Maker {
+			maker, err := NewJWTMaker(testIssuer, accessKeys, newTestKeyring(t, AlgorithmHS256), 60, 60, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return maker
+		},
+	},
+	"paseto": {
+		algorithm: AlgorithmV4Public,
+		newMaker: func(t *testing.T, accessKeys *Keyring) Maker {
+			maker, err := NewPasetoMaker(testIssuer, accessKeys, newTestKeyring(t, AlgorithmV4Local), 60, 60, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return maker
+		},
+	},
+}
+
+func mustKeyring(t *testing.T, algorithm string, keys []Key, signingKID string) *Keyring {
+	t.Helper()
+
+	ring, err := NewKeyring(algorithm, keys, signingKID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+// tokenKID returns the kid of a jwt header or paseto footer
+func tokenKID(t *testing.T, token string) string {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	encoded := parts[0]
+	if strings.HasPrefix(token, "v4.") {
+		encoded = parts[len(parts)-1]
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("could not decode %q: %v", encoded, err)
+	}
+	var header struct {
+		KeyID string `json:"kid"`
+	}
+	if err = json.Unmarshal(raw, &header); err != nil {
+		t.Fatalf("could not parse %q: %v", raw, err)
+	}
+	return header.KeyID
+}
+
+func TestKeyRotation(t *testing.T) {
+	for name, rotation := range rotationMakers {
+		t.Run(name, func(t *testing.T) {
+			oldKey := newTestKey(t, rotation.algorithm)
+			newKey := newTestKey(t, rotation.algorithm)
+			keys := []Key{{ID: "old", Private: oldKey}, {ID: "new", Private: newKey}}
+
+			// the new key is published before it signs
+			before := rotation.newMaker(t, mustKeyring(t, rotation.algorithm, keys, "old"))
+			oldToken, err := before.CreateAccessToken(42, AccessClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kid := tokenKID(t, oldToken); kid != "old" {
+				t.Fatalf("kid = %q, want old", kid)
+			}
+
+			after := rotation.newMaker(t, mustKeyring(t, rotation.algorithm, keys, "new"))
+			newToken, err := after.CreateAccessToken(42, AccessClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kid := tokenKID(t, newToken); kid != "new" {
+				t.Fatalf("kid = %q, want new", kid)
+			}
+			if _, err = after.ValidateAccessToken(oldToken); err != nil {
+				t.Errorf("token of the old key after switching the signing key = %v, want it valid", err)
+			}
+
+			// the old key only verifies until its tokens have expired
+			public := oldKey.(crypto.Signer).Public()
+			verifyOnly := rotation.newMaker(t, mustKeyring(t, rotation.algorithm, []Key{{ID: "old", Public: public}, {ID: "new", Private: newKey}}, ""))
+			if _, err = verifyOnly.ValidateAccessToken(oldToken); err != nil {
+				t.Errorf("token of the verification only key = %v, want it valid", err)
+			}
+
+			removed := rotation.newMaker(t, mustKeyring(t, rotation.algorithm, []Key{{ID: "new", Private: newKey}}, ""))
+			if _, err = removed.ValidateAccessToken(oldToken); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("token of a removed key = %v, want %v", err, ErrInvalidToken)
+			}
+			if _, err = removed.ValidateAccessToken(newToken); err != nil {
+				t.Errorf("token of the new key = %v, want it valid", err)
+			}
+		})
+	}
+}
+
+func TestUnknownKeyID(t *testing.T) {
+	for name, rotation := range rotationMakers {
+		t.Run(name, func(t *testing.T) {
+			key := newTestKey(t, rotation.algorithm)
+
+			// the signature is valid, only the kid is not in the keyring
+			maker := rotation.newMaker(t, mustKeyring(t, rotation.algorithm, []Key{{ID: "known", Private: key}}, ""))
+			other := rotation.newMaker(t, mustKeyring(t, rotation.algorithm, []Key{{ID: "unknown", Private: key}}, ""))
+
+			token, err := other.CreateAccessToken(42, AccessClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = maker.ValidateAccessToken(token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("token with an unknown kid = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestNewKeyringSigningKey(t *testing.T) {
+	key := newTestKey(t, AlgorithmEdDSA)
+	public := key.(crypto.Signer).Public()
+
+	ring := mustKeyring(t, AlgorithmEdDSA, []Key{{ID: "verify", Public: newTestKey(t, AlgorithmEdDSA).(crypto.Signer).Public()}, {ID: "sign", Private: key}}, "")
+	if ring.SigningKey().ID != "sign" {
+		t.Errorf("signing key = %s, want the first key with a private key", ring.SigningKey().ID)
+	}
+
+	if _, err := NewKeyring(AlgorithmEdDSA, []Key{{ID: "verify", Public: public}}, ""); err == nil {
+		t.Error("keyring without a private key was created")
+	}
+	if _, err := NewKeyring(AlgorithmEdDSA, []Key{{ID: "verify", Public: public}, {ID: "sign", Private: key}}, "verify"); err == nil {
+		t.Error("keyring signing with a verification only key was created")
+	}
+	if _, err := NewKeyring(AlgorithmEdDSA, []Key{{ID: "same", Private: key}, {ID: "same", Private: newTestKey(t, AlgorithmEdDSA)}}, ""); err == nil {
+		t.Error("keyring with a kid configured twice was created")
+	}
+
+	// keys without an id are named by their thumbprint
+	ring = mustKeyring(t, AlgorithmEdDSA, []Key{{Private: key}}, "")
+	if ring.SigningKey().ID != Thumbprint(public) {
+		t.Errorf("kid = %s, want the thumbprint %s", ring.SigningKey().ID, Thumbprint(public))
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	jwtMakerWith := func(t *testing.T, algorithm string) Maker {
+		maker, err := NewJWTMaker(testIssuer, newTestKeyring(t, algorithm), newTestKeyring(t, AlgorithmHS256), 60, 60, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return maker
+	}
+
+	tests := []struct {
+		name     string
+		maker    func(t *testing.T) Maker
+		wantJWKs []JWK
+	}{
+		{
+			name:     "RS256",
+			maker:    func(t *testing.T) Maker { return jwtMakerWith(t, AlgorithmRS256) },
+			wantJWKs: []JWK{{KeyType: "RSA", Algorithm: AlgorithmRS256}},
+		},
+		{
+			name:     "ES256",
+			maker:    func(t *testing.T) Maker { return jwtMakerWith(t, AlgorithmES256) },
+			wantJWKs: []JWK{{KeyType: "EC", Algorithm: AlgorithmES256, Curve: "P-256"}},
+		},
+		{
+			name:     "EdDSA",
+			maker:    func(t *testing.T) Maker { return jwtMakerWith(t, AlgorithmEdDSA) },
+			wantJWKs: []JWK{{KeyType: "OKP", Algorithm: AlgorithmEdDSA, Curve: "Ed25519"}},
+		},
+		{
+			name:     "HS256",
+			maker:    func(t *testing.T) Maker { return jwtMakerWith(t, AlgorithmHS256) },
+			wantJWKs: []JWK{},
+		},
+		{
+			name:     "paseto",
+			maker:    func(t *testing.T) Maker { return pasetoMaker(t, 60, 60, Options{}) },
+			wantJWKs: []JWK{{KeyType: "OKP", Curve: "Ed25519"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwks := tt.maker(t).JWKS()
+			if len(jwks.Keys) != len(tt.wantJWKs) {
+				t.Fatalf("published %d keys, want %d: %+v", len(jwks.Keys), len(tt.wantJWKs), jwks.Keys)
+			}
+
+			for i, want := range tt.wantJWKs {
+				got := jwks.Keys[i]
+				if got.KeyType != want.KeyType || got.Algorithm != want.Algorithm || got.Curve != want.Curve {
+					t.Errorf("key = %+v, want kty %s alg %q crv %q", got, want.KeyType, want.Algorithm, want.Curve)
+				}
+				if got.KeyID != "test" || got.Use != "sig" {
+					t.Errorf("kid = %q use = %q, want test sig", got.KeyID, got.Use)
+				}
+			}
+
+			// an empty set is still a list, clients cannot handle null
+			encoded, err := json.Marshal(jwks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tt.wantJWKs) == 0 && string(encoded) != `{"keys":[]}` {
+				t.Errorf("jwks = %s, want an empty key list", encoded)
+			}
+		})
+	}
+}
+
+func TestJWKSPublishesEveryAccessKey(t *testing.T) {
+	oldKey := newTestKey(t, AlgorithmRS256)
+	ring := mustKeyring(t, AlgorithmRS256, []Key{
+		{ID: "old", Public: oldKey.(crypto.Signer).Public()},
+		{ID: "new", Private: newTestKey(t, AlgorithmRS256)},
+	}, "")
+
+	maker, err := NewJWTMaker(testIssuer, ring, newTestKeyring(t, AlgorithmHS256), 60, 60, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := maker.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].KeyID != "old" || jwks.Keys[1].KeyID != "new" {
+		t.Fatalf("jwks = %+v, want the old and the new key", jwks.Keys)
+	}
+}
+
+func TestNewJWKRejectsSecrets(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmHS256, AlgorithmV4Local} {
+		if jwk, ok := NewJWK("secret", algorithm, newTestKey(t, algorithm)); ok {
+			t.Errorf("%s secret was published as %+v", algorithm, jwk)
+		}
+	}
+}
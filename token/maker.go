@@ -1,14 +1,61 @@
 package token
 
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
 // Maker is an interface for managing tokens
 type Maker interface {
-	CreateAccessToken(userID int64) (string, error)
+	CreateAccessToken(userID int64, claims AccessClaims) (string, error)
 
-	CreateRefreshToken(userID int64, tokenHash string) (string, error)
+	CreateRefreshToken(userID int64, sessionID uuid.UUID, tokenHash string) (string, *RefreshPayload, error)
 
 	ValidateAccessToken(token string) (*Payload, error)
 
 	ValidateRefreshToken(token string) (*RefreshPayload, error)
 
 	ValidateRefreshHash(hash string, userID int64, originalHash string) error
+
+	// JWKS returns the public keys other services verify access tokens with
+	JWKS() JWKS
+}
+
+// Options are the checks the makers apply on top of the signature
+type Options struct {
+	// Audience must be named by access tokens, all tokens are accepted when it
+	// is empty
+	Audience string
+	// Leeway is the clock skew allowed on the issue and expiry time
+	Leeway time.Duration
+}
+
+// checkAccessPayload checks the times and audience of a verified access token
+func checkAccessPayload(payload *Payload, options Options) error {
+	if err := payload.valid(options.Leeway); err != nil {
+		return err
+	}
+	if options.Audience != "" && !payload.HasAudience(options.Audience) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// generateCustomKey is the hash of the refresh payload, it ties the refresh
+// token to the token hash and secret of its session
+func generateCustomKey(userID int64, tokenHash string) string {
+	hash := md5.Sum([]byte(strconv.Itoa(int(userID)) + tokenHash))
+	return hex.EncodeToString(hash[:])
+}
+
+func validateRefreshHash(hash string, userID int64, originalHash string) error {
+	if hash == generateCustomKey(userID, originalHash) {
+		return nil
+	}
+
+	return ErrInvalidToken
 }
@@ -0,0 +1,335 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const testIssuer = "test-issuer"
+
+// testMaker builds a maker with the given access and refresh durations in
+// seconds and options
+type testMaker func(t *testing.T, accessDuration time.Duration, refreshDuration time.Duration, options Options) Maker
+
+func newTestKeyring(t *testing.T, algorithm string) *Keyring {
+	t.Helper()
+
+	keys, err := NewKeyring(algorithm, []Key{{ID: "test", Private: newTestKey(t, algorithm)}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// newTestKey generates a private key of the algorithm
+func newTestKey(t *testing.T, algorithm string) crypto.PrivateKey {
+	t.Helper()
+
+	switch algorithm {
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	case AlgorithmES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	case AlgorithmEdDSA, AlgorithmV4Public:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	case AlgorithmHS256, AlgorithmV4Local:
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatal(err)
+		}
+		return key
+	default:
+		t.Fatalf("no test key for %s", algorithm)
+		return nil
+	}
+}
+
+// jwtMaker signs the access tokens with the algorithm and the refresh tokens
+// with HS256
+func jwtMaker(algorithm string) testMaker {
+	return func(t *testing.T, accessDuration time.Duration, refreshDuration time.Duration, options Options) Maker {
+		t.Helper()
+
+		maker, err := NewJWTMaker(testIssuer, newTestKeyring(t, algorithm), newTestKeyring(t, AlgorithmHS256), accessDuration, refreshDuration, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return maker
+	}
+}
+
+// jwtSharedKeyMaker signs both token types with the same key, so only the
+// type claim keeps a refresh token from being used as access token
+func jwtSharedKeyMaker(t *testing.T, accessDuration time.Duration, refreshDuration time.Duration, options Options) Maker {
+	t.Helper()
+
+	keys := newTestKeyring(t, AlgorithmHS256)
+	maker, err := NewJWTMaker(testIssuer, keys, keys, accessDuration, refreshDuration, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return maker
+}
+
+func pasetoMaker(t *testing.T, accessDuration time.Duration, refreshDuration time.Duration, options Options) Maker {
+	t.Helper()
+
+	maker, err := NewPasetoMaker(testIssuer, newTestKeyring(t, AlgorithmV4Public), newTestKeyring(t, AlgorithmV4Local), accessDuration, refreshDuration, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return maker
+}
+
+var testMakers = map[string]testMaker{
+	"jwt RS256":      jwtMaker(AlgorithmRS256),
+	"jwt ES256":      jwtMaker(AlgorithmES256),
+	"jwt EdDSA":      jwtMaker(AlgorithmEdDSA),
+	"jwt shared key": jwtSharedKeyMaker,
+	"paseto":         pasetoMaker,
+}
+
+// tamper changes one character in the middle of the token
+func tamper(token string) string {
+	i := len(token) / 2
+	replacement := byte('A')
+	if token[i] == replacement {
+		replacement = 'B'
+	}
+	return token[:i] + string(replacement) + token[i+1:]
+}
+
+func TestMakers(t *testing.T) {
+	for name, newMaker := range testMakers {
+		t.Run(name, func(t *testing.T) {
+			t.Run("access token round trip", func(t *testing.T) {
+				testAccessTokenRoundTrip(t, newMaker(t, 60, 60, Options{Audience: "api"}))
+			})
+			t.Run("refresh token round trip", func(t *testing.T) {
+				testRefreshTokenRoundTrip(t, newMaker(t, 60, 60, Options{}))
+			})
+			t.Run("expired tokens", func(t *testing.T) {
+				testExpiredTokens(t, newMaker(t, -1, -1, Options{}))
+			})
+			t.Run("leeway", func(t *testing.T) {
+				testLeeway(t, newMaker(t, -1, -1, Options{Leeway: time.Minute}))
+			})
+			t.Run("wrong token type", func(t *testing.T) {
+				testWrongTokenType(t, newMaker(t, 60, 60, Options{}))
+			})
+			t.Run("wrong audience", func(t *testing.T) {
+				testWrongAudience(t, newMaker(t, 60, 60, Options{Audience: "api"}))
+			})
+			t.Run("refresh hash", func(t *testing.T) {
+				testRefreshHash(t, newMaker(t, 60, 60, Options{}))
+			})
+			t.Run("tampered tokens", func(t *testing.T) {
+				testTamperedTokens(t, newMaker(t, 60, 60, Options{}))
+			})
+			t.Run("tokens of another maker", func(t *testing.T) {
+				testTokensOfAnotherMaker(t, newMaker(t, 60, 60, Options{}), newMaker(t, 60, 60, Options{}))
+			})
+		})
+	}
+}
+
+func testAccessTokenRoundTrip(t *testing.T, maker Maker) {
+	sessionID := uuid.New()
+	claims := AccessClaims{
+		Audience:  []string{"api", "admin"},
+		Scopes:    []string{"read", "write"},
+		Roles:     []string{"admin"},
+		SessionID: sessionID,
+		Locale:    "de",
+		Custom:    map[string]any{"tenant": "acme"},
+	}
+
+	token, err := maker.CreateAccessToken(42, claims)
+	if err != nil {
+		t.Fatalf("could not create access token: %v", err)
+	}
+
+	payload, err := maker.ValidateAccessToken(token)
+	if err != nil {
+		t.Fatalf("access token is invalid: %v", err)
+	}
+	if payload.UserID != 42 || payload.Type != tokenTypeAccess || payload.Issuer != testIssuer {
+		t.Errorf("payload = %+v, want user 42 access token of %s", payload, testIssuer)
+	}
+	if payload.SessionID != sessionID || payload.Locale != "de" {
+		t.Errorf("sid = %s locale = %q, want %s de", payload.SessionID, payload.Locale, sessionID)
+	}
+	if !payload.HasAudience("admin") || !payload.HasScope("write") || !payload.HasRole("admin") {
+		t.Errorf("payload = %+v, want the audience, scopes and roles of the claims", payload)
+	}
+	if payload.Claims["tenant"] != "acme" {
+		t.Errorf("custom claims = %v, want tenant acme", payload.Claims)
+	}
+	if payload.ID == uuid.Nil || !payload.ExpiredAt.After(time.Now()) {
+		t.Errorf("id = %s exp = %s, want an id and an expiry in the future", payload.ID, payload.ExpiredAt)
+	}
+}
+
+func testRefreshTokenRoundTrip(t *testing.T, maker Maker) {
+	sessionID := uuid.New()
+
+	token, created, err := maker.CreateRefreshToken(42, sessionID, "token-hash")
+	if err != nil {
+		t.Fatalf("could not create refresh token: %v", err)
+	}
+
+	payload, err := maker.ValidateRefreshToken(token)
+	if err != nil {
+		t.Fatalf("refresh token is invalid: %v", err)
+	}
+	if payload.UserID != 42 || payload.SessionID != sessionID || payload.Type != tokenTypeRefresh || payload.Issuer != testIssuer {
+		t.Errorf("payload = %+v, want user 42 refresh token of session %s", payload, sessionID)
+	}
+	if payload.ID != created.ID || payload.Hash != created.Hash {
+		t.Errorf("payload = %+v, want the created payload %+v", payload, created)
+	}
+}
+
+func testExpiredTokens(t *testing.T, maker Maker) {
+	access, err := maker.CreateAccessToken(42, AccessClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = maker.ValidateAccessToken(access); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("expired access token = %v, want %v", err, ErrExpiredToken)
+	}
+
+	refresh, _, err := maker.CreateRefreshToken(42, uuid.New(), "token-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = maker.ValidateRefreshToken(refresh); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("expired refresh token = %v, want %v", err, ErrExpiredToken)
+	}
+}
+
+func testLeeway(t *testing.T, maker Maker) {
+	access, err := maker.CreateAccessToken(42, AccessClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = maker.ValidateAccessToken(access); err != nil {
+		t.Errorf("access token expired within the leeway = %v, want it valid", err)
+	}
+
+	refresh, _, err := maker.CreateRefreshToken(42, uuid.New(), "token-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = maker.ValidateRefreshToken(refresh); err != nil {
+		t.Errorf("refresh token expired within the leeway = %v, want it valid", err)
+	}
+}
+
+func testWrongTokenType(t *testing.T, maker Maker) {
+	access, err := maker.CreateAccessToken(42, AccessClaims{SessionID: uuid.New()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh, _, err := maker.CreateRefreshToken(42, uuid.New(), "token-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = maker.ValidateAccessToken(refresh); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh token as access token = %v, want %v", err, ErrInvalidToken)
+	}
+	if _, err = maker.ValidateRefreshToken(access); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("access token as refresh token = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func testWrongAudience(t *testing.T, maker Maker) {
+	for _, audience := range [][]string{{"other"}, nil} {
+		token, err := maker.CreateAccessToken(42, AccessClaims{Audience: audience})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = maker.ValidateAccessToken(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("token for audience %v = %v, want %v", audience, err, ErrInvalidToken)
+		}
+	}
+}
+
+func testRefreshHash(t *testing.T, maker Maker) {
+	token, _, err := maker.CreateRefreshToken(42, uuid.New(), "token-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := maker.ValidateRefreshToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = maker.ValidateRefreshHash(payload.Hash, 42, "token-hash"); err != nil {
+		t.Errorf("hash of the session = %v, want it valid", err)
+	}
+	if err = maker.ValidateRefreshHash(payload.Hash, 42, "other-hash"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("hash of another session = %v, want %v", err, ErrInvalidToken)
+	}
+	if err = maker.ValidateRefreshHash(payload.Hash, 43, "token-hash"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("hash of another user = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func testTamperedTokens(t *testing.T, maker Maker) {
+	access, err := maker.CreateAccessToken(42, AccessClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = maker.ValidateAccessToken(tamper(access)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("tampered access token = %v, want %v", err, ErrInvalidToken)
+	}
+
+	refresh, _, err := maker.CreateRefreshToken(42, uuid.New(), "token-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = maker.ValidateRefreshToken(tamper(refresh)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("tampered refresh token = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func testTokensOfAnotherMaker(t *testing.T, maker Maker, other Maker) {
+	access, err := other.CreateAccessToken(42, AccessClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = maker.ValidateAccessToken(access); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("access token signed with another key = %v, want %v", err, ErrInvalidToken)
+	}
+
+	refresh, _, err := other.CreateRefreshToken(42, uuid.New(), "token-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = maker.ValidateRefreshToken(refresh); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh token signed with another key = %v, want %v", err, ErrInvalidToken)
+	}
+}
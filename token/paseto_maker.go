@@ -0,0 +1,219 @@
+package token
+
+import (
+	"backend/metrics"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/google/uuid"
+)
+
+// PasetoMaker is a PASETO maker, access tokens are v4.public so other services
+// can verify them and refresh tokens are v4.local so the client cannot read the
+// session and hash they carry
+type PasetoMaker struct {
+	issuer          string
+	accessKeys      *Keyring
+	refreshKeys     *Keyring
+	accessDuration  time.Duration
+	refreshDuration time.Duration
+	options         Options
+}
+
+// pasetoFooter names the key of the token, the footer is authenticated but
+// not encrypted
+type pasetoFooter struct {
+	KeyID string `json:"kid"`
+}
+
+func NewPasetoMaker(issuer string, accessKeys *Keyring, refreshKeys *Keyring, accessDuration time.Duration, refreshDuration time.Duration, options Options) (Maker, error) {
+	if accessKeys == nil || refreshKeys == nil {
+		return nil, errors.New("access and refresh keyring are required")
+	}
+	if accessKeys.Algorithm() != AlgorithmV4Public || refreshKeys.Algorithm() != AlgorithmV4Local {
+		return nil, errors.New("paseto needs a v4.public access keyring and a v4.local refresh keyring")
+	}
+
+	return &PasetoMaker{
+		issuer:          issuer,
+		accessKeys:      accessKeys,
+		refreshKeys:     refreshKeys,
+		accessDuration:  accessDuration,
+		refreshDuration: refreshDuration,
+		options:         options,
+	}, nil
+}
+
+func (maker *PasetoMaker) CreateAccessToken(userID int64, claims AccessClaims) (string, error) {
+	payload, err := NewPayload(userID, claims, tokenTypeAccess, maker.issuer, maker.accessDuration)
+	if err != nil {
+		return "", err
+	}
+
+	key := maker.accessKeys.SigningKey()
+	token, err := newPasetoToken(key, payload)
+	if err != nil {
+		return "", err
+	}
+
+	secretKey, err := paseto.NewV4AsymmetricSecretKeyFromEd25519(key.Private.(ed25519.PrivateKey))
+	if err != nil {
+		return "", err
+	}
+
+	metrics.ObserveTokenIssued(tokenTypeAccess)
+	return token.V4Sign(secretKey, nil), nil
+}
+
+func (maker *PasetoMaker) CreateRefreshToken(userID int64, sessionID uuid.UUID, tokenHash string) (string, *RefreshPayload, error) {
+	payload, err := NewRefreshPayload(userID, sessionID, generateCustomKey(userID, tokenHash), tokenTypeRefresh, maker.issuer, maker.refreshDuration)
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := maker.refreshKeys.SigningKey()
+	token, err := newPasetoToken(key, payload)
+	if err != nil {
+		return "", nil, err
+	}
+
+	symmetricKey, err := paseto.V4SymmetricKeyFromBytes(key.Private.([]byte))
+	if err != nil {
+		return "", nil, err
+	}
+
+	metrics.ObserveTokenIssued(tokenTypeRefresh)
+	return token.V4Encrypt(symmetricKey, nil), payload, nil
+}
+
+func (maker *PasetoMaker) ValidateAccessToken(token string) (*Payload, error) {
+	payload, err := maker.validateAccessToken(token)
+	if err != nil {
+		observeValidationFailure(tokenTypeAccess, err)
+	}
+	return payload, err
+}
+
+func (maker *PasetoMaker) validateAccessToken(token string) (*Payload, error) {
+	key, err := pasetoKey(maker.accessKeys, paseto.V4Public, token)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := paseto.NewV4AsymmetricPublicKeyFromEd25519(key.Public.(ed25519.PublicKey))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	parsed, err := paseto.NewParserWithoutExpiryCheck().ParseV4Public(publicKey, token, nil)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{}
+	if err = json.Unmarshal(parsed.ClaimsJSON(), payload); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err = checkAccessPayload(payload, maker.options); err != nil {
+		return nil, err
+	}
+	if payload.UserID == 0 || payload.Issuer != maker.issuer || payload.Type != tokenTypeAccess {
+		return nil, ErrInvalidToken
+	}
+
+	return payload, nil
+}
+
+func (maker *PasetoMaker) ValidateRefreshToken(token string) (*RefreshPayload, error) {
+	payload, err := maker.validateRefreshToken(token)
+	if err != nil {
+		observeValidationFailure(tokenTypeRefresh, err)
+	}
+	return payload, err
+}
+
+func (maker *PasetoMaker) validateRefreshToken(token string) (*RefreshPayload, error) {
+	key, err := pasetoKey(maker.refreshKeys, paseto.V4Local, token)
+	if err != nil {
+		return nil, err
+	}
+
+	symmetricKey, err := paseto.V4SymmetricKeyFromBytes(key.Public.([]byte))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	parsed, err := paseto.NewParserWithoutExpiryCheck().ParseV4Local(symmetricKey, token, nil)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &RefreshPayload{}
+	if err = json.Unmarshal(parsed.ClaimsJSON(), payload); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err = payload.valid(maker.options.Leeway); err != nil {
+		return nil, err
+	}
+	if payload.UserID == 0 || payload.SessionID == uuid.Nil || payload.Issuer != maker.issuer || payload.Type != tokenTypeRefresh {
+		return nil, ErrInvalidToken
+	}
+
+	return payload, nil
+}
+
+func (maker *PasetoMaker) ValidateRefreshHash(hash string, userID int64, originalHash string) error {
+	return validateRefreshHash(hash, userID, originalHash)
+}
+
+// JWKS publishes the v4.public access keys as Ed25519 JWKs without alg, the
+// kid matches the one in the token footer
+func (maker *PasetoMaker) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range maker.accessKeys.Keys() {
+		if jwk, ok := NewJWK(key.ID, "", key.Public); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+// newPasetoToken uses the json of the payload as claims, so the tokens have
+// the same claims as the jwts and iat and exp are RFC 3339 as paseto requires
+func newPasetoToken(key *Key, payload interface{}) (*paseto.Token, error) {
+	claims, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	footer, err := json.Marshal(pasetoFooter{KeyID: key.ID})
+	if err != nil {
+		return nil, err
+	}
+	return paseto.NewTokenFromClaimsJSON(claims, footer)
+}
+
+// pasetoKey returns the key of the kid in the footer, the footer is read
+// before the token is verified so it only selects the key
+func pasetoKey(keys *Keyring, protocol paseto.Protocol, token string) (*Key, error) {
+	rawFooter, err := paseto.NewParser().UnsafeParseFooter(protocol, token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var footer pasetoFooter
+	if len(rawFooter) > 0 {
+		if err = json.Unmarshal(rawFooter, &footer); err != nil {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	key, ok := keys.VerificationKey(footer.KeyID)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return key, nil
+}
@@ -2,6 +2,7 @@ package token
 
 import (
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,21 +12,42 @@ import (
 var (
 	ErrInvalidToken = errors.New("token is invalid")
 	ErrExpiredToken = errors.New("token has expired")
+	ErrRevokedToken = errors.New("token has been revoked")
 )
 
 // Payload contains the payload data of the token
 type Payload struct {
-	ID        uuid.UUID `json:"id"`
-	Type      string    `json:"type"`
-	UserID    int64     `json:"userId"`
-	Issuer    string    `json:"iss"`
-	IssuedAt  time.Time `json:"iat"`
-	ExpiredAt time.Time `json:"exp"`
+	ID        uuid.UUID      `json:"id"`
+	Type      string         `json:"type"`
+	UserID    int64          `json:"userId"`
+	SessionID uuid.UUID      `json:"sid"`
+	Issuer    string         `json:"iss"`
+	Audience  []string       `json:"aud,omitempty"`
+	Scopes    []string       `json:"scopes,omitempty"`
+	Roles     []string       `json:"roles,omitempty"`
+	Locale    string         `json:"locale,omitempty"`
+	Claims    map[string]any `json:"claims,omitempty"`
+	IssuedAt  time.Time      `json:"iat"`
+	ExpiredAt time.Time      `json:"exp"`
+}
+
+// AccessClaims are the optional claims of an access token, custom claims are
+// kept under "claims" so they cannot replace the registered ones
+type AccessClaims struct {
+	Audience  []string
+	Scopes    []string
+	Roles     []string
+	SessionID uuid.UUID
+	// Locale is the language the user picked, it is used for the messages of
+	// the requests made with the token
+	Locale string
+	Custom map[string]any
 }
 
 type RefreshPayload struct {
 	ID        uuid.UUID `json:"id"`
 	Type      string    `json:"type"`
+	SessionID uuid.UUID `json:"sid"`
 	Hash      string    `json:"hash"`
 	UserID    int64     `json:"userId"`
 	Issuer    string    `json:"iss"`
@@ -33,7 +55,7 @@ type RefreshPayload struct {
 	ExpiredAt time.Time `json:"exp"`
 }
 
-func NewPayload(userID int64, tokenType string, issuer string, duration time.Duration) (*Payload, error) {
+func NewPayload(userID int64, claims AccessClaims, tokenType string, issuer string, duration time.Duration) (*Payload, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
@@ -43,14 +65,20 @@ func NewPayload(userID int64, tokenType string, issuer string, duration time.Dur
 		ID:        tokenID,
 		Type:      tokenType,
 		UserID:    userID,
+		SessionID: claims.SessionID,
 		Issuer:    issuer,
+		Audience:  claims.Audience,
+		Scopes:    claims.Scopes,
+		Roles:     claims.Roles,
+		Locale:    claims.Locale,
+		Claims:    claims.Custom,
 		IssuedAt:  time.Now(),
 		ExpiredAt: time.Now().Add(duration * time.Second),
 	}
 	return payload, nil
 }
 
-func NewRefreshPayload(userID int64, hash string, tokenType string, issuer string, duration time.Duration) (*RefreshPayload, error) {
+func NewRefreshPayload(userID int64, sessionID uuid.UUID, hash string, tokenType string, issuer string, duration time.Duration) (*RefreshPayload, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
@@ -59,6 +87,7 @@ func NewRefreshPayload(userID int64, hash string, tokenType string, issuer strin
 	payload := &RefreshPayload{
 		ID:        tokenID,
 		Type:      tokenType,
+		SessionID: sessionID,
 		Hash:      hash,
 		UserID:    userID,
 		Issuer:    issuer,
@@ -68,16 +97,48 @@ func NewRefreshPayload(userID int64, hash string, tokenType string, issuer strin
 	return payload, nil
 }
 
+// Valid is called by jwt-go, the makers check the times with their leeway
+// through valid instead
 func (payload *Payload) Valid() error {
-	if time.Now().After(payload.ExpiredAt) {
-		return ErrExpiredToken
-	}
-	return nil
+	return payload.valid(0)
 }
 
 func (payload *RefreshPayload) Valid() error {
-	if time.Now().After(payload.ExpiredAt) {
+	return payload.valid(0)
+}
+
+func (payload *Payload) valid(leeway time.Duration) error {
+	return validTimes(payload.IssuedAt, payload.ExpiredAt, leeway)
+}
+
+func (payload *RefreshPayload) valid(leeway time.Duration) error {
+	return validTimes(payload.IssuedAt, payload.ExpiredAt, leeway)
+}
+
+// validTimes allows the clocks of the issuer and this service to differ by the
+// leeway, tokens issued further in the future are invalid
+func validTimes(issuedAt time.Time, expiredAt time.Time, leeway time.Duration) error {
+	now := time.Now()
+	if now.After(expiredAt.Add(leeway)) {
 		return ErrExpiredToken
 	}
+	if issuedAt.After(now.Add(leeway)) {
+		return ErrInvalidToken
+	}
 	return nil
 }
+
+// HasAudience reports whether the token names the audience
+func (payload *Payload) HasAudience(audience string) bool {
+	return slices.Contains(payload.Audience, audience)
+}
+
+// HasScope reports whether the token grants the scope
+func (payload *Payload) HasScope(scope string) bool {
+	return slices.Contains(payload.Scopes, scope)
+}
+
+// HasRole reports whether the token has the role
+func (payload *Payload) HasRole(role string) bool {
+	return slices.Contains(payload.Roles, role)
+}
@@ -19,6 +19,18 @@ type Config struct {
 	CORS        []string         `mapstructure:"CORS"`
 	TOKEN       TokenConfig      `mapstructure:"TOKEN"`
 	GOOGLE      GoogleConfig     `mapstructure:"GOOGLE"`
+	MAIL        MailConfig       `mapstructure:"MAIL"`
+
+	EMAIL_VERIFICATION UserTokenConfig      `mapstructure:"EMAIL_VERIFICATION"`
+	PASSWORD_RESET     UserTokenConfig      `mapstructure:"PASSWORD_RESET"`
+	TWO_FACTOR         TwoFactorConfig      `mapstructure:"TWO_FACTOR"`
+	PASSKEY            PasskeyConfig        `mapstructure:"PASSKEY"`
+	EMAIL_LINK         EmailLinkConfig      `mapstructure:"EMAIL_LINK"`
+	OIDC               []OIDCProviderConfig `mapstructure:"OIDC"`
+	MIGRATIONS         MigrationsConfig     `mapstructure:"MIGRATIONS"`
+	SERVER             ServerConfig         `mapstructure:"SERVER"`
+	METRICS            MetricsConfig        `mapstructure:"METRICS"`
+	TRACING            TracingConfig        `mapstructure:"TRACING"`
 }
 
 type SchedulerConfig struct {
@@ -46,10 +58,29 @@ type InstagramConfig struct {
 	INSTAGRAM_WEBHOOK_VERIFY_TOKEN string `mapstructure:"INSTAGRAM_WEBHOOK_VERIFY_TOKEN"`
 }
 
+// GoogleConfig configures the google login, the urls default to the google
+// endpoints and only need to be set to point them somewhere else
 type GoogleConfig struct {
-	CLIENT_ID     string `mapstructure:"CLIENT_ID"`
-	CLIENT_SECRET string `mapstructure:"CLIENT_SECRET"`
-	REDIRECT_URI  string `mapstructure:"REDIRECT_URI"`
+	CLIENT_ID      string        `mapstructure:"CLIENT_ID"`
+	CLIENT_SECRET  string        `mapstructure:"CLIENT_SECRET"`
+	REDIRECT_URI   string        `mapstructure:"REDIRECT_URI"`
+	STATE_DURATION time.Duration `mapstructure:"STATE_DURATION"`
+	ISSUER         string        `mapstructure:"ISSUER"`
+	AUTH_URL       string        `mapstructure:"AUTH_URL"`
+	TOKEN_URL      string        `mapstructure:"TOKEN_URL"`
+	JWKS_URL       string        `mapstructure:"JWKS_URL"`
+}
+
+// OIDCProviderConfig configures one OpenID Connect provider, KEY is the name
+// of the provider in the api
+type OIDCProviderConfig struct {
+	KEY            string        `mapstructure:"KEY"`
+	ISSUER_URL     string        `mapstructure:"ISSUER_URL"`
+	CLIENT_ID      string        `mapstructure:"CLIENT_ID"`
+	CLIENT_SECRET  string        `mapstructure:"CLIENT_SECRET"`
+	SCOPES         []string      `mapstructure:"SCOPES"`
+	REDIRECT_URI   string        `mapstructure:"REDIRECT_URI"`
+	STATE_DURATION time.Duration `mapstructure:"STATE_DURATION"`
 }
 
 type LLMConfig struct {
@@ -63,13 +94,109 @@ type ImagesConfig struct {
 	GOOGLE_CUSTOM_SEARCH_ENGINE_ID string `mapstructure:"GOOGLE_CUSTOM_SEARCH_ENGINE_ID"`
 }
 
+// TokenConfig configures the token keys, the SECRET_KEY and PUBLIC_KEY pair of
+// a token type is its first key, more keys are added in the KEYS lists and the
+// SIGNING_KID picks the key new tokens are signed with
 type TokenConfig struct {
-	ACCESS_SECRET_KEY      string        `mapstructure:"ACCESS_SECRET_KEY"`
-	ACCESS_PUBLIC_KEY      string        `mapstructure:"ACCESS_PUBLIC_KEY"`
-	ACCESS_TOKEN_DURATION  time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
-	REFRESH_SECRET_KEY     string        `mapstructure:"REFRESH_SECRET_KEY"`
-	REFRESH_PUBLIC_KEY     string        `mapstructure:"REFRESH_PUBLIC_KEY"`
-	REFRESH_TOKEN_DURATION time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	ACCESS_SECRET_KEY      string           `mapstructure:"ACCESS_SECRET_KEY"`
+	ACCESS_PUBLIC_KEY      string           `mapstructure:"ACCESS_PUBLIC_KEY"`
+	ACCESS_TOKEN_DURATION  time.Duration    `mapstructure:"ACCESS_TOKEN_DURATION"`
+	REFRESH_SECRET_KEY     string           `mapstructure:"REFRESH_SECRET_KEY"`
+	REFRESH_PUBLIC_KEY     string           `mapstructure:"REFRESH_PUBLIC_KEY"`
+	REFRESH_TOKEN_DURATION time.Duration    `mapstructure:"REFRESH_TOKEN_DURATION"`
+	ACCESS_SIGNING_KID     string           `mapstructure:"ACCESS_SIGNING_KID"`
+	ACCESS_KEYS            []TokenKeyConfig `mapstructure:"ACCESS_KEYS"`
+	REFRESH_SIGNING_KID    string           `mapstructure:"REFRESH_SIGNING_KID"`
+	REFRESH_KEYS           []TokenKeyConfig `mapstructure:"REFRESH_KEYS"`
+	ACCESS_ALGORITHM       string           `mapstructure:"ACCESS_ALGORITHM"`
+	REFRESH_ALGORITHM      string           `mapstructure:"REFRESH_ALGORITHM"`
+	FORMAT                 string           `mapstructure:"FORMAT"`
+	AUDIENCE               string           `mapstructure:"AUDIENCE"`
+	SCOPES                 []string         `mapstructure:"SCOPES"`
+	LEEWAY                 time.Duration    `mapstructure:"LEEWAY"`
+	REVOCATION_DRIVER      string           `mapstructure:"REVOCATION_DRIVER"`
+}
+
+// TokenKeyConfig is one key of a keyring, keys without a secret key only
+// verify tokens, an empty KID is replaced by the thumbprint of the key. HS256
+// keys only have the secret key
+type TokenKeyConfig struct {
+	KID        string `mapstructure:"KID"`
+	SECRET_KEY string `mapstructure:"SECRET_KEY"`
+	PUBLIC_KEY string `mapstructure:"PUBLIC_KEY"`
+}
+
+type MailConfig struct {
+	DRIVER   string `mapstructure:"DRIVER"`
+	FROM     string `mapstructure:"FROM"`
+	HOST     string `mapstructure:"HOST"`
+	PORT     int    `mapstructure:"PORT"`
+	USERNAME string `mapstructure:"USERNAME"`
+	PASSWORD string `mapstructure:"PASSWORD"`
+}
+
+// ServerConfig configures the http server, the durations are in seconds and
+// unset values fall back to the defaults in api.NewServer
+type ServerConfig struct {
+	READ_HEADER_TIMEOUT time.Duration `mapstructure:"READ_HEADER_TIMEOUT"`
+	READ_TIMEOUT        time.Duration `mapstructure:"READ_TIMEOUT"`
+	WRITE_TIMEOUT       time.Duration `mapstructure:"WRITE_TIMEOUT"`
+	IDLE_TIMEOUT        time.Duration `mapstructure:"IDLE_TIMEOUT"`
+	SHUTDOWN_DELAY      time.Duration `mapstructure:"SHUTDOWN_DELAY"`
+	SHUTDOWN_TIMEOUT    time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	MAX_HEADER_BYTES    int           `mapstructure:"MAX_HEADER_BYTES"`
+	TLS_CERT_FILE       string        `mapstructure:"TLS_CERT_FILE"`
+	TLS_KEY_FILE        string        `mapstructure:"TLS_KEY_FILE"`
+}
+
+// MigrationsConfig configures the database migrations, they are applied
+// with the migrate command
+type MigrationsConfig struct {
+	REQUIRE_UP_TO_DATE bool `mapstructure:"REQUIRE_UP_TO_DATE"`
+}
+
+// MetricsConfig configures the prometheus /metrics endpoint, without a port
+// it is served next to the api
+type MetricsConfig struct {
+	ENABLED bool   `mapstructure:"ENABLED"`
+	PORT    string `mapstructure:"PORT"`
+}
+
+// TracingConfig configures the opentelemetry trace export, the exporter is
+// "otlp", "stdout" or "none", an empty otlp endpoint falls back to the
+// OTEL_EXPORTER_OTLP_* environment variables
+type TracingConfig struct {
+	EXPORTER     string  `mapstructure:"EXPORTER"`
+	ENDPOINT     string  `mapstructure:"ENDPOINT"`
+	SAMPLE_RATIO float64 `mapstructure:"SAMPLE_RATIO"`
+}
+
+// UserTokenConfig configures one time tokens that are mailed to users
+type UserTokenConfig struct {
+	URL               string        `mapstructure:"URL"`
+	TOKEN_DURATION    time.Duration `mapstructure:"TOKEN_DURATION"`
+	RESEND_INTERVAL   time.Duration `mapstructure:"RESEND_INTERVAL"`
+	MAX_SENDS_PER_DAY int64         `mapstructure:"MAX_SENDS_PER_DAY"`
+}
+
+// EmailLinkConfig configures passwordless login through a mailed link or code
+type EmailLinkConfig struct {
+	UserTokenConfig `mapstructure:",squash"`
+	ALLOW_SIGN_UP   bool `mapstructure:"ALLOW_SIGN_UP"`
+}
+
+type TwoFactorConfig struct {
+	ISSUER              string        `mapstructure:"ISSUER"`
+	CHALLENGE_DURATION  time.Duration `mapstructure:"CHALLENGE_DURATION"`
+	MAX_FAILED_ATTEMPTS int32         `mapstructure:"MAX_FAILED_ATTEMPTS"`
+	LOCKOUT_DURATION    time.Duration `mapstructure:"LOCKOUT_DURATION"`
+}
+
+type PasskeyConfig struct {
+	RP_ID              string        `mapstructure:"RP_ID"`
+	RP_DISPLAY_NAME    string        `mapstructure:"RP_DISPLAY_NAME"`
+	RP_ORIGINS         []string      `mapstructure:"RP_ORIGINS"`
+	CHALLENGE_DURATION time.Duration `mapstructure:"CHALLENGE_DURATION"`
 }
 
 type OciStorageConfig struct {
@@ -2,16 +2,32 @@ package utils
 
 import (
 	"backend/dto"
+	"backend/metrics"
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/oauth2"
 )
 
-func HttpGet(baseURL string, queryParams map[string]string, responseData any) (err error) {
+// httpClient is used by the Http* helpers and the oauth2 clients, the calls
+// are counted in the outbound request metrics, traced and carry the W3C trace
+// context of ctx
+var httpClient = &http.Client{Transport: otelhttp.NewTransport(metrics.InstrumentTransport(nil))}
+
+// WithHTTPClient makes libraries that take their client from the context,
+// like oauth2 and go-oidc, use the instrumented client
+func WithHTTPClient(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+}
+
+func HttpGet(ctx context.Context, baseURL string, queryParams map[string]string, responseData any) (err error) {
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		return
@@ -21,7 +37,12 @@ func HttpGet(baseURL string, queryParams map[string]string, responseData any) (e
 		q.Add(key, value)
 	}
 	u.RawQuery = q.Encode()
-	resp, err := http.Get(u.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return
+	}
+
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return
 	}
@@ -30,7 +51,7 @@ func HttpGet(baseURL string, queryParams map[string]string, responseData any) (e
 		return
 	}
 	if resp.StatusCode >= 400 {
-		err = dto.NewErrorWithStatus(resp.StatusCode, "request could not be completed successfully")
+		err = dto.NewErrorWithCode(resp.StatusCode, dto.ErrCodeUpstreamFailed, "request could not be completed successfully")
 		return
 	}
 
@@ -43,7 +64,7 @@ func HttpGet(baseURL string, queryParams map[string]string, responseData any) (e
 	return
 }
 
-func HttpGetWithHeaders(baseURL string, queryParams map[string]string, headers map[string]string, responseData any) (err error) {
+func HttpGetWithHeaders(ctx context.Context, baseURL string, queryParams map[string]string, headers map[string]string, responseData any) (err error) {
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		return
@@ -55,7 +76,7 @@ func HttpGetWithHeaders(baseURL string, queryParams map[string]string, headers m
 	}
 	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return
 	}
@@ -64,8 +85,7 @@ func HttpGetWithHeaders(baseURL string, queryParams map[string]string, headers m
 		req.Header.Add(key, value)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return
 	}
@@ -77,7 +97,7 @@ func HttpGetWithHeaders(baseURL string, queryParams map[string]string, headers m
 	}
 
 	if resp.StatusCode >= 400 {
-		err = dto.NewErrorWithStatus(resp.StatusCode, "request could not be completed successfully")
+		err = dto.NewErrorWithCode(resp.StatusCode, dto.ErrCodeUpstreamFailed, "request could not be completed successfully")
 		return
 	}
 
@@ -90,11 +110,11 @@ func HttpGetWithHeaders(baseURL string, queryParams map[string]string, headers m
 	return
 }
 
-func HttpPost(urlStr string, queryParams map[string]string, body any, responseData any) (err error) {
-	return HttpPostWithAuth(urlStr, queryParams, body, responseData, "")
+func HttpPost(ctx context.Context, urlStr string, queryParams map[string]string, body any, responseData any) (err error) {
+	return HttpPostWithAuth(ctx, urlStr, queryParams, body, responseData, "")
 }
 
-func HttpPostWithAuth(urlStr string, queryParams map[string]string, body any, responseData any, authToken string) (err error) {
+func HttpPostWithAuth(ctx context.Context, urlStr string, queryParams map[string]string, body any, responseData any, authToken string) (err error) {
 	u, err := url.Parse(urlStr)
 	if err != nil {
 		return
@@ -113,7 +133,7 @@ func HttpPostWithAuth(urlStr string, queryParams map[string]string, body any, re
 		}
 	}
 
-	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewBuffer(bodyBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewBuffer(bodyBytes))
 	if err != nil {
 		return
 	}
@@ -124,13 +144,12 @@ func HttpPostWithAuth(urlStr string, queryParams map[string]string, body any, re
 		req.Header.Set("Authorization", "Bearer "+authToken)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return
 	}
 	if resp.StatusCode >= 400 {
-		err = dto.NewErrorWithStatus(resp.StatusCode, "reqest could not be completed successfully")
+		err = dto.NewErrorWithCode(resp.StatusCode, dto.ErrCodeUpstreamFailed, "request could not be completed successfully")
 		return
 	}
 	defer resp.Body.Close()
@@ -139,13 +158,14 @@ func HttpPostWithAuth(urlStr string, queryParams map[string]string, body any, re
 	if err != nil {
 		return
 	}
-	slog.Info("httpPost", "response", string(respBody))
+	// the body can hold tokens, so only its size is logged
+	slog.InfoContext(ctx, "httpPost", slog.Int("status", resp.StatusCode), slog.Int("bytes", len(respBody)))
 	json.Unmarshal(respBody, &responseData)
 
 	return
 }
 
-func HttpPostFormData(urlStr string, queryParams map[string]string, form map[string]string, responseData any) (err error) {
+func HttpPostFormData(ctx context.Context, urlStr string, queryParams map[string]string, form map[string]string, responseData any) (err error) {
 	u, err := url.Parse(urlStr)
 	if err != nil {
 		return
@@ -163,15 +183,14 @@ func HttpPostFormData(urlStr string, queryParams map[string]string, form map[str
 	}
 
 	formDataString := formData.Encode()
-	req, err := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(formDataString))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(formDataString))
 	if err != nil {
 		return
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return
 	}
@@ -182,13 +201,26 @@ func HttpPostFormData(urlStr string, queryParams map[string]string, form map[str
 		return
 	}
 
-	slog.Info("httpPostFormData response ", "response", string(respBody))
+	slog.InfoContext(ctx, "httpPostFormData", slog.Int("status", resp.StatusCode), slog.Int("bytes", len(respBody)))
 
 	if resp.StatusCode >= 400 {
-		err = dto.NewErrorWithStatus(resp.StatusCode, "reqest could not be completed successfully")
+		err = dto.NewErrorWithCode(resp.StatusCode, dto.ErrCodeUpstreamFailed, "request could not be completed successfully")
 		return
 	}
 	json.Unmarshal(respBody, &responseData)
 
 	return
 }
+
+// LinkWithToken adds the token as the "token" query parameter of the link
+func LinkWithToken(baseURL string, token string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
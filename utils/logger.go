@@ -3,6 +3,8 @@ package utils
 import (
 	"context"
 	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ctxKey string
@@ -11,35 +13,79 @@ const (
 	slogFields ctxKey = "slog_fields"
 )
 
+// ContextHandler adds the attributes of the context to every record and masks
+// the values of sensitive keys, also inside groups, maps and structs, before
+// they reach the wrapped handler
 type ContextHandler struct {
 	slog.Handler
+	// RedactKeys replaces DefaultRedactKeys when it is set
+	RedactKeys []string
+
+	redactor *redactor
 }
 
-// Handle adds contextual attributes to the Record before calling the underlying
-// handler
+// NewContextHandler wraps the handler, nil redactKeys uses DefaultRedactKeys
+func NewContextHandler(handler slog.Handler, redactKeys []string) *ContextHandler {
+	return &ContextHandler{Handler: handler, RedactKeys: redactKeys, redactor: newRedactor(redactKeys)}
+}
+
+func (h ContextHandler) getRedactor() *redactor {
+	if h.redactor == nil {
+		return newRedactor(h.RedactKeys)
+	}
+	return h.redactor
+}
+
+// Handle adds contextual attributes and the ids of the current span to the
+// Record and redacts them before calling the underlying handler
 func (h ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	redactor := h.getRedactor()
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactor.attr(attr))
+		return true
+	})
+
 	if attrs, ok := ctx.Value(slogFields).([]slog.Attr); ok {
 		for _, v := range attrs {
-			r.AddAttrs(v)
+			redacted.AddAttrs(redactor.attr(v))
 		}
 	}
 
-	return h.Handler.Handle(ctx, r)
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		redacted.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+
+	return h.Handler.Handle(ctx, redacted)
+}
+
+// WithAttrs keeps the handler wrapped, so loggers made with slog.With are
+// redacted too
+func (h ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactor := h.getRedactor()
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactor.attr(attr)
+	}
+	return &ContextHandler{Handler: h.Handler.WithAttrs(redacted), RedactKeys: h.RedactKeys, redactor: redactor}
 }
 
-// AppendCtx adds an slog attribute to the provided context so that it will be
+func (h ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name), RedactKeys: h.RedactKeys, redactor: h.getRedactor()}
+}
+
+// AppendCtx adds slog attributes to the provided context so that they will be
 // included in any Record created with such context
-func AppendCtx(parent context.Context, attr slog.Attr) context.Context {
+func AppendCtx(parent context.Context, attrs ...slog.Attr) context.Context {
 	if parent == nil {
 		parent = context.Background()
 	}
 
-	if v, ok := parent.Value(slogFields).([]slog.Attr); ok {
-		v = append(v, attr)
-		return context.WithValue(parent, slogFields, v)
-	}
-
-	v := []slog.Attr{}
-	v = append(v, attr)
+	// copy so contexts derived from the same parent do not share attributes
+	v, _ := parent.Value(slogFields).([]slog.Attr)
+	v = append(v[:len(v):len(v)], attrs...)
 	return context.WithValue(parent, slogFields, v)
 }
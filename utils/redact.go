@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+const redactedValue = "[REDACTED]"
+
+// DefaultRedactKeys are masked when ContextHandler has no RedactKeys
+var DefaultRedactKeys = []string{"password", "token", "code", "secret", "authorization"}
+
+// redactor masks attributes whose key, or the last word of it, is one of the
+// keys, so "password", "newPassword" and "access_token" are masked but
+// "token_id" is not
+type redactor struct {
+	keys map[string]bool
+}
+
+func newRedactor(keys []string) *redactor {
+	if keys == nil {
+		keys = DefaultRedactKeys
+	}
+
+	r := &redactor{keys: make(map[string]bool, len(keys))}
+	for _, key := range keys {
+		r.keys[strings.ToLower(key)] = true
+	}
+	return r
+}
+
+func (r *redactor) sensitive(key string) bool {
+	words := splitWords(key)
+	if len(words) == 0 {
+		return false
+	}
+	return r.keys[strings.Join(words, "_")] || r.keys[words[len(words)-1]]
+}
+
+func (r *redactor) attr(attr slog.Attr) slog.Attr {
+	if r.sensitive(attr.Key) {
+		return slog.String(attr.Key, redactedValue)
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindGroup:
+		attrs := value.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, groupAttr := range attrs {
+			redacted[i] = r.attr(groupAttr)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		return slog.Attr{Key: attr.Key, Value: r.any(value)}
+	default:
+		return slog.Attr{Key: attr.Key, Value: value}
+	}
+}
+
+// any masks the keys inside maps, structs and slices, they are converted to
+// their json form first so the struct fields are named like in the output
+func (r *redactor) any(value slog.Value) slog.Value {
+	v := value.Any()
+	if _, ok := v.(error); ok {
+		return value
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return value
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array:
+	default:
+		return value
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return value
+	}
+
+	var decoded any
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	if err = decoder.Decode(&decoded); err != nil {
+		return value
+	}
+	return slog.AnyValue(r.json(decoded))
+}
+
+func (r *redactor) json(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if r.sensitive(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = r.json(value)
+			}
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = r.json(value)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// splitWords splits snake_case, kebab-case and camelCase keys into lower case
+// words
+func splitWords(key string) []string {
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+
+	runes := []rune(key)
+	for i, c := range runes {
+		switch {
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			flush()
+		case unicode.IsUpper(c):
+			// the last letter of an acronym starts the next word, as in "HTTPHeader"
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				flush()
+			}
+			word.WriteRune(unicode.ToLower(c))
+		default:
+			word.WriteRune(c)
+		}
+	}
+	flush()
+	return words
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// logRecord logs through a ContextHandler into a buffer and returns the json
+// record it wrote
+func logRecord(t *testing.T, redactKeys []string, log func(logger *slog.Logger)) (map[string]any, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	log(slog.New(NewContextHandler(slog.NewJSONHandler(&buf, nil), redactKeys)))
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("could not decode log record %q: %v", buf.String(), err)
+	}
+	return record, buf.String()
+}
+
+// lookup follows the keys through the nested objects of the record
+func lookup(t *testing.T, record map[string]any, keys ...string) any {
+	t.Helper()
+
+	var value any = record
+	for _, key := range keys {
+		object, ok := value.(map[string]any)
+		if !ok {
+			t.Fatalf("%s is not an object in %v", key, record)
+		}
+		value, ok = object[key]
+		if !ok {
+			t.Fatalf("%s is missing in %v", strings.Join(keys, "."), record)
+		}
+	}
+	return value
+}
+
+func TestContextHandlerRedactsAttributes(t *testing.T) {
+	tests := []struct {
+		key      string
+		redacted bool
+	}{
+		{key: "password", redacted: true},
+		{key: "newPassword", redacted: true},
+		{key: "refreshToken", redacted: true},
+		{key: "access_token", redacted: true},
+		{key: "code", redacted: true},
+		{key: "client-secret", redacted: true},
+		{key: "Authorization", redacted: true},
+		{key: "token_id", redacted: false},
+		{key: "code_length", redacted: false},
+		{key: "email", redacted: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			record, output := logRecord(t, nil, func(logger *slog.Logger) {
+				logger.Info("test", slog.String(tt.key, "sensitive-value"))
+			})
+
+			got := lookup(t, record, tt.key)
+			if tt.redacted {
+				if got != redactedValue || strings.Contains(output, "sensitive-value") {
+					t.Errorf("%s = %v, want it redacted", tt.key, got)
+				}
+			} else if got != "sensitive-value" {
+				t.Errorf("%s = %v, want it kept", tt.key, got)
+			}
+		})
+	}
+}
+
+type testLoginRequest struct {
+	Email        string            `json:"email"`
+	Password     string            `json:"password"`
+	RefreshToken string            `json:"refreshToken"`
+	Metadata     map[string]string `json:"metadata"`
+}
+
+func TestContextHandlerRedactsNestedValues(t *testing.T) {
+	record, output := logRecord(t, nil, func(logger *slog.Logger) {
+		logger.Info("test",
+			slog.Group("request", slog.String("email", "user@example.com"), slog.String("password", "group-password")),
+			slog.Any("payload", map[string]any{
+				"email": "user@example.com",
+				"user":  map[string]any{"password": "map-password"},
+				"codes": []any{map[string]any{"code": "123456"}},
+			}),
+			slog.Any("login", &testLoginRequest{
+				Email:        "user@example.com",
+				Password:     "struct-password",
+				RefreshToken: "struct-refresh-token",
+				Metadata:     map[string]string{"secret": "struct-secret"},
+			}),
+		)
+	})
+
+	for _, value := range []string{"group-password", "map-password", "123456", "struct-password", "struct-refresh-token", "struct-secret"} {
+		if strings.Contains(output, value) {
+			t.Errorf("%s was logged: %s", value, output)
+		}
+	}
+
+	redacted := [][]string{
+		{"request", "password"},
+		{"payload", "user", "password"},
+		{"login", "password"},
+		{"login", "refreshToken"},
+		{"login", "metadata", "secret"},
+	}
+	for _, keys := range redacted {
+		if got := lookup(t, record, keys...); got != redactedValue {
+			t.Errorf("%s = %v, want it redacted", strings.Join(keys, "."), got)
+		}
+	}
+
+	codes := lookup(t, record, "payload", "codes").([]any)
+	if got := codes[0].(map[string]any)["code"]; got != redactedValue {
+		t.Errorf("payload.codes[0].code = %v, want it redacted", got)
+	}
+
+	for _, keys := range [][]string{{"request", "email"}, {"payload", "email"}, {"login", "email"}} {
+		if got := lookup(t, record, keys...); got != "user@example.com" {
+			t.Errorf("%s = %v, want it kept", strings.Join(keys, "."), got)
+		}
+	}
+}
+
+func TestContextHandlerRedactsContextAndLoggerAttributes(t *testing.T) {
+	record, output := logRecord(t, nil, func(logger *slog.Logger) {
+		ctx := AppendCtx(context.Background(), slog.String("token", "context-token"), slog.String("requestID", "request-1"))
+		logger.With(slog.String("secret", "logger-secret")).WithGroup("auth").InfoContext(ctx, "test", slog.String("code", "654321"))
+	})
+
+	for _, value := range []string{"context-token", "logger-secret", "654321"} {
+		if strings.Contains(output, value) {
+			t.Errorf("%s was logged: %s", value, output)
+		}
+	}
+	if got := lookup(t, record, "secret"); got != redactedValue {
+		t.Errorf("secret = %v, want it redacted", got)
+	}
+	if got := lookup(t, record, "auth", "code"); got != redactedValue {
+		t.Errorf("auth.code = %v, want it redacted", got)
+	}
+	if got := lookup(t, record, "auth", "requestID"); got != "request-1" {
+		t.Errorf("auth.requestID = %v, want it kept", got)
+	}
+}
+
+func TestContextHandlerRedactKeys(t *testing.T) {
+	record, _ := logRecord(t, []string{"ssn"}, func(logger *slog.Logger) {
+		logger.Info("test", slog.String("userSSN", "123-45-6789"), slog.String("password", "kept"))
+	})
+
+	if got := lookup(t, record, "userSSN"); got != redactedValue {
+		t.Errorf("userSSN = %v, want it redacted", got)
+	}
+	if got := lookup(t, record, "password"); got != "kept" {
+		t.Errorf("password = %v, want it kept as it is not in the keys", got)
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := map[string][]string{
+		"password":      {"password"},
+		"newPassword":   {"new", "password"},
+		"refresh_token": {"refresh", "token"},
+		"client-secret": {"client", "secret"},
+		"HTTPHeader":    {"http", "header"},
+		"userID":        {"user", "id"},
+		"":              nil,
+	}
+
+	for key, want := range tests {
+		if got := splitWords(key); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitWords(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package utils
 
 import (
+	crand "crypto/rand"
+	"encoding/base64"
+	"math/big"
 	"math/rand"
 	"time"
 )
@@ -19,3 +22,28 @@ func GenerateRandomString(n int64) string {
 
 	return string(result)
 }
+
+// GenerateSecureToken generates a url safe random token from n bytes of
+// cryptographically secure randomness.
+func GenerateSecureToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// GenerateSecureString generates a random string of length n using only the
+// characters of the given alphabet, the randomness is cryptographically secure.
+func GenerateSecureString(n int, alphabet string) (string, error) {
+	result := make([]byte, n)
+	max := big.NewInt(int64(len(alphabet)))
+	for i := range result {
+		idx, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		result[i] = alphabet[idx.Int64()]
+	}
+	return string(result), nil
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890" in base32
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// the RFC 6238 appendix B vectors have eight digits, a six digit code is the
+// last six of them
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestGenerateTOTPCode(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		code, err := GenerateTOTPCode(rfc6238Secret, time.Unix(vector.unix, 0))
+		if err != nil {
+			t.Fatalf("T=%d: %v", vector.unix, err)
+		}
+		if code != vector.code {
+			t.Errorf("T=%d: code = %s, want %s", vector.unix, code, vector.code)
+		}
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		step, ok := ValidateTOTPCode(rfc6238Secret, vector.code, time.Unix(vector.unix, 0))
+		if !ok {
+			t.Errorf("T=%d: code %s was rejected", vector.unix, vector.code)
+			continue
+		}
+		if step != vector.unix/totpPeriod {
+			t.Errorf("T=%d: step = %d, want %d", vector.unix, step, vector.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPCodeSkew(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+
+	// the code of the step before and after is accepted for clock drift
+	for _, offset := range []time.Duration{-totpPeriod * time.Second, totpPeriod * time.Second} {
+		code, err := GenerateTOTPCode(rfc6238Secret, at.Add(offset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := ValidateTOTPCode(rfc6238Secret, code, at); !ok {
+			t.Errorf("code of the step %s away was rejected", offset)
+		}
+	}
+
+	code, err := GenerateTOTPCode(rfc6238Secret, at.Add(2*totpPeriod*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ValidateTOTPCode(rfc6238Secret, code, at); ok {
+		t.Error("code of the step two periods away was accepted")
+	}
+}
+
+func TestValidateTOTPCodeRejectsMalformedCodes(t *testing.T) {
+	at := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870820", "94287082"} {
+		if _, ok := ValidateTOTPCode(rfc6238Secret, code, at); ok {
+			t.Errorf("code %q was accepted", code)
+		}
+	}
+
+	// a lower case secret without padding, as typed by a user, still works
+	if _, ok := ValidateTOTPCode(strings.ToLower(rfc6238Secret), "287082", at); !ok {
+		t.Error("code was rejected for a lower case secret")
+	}
+}